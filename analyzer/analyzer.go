@@ -0,0 +1,102 @@
+// Package analyzer defines how content is analyzed for indexing, and a registry
+// to select analyzers based on the type of the content
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// ErrUnsupported is returned by analyzers that cannot handle the given content
+// despite matching its type, in which case the next matching analyzer is tried
+var ErrUnsupported = errors.New("unsupported content type for indexing")
+
+// Input is content to be analyzed
+type Input struct {
+	// JobID identifies the analysis, ie the hash of the content
+	JobID string
+
+	// Name is the display name of the content, if one was provided
+	Name string
+
+	// MimeType is the detected type of the content
+	MimeType string
+
+	// Content is the raw content
+	Content []byte
+}
+
+// Result is the outcome of an analysis
+type Result struct {
+	// Content is the searchable text extracted from the content
+	Content string
+
+	// Category is the category of the content, ie models.MimeTypeDocument - if
+	// not set, the category of the analyzer's registration is used
+	Category string
+
+	// MimeType refines the detected type of the content, if set
+	MimeType string
+
+	// DisplayName is used for the content if no display name was provided
+	DisplayName string
+
+	// Tags are additional tags for the content
+	Tags []string
+
+	// Labels are classifications of the content, ie what an image depicts,
+	// mapped to their confidence between 0 and 1
+	Labels map[string]float64
+
+	// Headings are section titles within the content, which are boosted in
+	// search results
+	Headings []string
+
+	// Comments are comments in source code, which are indexed separately from
+	// the content
+	Comments string
+
+	// Sections are the parts of the extracted content, ie the chapters of a
+	// book or the cues of subtitles, in order
+	Sections []models.Section
+
+	// References are content hashes of other objects the content links to
+	References []string
+
+	// Fields are the names of the keys or columns in structured content, which
+	// can be used to filter searches
+	Fields []string
+
+	// Attributes are additional properties of the content, ie its author
+	Attributes map[string]string
+
+	// Location is the geographic position associated with the content, ie
+	// where a photo was taken
+	Location *models.GeoPoint
+}
+
+// Analyzer extracts searchable text and metadata from content
+type Analyzer interface {
+	Analyze(ctx context.Context, in Input) (*Result, error)
+}
+
+// StreamAnalyzer is an Analyzer that can also analyze content as it is read,
+// so that large content does not have to be buffered in full
+type StreamAnalyzer interface {
+	Analyzer
+
+	// AnalyzeStream analyzes the content read from r. The first bytes of the
+	// content are provided as in.Content, and are also returned by r, so that
+	// analyzers can decide whether they support the content - ErrUnsupported
+	// must be returned before reading from r.
+	AnalyzeStream(ctx context.Context, in Input, r io.Reader) (*Result, error)
+}
+
+// Func is an adapter to allow the use of ordinary functions as analyzers
+type Func func(ctx context.Context, in Input) (*Result, error)
+
+// Analyze calls f(ctx, in)
+func (f Func) Analyze(ctx context.Context, in Input) (*Result, error) { return f(ctx, in) }
@@ -0,0 +1,324 @@
+// Package archive provides analysis of zip and tar archives
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// Options denotes limits on the archives that are analyzed, which guard
+// against zip bombs and other malicious archives
+type Options struct {
+	// MaxEntries is the maximum number of entries in an archive - archives
+	// with more entries are rejected
+	MaxEntries int
+
+	// MaxEntrySize is the maximum decompressed size of a member that is
+	// analyzed - larger members are indexed by name only
+	MaxEntrySize int64
+
+	// MaxSize is the maximum total decompressed size of an archive - larger
+	// archives are rejected
+	MaxSize int64
+
+	// MaxDepth is the maximum nesting of archives within archives - deeper
+	// archives are indexed by name only
+	MaxDepth int
+
+	// MaxPathDepth is the maximum number of path elements in an entry's name -
+	// entries with deeper paths are skipped
+	MaxPathDepth int
+}
+
+// DefaultOptions are the default archive limits
+var DefaultOptions = Options{
+	MaxEntries:   1000,
+	MaxEntrySize: 32 << 20,
+	MaxSize:      256 << 20,
+	MaxDepth:     2,
+	MaxPathDepth: 32,
+}
+
+// Analyzer enumerates the entries of archives, and analyzes their members
+type Analyzer struct {
+	members analyzer.Analyzer
+	opts    Options
+
+	l *zap.SugaredLogger
+}
+
+// NewAnalyzer instantiates an archive analyzer that analyzes members with the
+// given analyzer, ie an analyzer.Registry. Unset options are taken from
+// DefaultOptions.
+func NewAnalyzer(members analyzer.Analyzer, opts Options, logger *zap.SugaredLogger) *Analyzer {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultOptions.MaxEntries
+	}
+	if opts.MaxEntrySize <= 0 {
+		opts.MaxEntrySize = DefaultOptions.MaxEntrySize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultOptions.MaxSize
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultOptions.MaxDepth
+	}
+	if opts.MaxPathDepth <= 0 {
+		opts.MaxPathDepth = DefaultOptions.MaxPathDepth
+	}
+	return &Analyzer{members, opts, logger}
+}
+
+// depthKey is the context key for the nesting depth of the archive being
+// analyzed
+type depthKey struct{}
+
+// entry is a file within an archive
+type entry struct {
+	name string
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// Analyze indexes the names of each file in the archive, along with the
+// content of supported members. Each file is recorded as a section of the
+// archive's content. Content that is not a zip, tar or gzipped tar archive is
+// not supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	var depth, _ = ctx.Value(depthKey{}).(int)
+	if depth >= a.opts.MaxDepth {
+		return nil, analyzer.ErrUnsupported
+	}
+
+	var (
+		format string
+		walk   func(fn func(entry) error) error
+	)
+	switch {
+	case bytes.HasPrefix(in.Content, []byte("PK\x03\x04")),
+		bytes.HasPrefix(in.Content, []byte("PK\x05\x06")):
+		zr, err := zip.NewReader(bytes.NewReader(in.Content), int64(len(in.Content)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zip archive: %s", err.Error())
+		}
+		format, walk = "zip", walkZip(zr)
+	case bytes.HasPrefix(in.Content, []byte("\x1f\x8b")):
+		gz, err := gzip.NewReader(bytes.NewReader(in.Content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip archive: %s", err.Error())
+		}
+		defer gz.Close()
+		var br = bufio.NewReader(gz)
+		if head, _ := br.Peek(512); !isTar(head) {
+			return nil, analyzer.ErrUnsupported
+		}
+		format, walk = "tar+gzip", walkTar(tar.NewReader(br))
+	case isTar(in.Content):
+		format, walk = "tar", walkTar(tar.NewReader(bytes.NewReader(in.Content)))
+	default:
+		return nil, analyzer.ErrUnsupported
+	}
+
+	var (
+		l        = a.l.With("job_id", in.JobID, "format", format)
+		mctx     = context.WithValue(ctx, depthKey{}, depth+1)
+		content  strings.Builder
+		sections []models.Section
+		tags     = newSet()
+		refs     = newSet()
+		entries  int
+		skipped  int
+		total    int64
+	)
+	if err := walk(func(e entry) error {
+		if entries++; entries > a.opts.MaxEntries {
+			return fmt.Errorf("archive has more than %d entries", a.opts.MaxEntries)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var name, ok = a.clean(e.name)
+		if !ok {
+			l.Debugw("skipping entry", "name", e.name)
+			skipped++
+			return nil
+		}
+
+		// record the entry's name, followed by its content
+		if content.Len() > 0 {
+			content.WriteString("\n\n")
+		}
+		sections = append(sections, models.Section{Title: name, Offset: content.Len()})
+		content.WriteString(name)
+
+		// oversized members are not read, but still count towards the size of
+		// the archive
+		var b []byte
+		var err error
+		if e.size > a.opts.MaxEntrySize {
+			total += e.size
+		} else {
+			b, err = read(e, a.opts.MaxEntrySize)
+			total += int64(len(b))
+		}
+		if total > a.opts.MaxSize {
+			return fmt.Errorf("archive is larger than %d bytes when decompressed", a.opts.MaxSize)
+		}
+		if err != nil || len(b) == 0 || int64(len(b)) > a.opts.MaxEntrySize {
+			l.Debugw("failed to read entry", "name", name, "error", err)
+			return nil
+		}
+
+		result, err := a.members.Analyze(mctx, analyzer.Input{
+			JobID:    in.JobID + "/" + name,
+			Name:     name,
+			MimeType: detect(name, b),
+			Content:  b,
+		})
+		if err != nil {
+			if err != analyzer.ErrUnsupported {
+				l.Warnw("failed to analyze entry", "name", name, "error", err)
+			}
+			return nil
+		}
+		if result.Content != "" {
+			content.WriteString("\n")
+			content.WriteString(result.Content)
+		}
+		tags.add(result.Tags...)
+		refs.add(result.References...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &analyzer.Result{
+		Content:    content.String(),
+		Sections:   sections,
+		Tags:       tags.values,
+		References: refs.values,
+		Attributes: map[string]string{
+			"format":  format,
+			"entries": strconv.Itoa(len(sections)),
+			"skipped": strconv.Itoa(skipped),
+		},
+	}, nil
+}
+
+// clean normalizes the given entry name, and reports whether the entry should
+// be indexed
+func (a *Analyzer) clean(name string) (string, bool) {
+	name = path.Clean("/" + strings.Replace(name, "\\", "/", -1))[1:]
+	if name == "" || strings.Count(name, "/")+1 > a.opts.MaxPathDepth {
+		return "", false
+	}
+	return name, true
+}
+
+// walkZip iterates over the files in a zip archive
+func walkZip(zr *zip.Reader) func(fn func(entry) error) error {
+	return func(fn func(entry) error) error {
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			if err := fn(entry{
+				name: f.Name,
+				size: int64(f.UncompressedSize64),
+				open: f.Open,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// walkTar iterates over the regular files in a tar archive
+func walkTar(tr *tar.Reader) func(fn func(entry) error) error {
+	return func(fn func(entry) error) error {
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read tar archive: %s", err.Error())
+			}
+			if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+				continue
+			}
+			if err := fn(entry{
+				name: h.Name,
+				size: h.Size,
+				open: func() (io.ReadCloser, error) { return ioutil.NopCloser(tr), nil },
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// read reads up to one byte more than limit from the given entry, so that
+// oversized entries can be detected regardless of their declared size
+func read(e entry, limit int64) ([]byte, error) {
+	r, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(io.LimitReader(r, limit+1))
+}
+
+// isTar checks for the magic bytes of a tar archive
+func isTar(head []byte) bool {
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
+
+// detect determines the type of the given member from its content, falling
+// back to its extension if its content is not recognized
+func detect(name string, content []byte) string {
+	var contentType = analyzer.DetectContentType(content)
+	if contentType == "application/octet-stream" {
+		if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+			return t
+		}
+	}
+	return contentType
+}
+
+// set is an ordered set of strings
+type set struct {
+	seen   map[string]bool
+	values []string
+}
+
+func newSet() *set { return &set{seen: make(map[string]bool)} }
+
+func (s *set) add(values ...string) {
+	for _, v := range values {
+		if !s.seen[v] {
+			s.seen[v] = true
+			s.values = append(s.values, v)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/archive"
+	"github.com/RTradeLtd/Lens/v2/analyzer/text"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// file is an archive entry
+type file struct {
+	name    string
+	content string
+}
+
+// newZip creates a zip archive with the given files
+func newZip(t *testing.T, files ...file) []byte {
+	var b bytes.Buffer
+	var w = zip.NewWriter(&b)
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// newTar creates a tar archive with the given files, optionally gzipped
+func newTar(t *testing.T, gzipped bool, files ...file) []byte {
+	var b bytes.Buffer
+	var w = tar.NewWriter(&b)
+	w.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, f := range files {
+		if err := w.WriteHeader(&tar.Header{
+			Name: f.name,
+			Mode: 0644,
+			Size: int64(len(f.content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !gzipped {
+		return b.Bytes()
+	}
+	var gz bytes.Buffer
+	var gw = gzip.NewWriter(&gz)
+	gw.Write(b.Bytes())
+	gw.Close()
+	return gz.Bytes()
+}
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	var opts = archive.Options{
+		MaxEntries:   4,
+		MaxEntrySize: 1024,
+		MaxSize:      3000,
+		MaxDepth:     2,
+		MaxPathDepth: 3,
+	}
+	var files = []file{
+		{"README.md", "hello world"},
+		{"dir/notes.txt", "more notes"},
+	}
+	tests := []struct {
+		name    string
+		content []byte
+		want    *analyzer.Result
+		wantErr string
+	}{
+		{"not an archive", []byte("hello world"), nil, "unsupported content type"},
+		{"gzip without tar", func() []byte {
+			var b bytes.Buffer
+			var gw = gzip.NewWriter(&b)
+			gw.Write([]byte("hello world"))
+			gw.Close()
+			return b.Bytes()
+		}(), nil, "unsupported content type"},
+		{"zip", newZip(t, files...), &analyzer.Result{
+			Content: "README.md\nhello world\n\ndir/notes.txt\nmore notes",
+			Sections: []models.Section{
+				{Title: "README.md", Offset: 0},
+				{Title: "dir/notes.txt", Offset: 23},
+			},
+			Attributes: map[string]string{"format": "zip", "entries": "2", "skipped": "0"},
+		}, ""},
+		{"tar", newTar(t, false, files...), &analyzer.Result{
+			Content: "README.md\nhello world\n\ndir/notes.txt\nmore notes",
+			Sections: []models.Section{
+				{Title: "README.md", Offset: 0},
+				{Title: "dir/notes.txt", Offset: 23},
+			},
+			Attributes: map[string]string{"format": "tar", "entries": "2", "skipped": "0"},
+		}, ""},
+		{"tar.gz", newTar(t, true, files...), &analyzer.Result{
+			Content: "README.md\nhello world\n\ndir/notes.txt\nmore notes",
+			Sections: []models.Section{
+				{Title: "README.md", Offset: 0},
+				{Title: "dir/notes.txt", Offset: 23},
+			},
+			Attributes: map[string]string{"format": "tar+gzip", "entries": "2", "skipped": "0"},
+		}, ""},
+		{"unsafe and deep paths", newZip(t,
+			file{"../../etc/passwd", "root"},
+			file{"a/b/c/d.txt", "too deep"},
+		), &analyzer.Result{
+			Content:    "etc/passwd\nroot",
+			Sections:   []models.Section{{Title: "etc/passwd", Offset: 0}},
+			Attributes: map[string]string{"format": "zip", "entries": "1", "skipped": "1"},
+		}, ""},
+		{"oversized and unsupported members", newZip(t,
+			file{"big.txt", strings.Repeat("a", 1025)},
+			file{"blob.bin", "\x00\x01\x02"},
+		), &analyzer.Result{
+			Content: "big.txt\n\nblob.bin",
+			Sections: []models.Section{
+				{Title: "big.txt", Offset: 0},
+				{Title: "blob.bin", Offset: 9},
+			},
+			Attributes: map[string]string{"format": "zip", "entries": "2", "skipped": "0"},
+		}, ""},
+		{"nested archives", newZip(t,
+			file{"inner.zip", string(newZip(t, file{"a.txt", "nested"}))},
+		), &analyzer.Result{
+			Content: "inner.zip\na.txt\nnested",
+			Sections: []models.Section{
+				{Title: "inner.zip", Offset: 0},
+			},
+			Attributes: map[string]string{"format": "zip", "entries": "1", "skipped": "0"},
+		}, ""},
+		{"too deeply nested archives", newZip(t,
+			file{"inner.zip", string(newZip(t,
+				file{"inner.zip", string(newZip(t, file{"a.txt", "nested"}))},
+			))},
+		), &analyzer.Result{
+			Content: "inner.zip\ninner.zip",
+			Sections: []models.Section{
+				{Title: "inner.zip", Offset: 0},
+			},
+			Attributes: map[string]string{"format": "zip", "entries": "1", "skipped": "0"},
+		}, ""},
+		{"too many entries", newZip(t,
+			file{"1", ""}, file{"2", ""}, file{"3", ""}, file{"4", ""}, file{"5", ""},
+		), nil, "more than 4 entries"},
+		{"too large", newZip(t,
+			file{"1", strings.Repeat("a", 1000)},
+			file{"2", strings.Repeat("a", 1000)},
+			file{"3", strings.Repeat("a", 1001)},
+		), nil, "larger than 3000 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = analyzer.NewRegistry(analyzer.Registration{
+				Name:     "text",
+				Patterns: []string{"text/*"},
+				Analyzer: text.NewAnalyzer(),
+			})
+			var a = archive.NewAnalyzer(r, opts, nil)
+			r.Register(analyzer.Registration{
+				Name:     "archive",
+				Patterns: []string{"application/zip"},
+				Analyzer: a,
+			})
+
+			got, err := a.Analyze(context.Background(), analyzer.Input{Content: tt.content})
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
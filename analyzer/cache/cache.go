@@ -0,0 +1,222 @@
+// Package cache provides an on-disk cache of analysis results, so that content
+// that has already been analyzed does not need to be analyzed again
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	mh "github.com/multiformats/go-multihash"
+)
+
+// DefaultMaxSize is the default maximum size, in bytes, of the cache
+const DefaultMaxSize int64 = 256 << 20
+
+// Options denotes configuration for the cache
+type Options struct {
+	// Path is the directory to store cached results in
+	Path string
+
+	// MaxSize is the maximum size of the cache - when it is exceeded, the least
+	// recently used results are evicted. If not set, DefaultMaxSize is used.
+	MaxSize int64
+}
+
+// Cache stores analysis results on disk, keyed by the multihash of the
+// analyzed content and the name of the analyzer that produced them. Results
+// are only valid for the version of the analyzer that produced them.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	entries map[string]*entry
+	size    int64
+	mux     sync.Mutex
+
+	l *zap.SugaredLogger
+}
+
+// entry tracks the size and last use of a cached result
+type entry struct {
+	size int64
+	used time.Time
+}
+
+// result is the on-disk format of a cached result
+type result struct {
+	Version string `json:"version"`
+	Value   string `json:"value"`
+}
+
+// New instantiates a cache in the configured directory, picking up results
+// cached by previous instances
+func New(opts Options, logger *zap.SugaredLogger) (*Cache, error) {
+	if opts.Path == "" {
+		return nil, errors.New("no cache path provided")
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	if err := os.MkdirAll(opts.Path, 0755); err != nil {
+		return nil, err
+	}
+
+	var c = &Cache{
+		dir:     opts.Path,
+		maxSize: opts.MaxSize,
+		entries: make(map[string]*entry),
+		l:       logger,
+	}
+	if err := filepath.Walk(opts.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		c.entries[path] = &entry{size: info.Size(), used: info.ModTime()}
+		c.size += info.Size()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	c.evict()
+	return c, nil
+}
+
+// Key generates the multihash key for the given content
+func Key(content []byte) (string, error) {
+	hash, err := mh.Sum(content, mh.SHA2_256, -1)
+	if err != nil {
+		return "", err
+	}
+	return hash.B58String(), nil
+}
+
+// Get retrieves the result of the given analyzer for the content with the
+// given key. Results produced by other versions of the analyzer are removed.
+func (c *Cache) Get(analyzer, version, key string) (string, bool) {
+	var path = c.path(analyzer, key)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	e, ok := c.entries[path]
+	if !ok {
+		return "", false
+	}
+
+	var r result
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(b, &r)
+	}
+	if err != nil || r.Version != version {
+		c.l.Debugw("removing invalid result",
+			"analyzer", analyzer, "key", key, "version", r.Version, "error", err)
+		c.remove(path)
+		return "", false
+	}
+
+	// record use so that frequently used results are kept
+	e.used = time.Now()
+	os.Chtimes(path, e.used, e.used)
+	return r.Value, true
+}
+
+// Put stores the result of the given analyzer for the content with the given
+// key, evicting the least recently used results if the cache is too large
+func (c *Cache) Put(analyzer, version, key, value string) error {
+	b, err := json.Marshal(&result{Version: version, Value: value})
+	if err != nil {
+		return err
+	}
+	var path = c.path(analyzer, key)
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(path, b, 0644); err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if e, ok := c.entries[path]; ok {
+		c.size -= e.size
+	}
+	c.entries[path] = &entry{size: int64(len(b)), used: time.Now()}
+	c.size += int64(len(b))
+	c.evict()
+	return nil
+}
+
+// Size returns the total size, in bytes, of cached results
+func (c *Cache) Size() int64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.size
+}
+
+// path returns the location of the given result
+func (c *Cache) path(analyzer, key string) string {
+	return filepath.Join(c.dir, segment(analyzer), segment(key))
+}
+
+// segment escapes the given name for use as a single path segment, so that
+// names such as "../x" or "a/b" can't refer to other locations. Alphanumeric
+// characters and dashes are kept as-is so that typical names stay readable,
+// and names are escaped uniquely so that they can't collide.
+func segment(name string) string {
+	if name == "" {
+		return "%"
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// evict removes the least recently used results until the cache fits within
+// its maximum size. The caller must hold the lock.
+func (c *Cache) evict() {
+	if c.size <= c.maxSize {
+		return
+	}
+	var paths = make([]string, 0, len(c.entries))
+	for path := range c.entries {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return c.entries[paths[i]].used.Before(c.entries[paths[j]].used)
+	})
+	for _, path := range paths {
+		if c.size <= c.maxSize {
+			break
+		}
+		c.remove(path)
+	}
+	c.l.Debugw("evicted results", "size", c.size)
+}
+
+// remove deletes the given result. The caller must hold the lock.
+func (c *Cache) remove(path string) {
+	if e, ok := c.entries[path]; ok {
+		c.size -= e.size
+		delete(c.entries, path)
+	}
+	os.Remove(path)
+}
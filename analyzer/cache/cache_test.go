@@ -0,0 +1,157 @@
+package cache_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer/cache"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := cache.New(cache.Options{}, nil); err == nil {
+		t.Error("New() expected error for no path")
+	}
+}
+
+func TestKey(t *testing.T) {
+	a, err := cache.Key([]byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := cache.Key([]byte("hello world"))
+	c, _ := cache.Key([]byte("goodbye world"))
+	if a != b {
+		t.Errorf("Key() = %s for same content, want %s", b, a)
+	}
+	if a == c {
+		t.Errorf("Key() = %s for different content", c)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := cache.New(cache.Options{Path: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("ocr", "v1", "key"); ok {
+		t.Error("Get() found result before it was stored")
+	}
+	if err = c.Put("ocr", "v1", "key", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		analyzer string
+		version  string
+		key      string
+		want     string
+		wantOK   bool
+	}{
+		{"different analyzer", "tensorflow", "v1", "key", "", false},
+		{"different key", "ocr", "v1", "other", "", false},
+		{"ok", "ocr", "v1", "key", "hello world", true},
+		{"different version", "ocr", "v2", "key", "", false},
+		{"invalidated by different version", "ocr", "v1", "key", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := c.Get(tt.analyzer, tt.version, tt.key)
+			if ok != tt.wantOK {
+				t.Errorf("Get() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if c.Size() != 0 {
+		t.Errorf("Size() = %d after invalidation, want 0", c.Size())
+	}
+}
+
+func TestCache_evict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// each result is about 40 bytes, so only two fit
+	c, err := cache.New(cache.Options{Path: dir, MaxSize: 100}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("ocr", "v1", "a", "result a")
+	c.Put("ocr", "v1", "b", "result b")
+	if _, ok := c.Get("ocr", "v1", "a"); !ok {
+		t.Error("Get(a) expected result")
+	}
+	c.Put("ocr", "v1", "c", "result c")
+	if _, ok := c.Get("ocr", "v1", "b"); ok {
+		t.Error("Get(b) expected least recently used result to be evicted")
+	}
+	if _, ok := c.Get("ocr", "v1", "a"); !ok {
+		t.Error("Get(a) expected recently used result to be kept")
+	}
+	if c.Size() > 100 {
+		t.Errorf("Size() = %d, want <= %d", c.Size(), 100)
+	}
+
+	// results should be picked up by new instances
+	c2, err := cache.New(cache.Options{Path: dir, MaxSize: 100}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c2.Get("ocr", "v1", "c"); !ok || got != "result c" {
+		t.Errorf("Get(c) = %v, %v, want %v, %v", got, ok, "result c", true)
+	}
+	if c2.Size() != c.Size() {
+		t.Errorf("Size() = %d, want %d", c2.Size(), c.Size())
+	}
+}
+
+func TestCache_names(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var root = filepath.Join(dir, "root")
+
+	c, err := cache.New(cache.Options{Path: root}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names = []string{"ocr", "../escaped", "a/b", "a_b", "a%2Fb", "..", ""}
+	for _, name := range names {
+		if err = c.Put(name, "v1", "key", name); err != nil {
+			t.Fatalf("Put(%q) error = %v", name, err)
+		}
+	}
+
+	// results must stay within the cache directory, one directory per name
+	if _, err = os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("result stored outside of cache directory, error = %v", err)
+	}
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != len(names) {
+		t.Errorf("got %d cache directories, want %d", len(infos), len(names))
+	}
+	for _, name := range names {
+		if got, ok := c.Get(name, "v1", "key"); !ok || got != name {
+			t.Errorf("Get(%q) = %q (%v), want %q", name, got, ok, name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer/cache"
+)
+
+// Cached wraps the analyzer of the given registration so that its results are
+// cached, keyed by the content analyzed and the name and version of the
+// registration
+func Cached(c *cache.Cache, reg Registration) Registration {
+	var a = reg.Analyzer
+	reg.Analyzer = Func(func(ctx context.Context, in Input) (*Result, error) {
+		key, err := cache.Key(in.Content)
+		if err != nil {
+			return a.Analyze(ctx, in)
+		}
+		if cached, ok := c.Get(reg.Name, reg.Version, key); ok {
+			var result Result
+			if json.Unmarshal([]byte(cached), &result) == nil {
+				return &result, nil
+			}
+		}
+
+		result, err := a.Analyze(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		if b, err := json.Marshal(result); err == nil {
+			c.Put(reg.Name, reg.Version, key, string(b))
+		}
+		return result, nil
+	})
+	return reg
+}
@@ -0,0 +1,178 @@
+// Package code provides analysis of source code
+package code
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+)
+
+// Analyzer detects the language of source code, and separates its comments
+// from the code itself
+type Analyzer struct{}
+
+// NewAnalyzer instantiates a source code analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze indexes the code without its comments, which are recorded
+// separately. Compound identifiers, ie "parseHTTPRequest" or "max_size", are
+// split into their words, which are appended to the content so that they can
+// be searched for individually. The language is used as a tag. Content that is
+// not recognized as source code is not supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	lang, ok := Detect(in.Name, in.Content)
+	if !ok {
+		return nil, analyzer.ErrUnsupported
+	}
+	var code, comments = split(lang, string(in.Content))
+
+	// append the words of each compound identifier
+	var (
+		content strings.Builder
+		seen    = make(map[string]bool)
+	)
+	content.WriteString(strings.TrimSpace(code))
+	for _, id := range identifier.FindAllString(code, -1) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if words := Tokenize(id); len(words) > 1 {
+			content.WriteString("\n")
+			content.WriteString(strings.Join(words, " "))
+		}
+	}
+
+	return &analyzer.Result{
+		Content:    content.String(),
+		Comments:   strings.Join(comments, "\n"),
+		Tags:       []string{lang.ID},
+		Attributes: map[string]string{"language": lang.Name},
+	}, nil
+}
+
+// identifier matches identifiers in most languages
+var identifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// Tokenize splits a camelCase, PascalCase or snake_case identifier into its
+// lowercased words - acronyms and numbers are kept together, so
+// "parseHTTPRequest" becomes "parse", "http" and "request", and "base64Encode"
+// becomes "base64" and "encode"
+func Tokenize(id string) []string {
+	var words []string
+	for _, part := range strings.FieldsFunc(id, func(r rune) bool { return r == '_' || r == '-' }) {
+		var runes = []rune(part)
+		var start = 0
+		for i := 1; i < len(runes); i++ {
+			var prev, cur = runes[i-1], runes[i]
+			var boundary = (unicode.IsLower(prev) && unicode.IsUpper(cur)) ||
+				(unicode.IsDigit(prev) && unicode.IsUpper(cur)) ||
+				// end of an acronym, ie the "R" in "HTTPRequest"
+				(unicode.IsUpper(prev) && unicode.IsUpper(cur) &&
+					i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+			if boundary {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+	return words
+}
+
+// split separates source code from its comments
+func split(lang Language, src string) (code string, comments []string) {
+	var (
+		out     strings.Builder
+		comment strings.Builder
+		quote   rune
+	)
+	var flush = func() {
+		if c := strings.TrimSpace(comment.String()); c != "" {
+			comments = append(comments, c)
+		}
+		comment.Reset()
+	}
+
+	// an interpreter directive is not a comment
+	var i int
+	if strings.HasPrefix(src, "#!") {
+		if i = strings.IndexByte(src, '\n'); i < 0 {
+			i = len(src)
+		}
+		out.WriteString(src[:i])
+	}
+
+	for i < len(src) {
+		var rest = src[i:]
+		r, size := utf8.DecodeRuneInString(rest)
+
+		// inside a string literal, only look for its end
+		if quote != 0 {
+			out.WriteRune(r)
+			if r == '\\' && quote != '`' && size < len(rest) {
+				_, next := utf8.DecodeRuneInString(rest[size:])
+				out.WriteString(rest[size : size+next])
+				size += next
+			} else if r == quote || (r == '\n' && quote != '`') {
+				quote = 0
+			}
+			i += size
+			continue
+		}
+		if strings.ContainsRune(lang.Quotes, r) {
+			quote = r
+			out.WriteRune(r)
+			i += size
+			continue
+		}
+
+		// block comments run until their end marker
+		if start, end := lang.Block[0], lang.Block[1]; start != "" && strings.HasPrefix(rest, start) {
+			var body = rest[len(start):]
+			var n = strings.Index(body, end)
+			if n < 0 {
+				n = len(body)
+			}
+			comment.WriteString(strings.Trim(body[:n], "*! \t\r\n"))
+			flush()
+			i += len(start) + n + len(end)
+			if i > len(src) {
+				i = len(src)
+			}
+			continue
+		}
+
+		// line comments run until the end of the line
+		var isLine bool
+		for _, marker := range lang.Line {
+			if strings.HasPrefix(rest, marker) {
+				var n = strings.IndexByte(rest, '\n')
+				if n < 0 {
+					n = len(rest)
+				}
+				comment.WriteString(strings.TrimLeft(rest[len(marker):n], "/#! \t"))
+				comment.WriteString("\n")
+				i += n
+				isLine = true
+				break
+			}
+		}
+		if isLine {
+			continue
+		}
+
+		// consecutive line comments are kept together
+		if r != '\n' && !unicode.IsSpace(r) {
+			flush()
+		}
+		out.WriteRune(r)
+		i += size
+	}
+	flush()
+	return out.String(), comments
+}
@@ -0,0 +1,123 @@
+package code_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/code"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"by extension", "main.go", "", "go"},
+		{"by uppercase extension", "Main.JAVA", "", "java"},
+		{"not code", "README.md", "package main\n\nfunc main() {}", ""},
+		{"display name with dot", "server v1.2", "package lens\n\nfunc New() {}", "go"},
+		{"unknown extension", "build.local", "#!/bin/bash\necho hi", "shell"},
+		{"shebang", "build", "#!/bin/bash\necho hi", "shell"},
+		{"shebang with env", "", "#!/usr/bin/env python3\nprint('hi')", "python"},
+		{"unknown shebang", "", "#!/usr/bin/perl\nprint 'hi';", ""},
+		{"go", "", "package lens\n\nfunc New() {}", "go"},
+		{"python", "", "import os\n\ndef main():\n    pass\n", "python"},
+		{"c", "", "#include <stdio.h>\nint main() {}", "c"},
+		{"cpp", "", "#include <vector>\nstd::vector<int> v;", "cpp"},
+		{"solidity", "", "pragma solidity ^0.5.0;\ncontract A {}", "solidity"},
+		{"go after license", "", "// Copyright 2019\n/* Licensed\n   under MIT */\n\npackage lens\n\nfunc New() {}", "go"},
+		{"shell after comment", "", "# build script\nset -e\ngo build ./...\n", "shell"},
+		{"python after comment", "", "# utilities\nimport os\n\ndef main():\n    pass\n", "python"},
+		{"prose", "", "it was the best of times, it was the worst of times", ""},
+		{"prose quoting go", "", "To get started:\n\npackage main\n\nfunc main() {}\n", ""},
+		{"prose quoting c", "", "Include the header:\n#include <stdio.h>\n", ""},
+		{"unterminated comment", "", "/* package main\n\nfunc main() {}", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := code.Detect(tt.file, []byte(tt.content))
+			if ok != (tt.want != "") || got.ID != tt.want {
+				t.Errorf("Detect() = %v, %v, want %v", got.ID, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"simple", []string{"simple"}},
+		{"camelCase", []string{"camel", "case"}},
+		{"PascalCase", []string{"pascal", "case"}},
+		{"snake_case_name", []string{"snake", "case", "name"}},
+		{"SCREAMING_SNAKE", []string{"screaming", "snake"}},
+		{"parseHTTPRequest", []string{"parse", "http", "request"}},
+		{"HTTPServer", []string{"http", "server"}},
+		{"base64Encode", []string{"base64", "encode"}},
+		{"_private", []string{"private"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := code.Tokenize(tt.id); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      analyzer.Input
+		want    *analyzer.Result
+		wantErr error
+	}{
+		{"not code",
+			analyzer.Input{Name: "notes.txt", Content: []byte("hello world")},
+			nil, analyzer.ErrUnsupported},
+		{"go",
+			analyzer.Input{Name: "server.go", Content: []byte(`// Package server runs things
+// for a living
+package server
+
+/* newServer creates
+   a server */
+func newServer(maxConns int) string {
+	return "http://not/a/comment" // the address
+}
+`)},
+			&analyzer.Result{
+				Content: "package server\n\n\nfunc newServer(maxConns int) string {\n\treturn \"http://not/a/comment\" \n}" +
+					"\nnew server\nmax conns",
+				Comments:   "Package server runs things\nfor a living\nnewServer creates\n   a server\nthe address",
+				Tags:       []string{"go"},
+				Attributes: map[string]string{"language": "Go"},
+			}, nil},
+		{"python",
+			analyzer.Input{Content: []byte("#!/usr/bin/env python\n# greet people\ndef say_hello(name):\n    print('# not a comment', name)\n")},
+			&analyzer.Result{
+				Content:    "#!/usr/bin/env python\n\ndef say_hello(name):\n    print('# not a comment', name)\nsay hello",
+				Comments:   "greet people",
+				Tags:       []string{"python"},
+				Attributes: map[string]string{"language": "Python"},
+			}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := code.NewAnalyzer().Analyze(context.Background(), tt.in)
+			if err != tt.wantErr {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package code
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Language denotes a programming language and its comment syntax
+type Language struct {
+	// ID identifies the language, and is used as a tag for indexed code
+	ID string
+
+	// Name is the display name of the language
+	Name string
+
+	// Line are the markers of line comments
+	Line []string
+
+	// Block are the start and end markers of block comments, if the language
+	// has them
+	Block [2]string
+
+	// Quotes are the characters that delimit string literals
+	Quotes string
+}
+
+var (
+	cStyle     = [2]string{"/*", "*/"}
+	cLine      = []string{"//"}
+	scriptLine = []string{"#"}
+)
+
+// Languages are the languages that can be detected, keyed by ID
+var Languages = map[string]Language{
+	"c":          {"c", "C", cLine, cStyle, `"'`},
+	"cpp":        {"cpp", "C++", cLine, cStyle, `"'`},
+	"csharp":     {"csharp", "C#", cLine, cStyle, `"'`},
+	"go":         {"go", "Go", cLine, cStyle, "\"'`"},
+	"java":       {"java", "Java", cLine, cStyle, `"'`},
+	"javascript": {"javascript", "JavaScript", cLine, cStyle, "\"'`"},
+	"kotlin":     {"kotlin", "Kotlin", cLine, cStyle, `"'`},
+	"php":        {"php", "PHP", []string{"//", "#"}, cStyle, `"'`},
+	"python":     {"python", "Python", scriptLine, [2]string{}, `"'`},
+	"ruby":       {"ruby", "Ruby", scriptLine, [2]string{"=begin", "=end"}, `"'`},
+	"rust":       {"rust", "Rust", cLine, cStyle, `"`},
+	"scala":      {"scala", "Scala", cLine, cStyle, `"'`},
+	"shell":      {"shell", "Shell", scriptLine, [2]string{}, `"'`},
+	"solidity":   {"solidity", "Solidity", cLine, cStyle, `"'`},
+	"sql":        {"sql", "SQL", []string{"--"}, cStyle, `'`},
+	"swift":      {"swift", "Swift", cLine, cStyle, `"`},
+	"typescript": {"typescript", "TypeScript", cLine, cStyle, "\"'`"},
+}
+
+// extensions maps file extensions to language IDs
+var extensions = map[string]string{
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".cxx":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".go":    "go",
+	".java":  "java",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".kt":    "kotlin",
+	".kts":   "kotlin",
+	".php":   "php",
+	".py":    "python",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".scala": "scala",
+	".sh":    "shell",
+	".bash":  "shell",
+	".sol":   "solidity",
+	".sql":   "sql",
+	".swift": "swift",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+}
+
+// documents are the extensions of documents that may contain snippets of code,
+// but are not code themselves
+var documents = map[string]bool{
+	".md": true, ".markdown": true, ".txt": true, ".rst": true, ".html": true,
+	".htm": true, ".xml": true, ".json": true, ".csv": true, ".tsv": true,
+	".yaml": true, ".yml": true, ".srt": true, ".vtt": true,
+}
+
+// interpreters maps the interpreters of scripts to language IDs
+var interpreters = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"python":  "python",
+	"python3": "python",
+	"node":    "javascript",
+	"ruby":    "ruby",
+	"php":     "php",
+}
+
+// signatures are patterns that strongly indicate a language, in the order
+// they are checked. They are anchored to the start of the code, after any
+// leading comments, so that snippets quoted in prose are not mistaken for code.
+var signatures = []struct {
+	language string
+	pattern  *regexp.Regexp
+}{
+	{"solidity", regexp.MustCompile(`\Apragma solidity `)},
+	{"go", regexp.MustCompile(`(?m)\Apackage \w+\s*$[\s\S]*^func `)},
+	{"rust", regexp.MustCompile(`(?m)\A((use|mod|extern crate) .*\n\s*)*(pub )?fn \w+[<(][\s\S]*\blet (mut )?\w+`)},
+	{"java", regexp.MustCompile(`(?m)\A(package [\w.]+;\s*)?(import [\w.*]+;\s*)*(public |final |abstract )*class \w+[\s\S]*\b(public|private) (static )?\w+`)},
+	{"cpp", regexp.MustCompile(`(?m)\A#include <\w+>\s*$[\s\S]*\b(std::|namespace |template\s*<)`)},
+	{"c", regexp.MustCompile(`(?m)\A#include [<"][\w/]+\.h[>"]\s*$`)},
+	{"python", regexp.MustCompile(`(?m)\A(from [\w.]+ )?import \w+[\s\S]*^\s*def \w+\(.*\):\s*$`)},
+	{"php", regexp.MustCompile(`\A<\?php\b`)},
+	{"shell", regexp.MustCompile(`(?m)\A(set -[a-z]+|export \w+=\S*)\s*$`)},
+}
+
+// Detect determines the language of the given source code from its file name
+// or, if the name has no known extension, from its interpreter directive or how
+// the code begins. It returns false if the language could not be determined.
+func Detect(name string, content []byte) (Language, bool) {
+	// names may be display names rather than file names, ie "notes v1.2", so
+	// only known extensions are trusted
+	switch ext := strings.ToLower(path.Ext(name)); {
+	case extensions[ext] != "":
+		return Languages[extensions[ext]], true
+	case documents[ext]:
+		return Language{}, false
+	}
+
+	// check for an interpreter directive, ie "#!/usr/bin/env python3"
+	if bytes.HasPrefix(content, []byte("#!")) {
+		var line = string(content[2:])
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		var fields = strings.Fields(line)
+		if len(fields) > 0 {
+			var interpreter = path.Base(fields[0])
+			if interpreter == "env" && len(fields) > 1 {
+				interpreter = fields[1]
+			}
+			if id, ok := interpreters[interpreter]; ok {
+				return Languages[id], true
+			}
+		}
+	}
+
+	var code = skipComments(content)
+	for _, s := range signatures {
+		if s.pattern.Match(code) {
+			return Languages[s.language], true
+		}
+	}
+	return Language{}, false
+}
+
+// skipComments returns the given content without its leading blank lines and
+// comments
+func skipComments(content []byte) []byte {
+	for {
+		content = bytes.TrimLeft(content, " \t\r\n")
+		switch {
+		case bytes.HasPrefix(content, []byte("//")),
+			bytes.HasPrefix(content, []byte("# ")),
+			bytes.HasPrefix(content, []byte("#\n")):
+			var i = bytes.IndexByte(content, '\n')
+			if i < 0 {
+				return nil
+			}
+			content = content[i+1:]
+		case bytes.HasPrefix(content, []byte("/*")):
+			var i = bytes.Index(content, []byte("*/"))
+			if i < 0 {
+				return nil
+			}
+			content = content[i+2:]
+		default:
+			return content
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package epub provides analysis of EPUB e-books
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/html"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// MimeType is the mime type of EPUB e-books
+const MimeType = "application/epub+zip"
+
+// MaxPartSize is the maximum decompressed size of each part of a book that is
+// read, which guards against zip bombs
+const MaxPartSize = 32 << 20
+
+// Analyzer extracts chapters and metadata from EPUB e-books
+type Analyzer struct {
+	chapters *html.Analyzer
+}
+
+// NewAnalyzer instantiates an EPUB analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{html.NewAnalyzer()} }
+
+// container is the EPUB container document, which points to the package
+// document
+type container struct {
+	Rootfiles []struct {
+		Path string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// packageDocument is the OPF package document, which describes the book
+type packageDocument struct {
+	Titles    []string `xml:"metadata>title"`
+	Creators  []string `xml:"metadata>creator"`
+	Languages []string `xml:"metadata>language"`
+	Manifest  []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// Analyze extracts the text of each chapter in reading order, recording where
+// each chapter starts. Zip containers that are not EPUB books are not
+// supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	zr, err := zip.NewReader(bytes.NewReader(in.Content), int64(len(in.Content)))
+	if err != nil {
+		return nil, analyzer.ErrUnsupported
+	}
+	var files = make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	if _, ok := files["META-INF/container.xml"]; !ok {
+		return nil, analyzer.ErrUnsupported
+	}
+
+	// locate and read the package document
+	var c container
+	if err := decode(files, "META-INF/container.xml", &c); err != nil {
+		return nil, err
+	}
+	if len(c.Rootfiles) == 0 {
+		return nil, analyzer.ErrUnsupported
+	}
+	var opfPath = c.Rootfiles[0].Path
+	var opf packageDocument
+	if err := decode(files, opfPath, &opf); err != nil {
+		return nil, err
+	}
+
+	// read chapters in spine order
+	var hrefs = make(map[string]string, len(opf.Manifest))
+	for _, item := range opf.Manifest {
+		hrefs[item.ID] = item.Href
+	}
+	var (
+		content  strings.Builder
+		sections = make([]models.Section, 0, len(opf.Spine))
+		refs     []string
+	)
+	for i, ref := range opf.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		var name = path.Join(path.Dir(opfPath), href)
+		b, err := read(files, name)
+		if err != nil {
+			return nil, err
+		}
+		chapter, err := a.chapters.Analyze(ctx, analyzer.Input{
+			JobID:    in.JobID,
+			MimeType: "application/xhtml+xml",
+			Content:  b,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chapter '%s': %s", href, err.Error())
+		}
+		if chapter.Content == "" {
+			continue
+		}
+
+		var title = chapter.DisplayName
+		if len(chapter.Headings) > 0 {
+			title = chapter.Headings[0]
+		}
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		if content.Len() > 0 {
+			content.WriteString("\n\n")
+		}
+		sections = append(sections, models.Section{Title: title, Offset: content.Len()})
+		content.WriteString(chapter.Content)
+		refs = append(refs, chapter.References...)
+	}
+
+	var result = &analyzer.Result{
+		Content:    content.String(),
+		MimeType:   MimeType,
+		Sections:   sections,
+		References: refs,
+	}
+	var attrs = make(map[string]string)
+	if len(opf.Titles) > 0 {
+		result.DisplayName = strings.TrimSpace(opf.Titles[0])
+		attrs["title"] = result.DisplayName
+	}
+	if len(opf.Creators) > 0 {
+		attrs["author"] = strings.Join(trim(opf.Creators), ", ")
+	}
+	if len(opf.Languages) > 0 {
+		attrs["language"] = strings.TrimSpace(opf.Languages[0])
+	}
+	if len(attrs) > 0 {
+		result.Attributes = attrs
+	}
+	return result, nil
+}
+
+// read reads the given file from the book, limiting how much can be read
+func read(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("missing file '%s'", name)
+	}
+	if f.UncompressedSize64 > MaxPartSize {
+		return nil, fmt.Errorf("file '%s' is too large", name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(io.LimitReader(r, MaxPartSize))
+}
+
+// decode unmarshals the given XML file from the book into out
+func decode(files map[string]*zip.File, name string, out interface{}) error {
+	b, err := read(files, name)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(b, out)
+}
+
+// trim trims whitespace from the given values
+func trim(values []string) []string {
+	var out = make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.TrimSpace(v)
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package epub_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/epub"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+const containerXML = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+	<rootfiles>
+		<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+	</rootfiles>
+</container>`
+
+const packageXML = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+	<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+		<dc:title>Moby Dick</dc:title>
+		<dc:creator>Herman Melville</dc:creator>
+		<dc:creator> Another Author </dc:creator>
+		<dc:language>en</dc:language>
+	</metadata>
+	<manifest>
+		<item id="c1" href="text/chapter1.xhtml" media-type="application/xhtml+xml"/>
+		<item id="c2" href="text/chapter2.xhtml" media-type="application/xhtml+xml"/>
+		<item id="c3" href="text/chapter3.xhtml" media-type="application/xhtml+xml"/>
+		<item id="css" href="style.css" media-type="text/css"/>
+	</manifest>
+	<spine>
+		<itemref idref="c2"/>
+		<itemref idref="c1"/>
+		<itemref idref="c3"/>
+		<itemref idref="missing"/>
+	</spine>
+</package>`
+
+// newZip creates a zip container with the given files
+func newZip(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	var w = zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    *analyzer.Result
+		wantErr bool
+	}{
+		{"not a zip", nil, nil, true},
+		{"plain zip", map[string]string{"README.md": "hello"}, nil, true},
+		{"missing package document", map[string]string{
+			"META-INF/container.xml": containerXML,
+		}, nil, true},
+		{"missing chapter", map[string]string{
+			"META-INF/container.xml": containerXML,
+			"OEBPS/content.opf":      packageXML,
+		}, nil, true},
+		{"book", map[string]string{
+			"mimetype":               epub.MimeType,
+			"META-INF/container.xml": containerXML,
+			"OEBPS/content.opf":      packageXML,
+			"OEBPS/text/chapter1.xhtml": `<html><body>
+				<h1>Loomings</h1><p>Call me Ishmael.</p>
+			</body></html>`,
+			"OEBPS/text/chapter2.xhtml": `<html><head><title>Etymology</title></head><body>
+				<p>The pale Usher.</p>
+			</body></html>`,
+			"OEBPS/text/chapter3.xhtml": `<html><body><p>Untitled.</p></body></html>`,
+		}, &analyzer.Result{
+			Content:     "Etymology\nThe pale Usher.\n\nLoomings\nCall me Ishmael.\n\nUntitled.",
+			MimeType:    epub.MimeType,
+			DisplayName: "Moby Dick",
+			Sections: []models.Section{
+				{Title: "Etymology", Offset: 0},
+				{Title: "Loomings", Offset: 27},
+				{Title: "Chapter 3", Offset: 54},
+			},
+			Attributes: map[string]string{
+				"title":    "Moby Dick",
+				"author":   "Herman Melville, Another Author",
+				"language": "en",
+			},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content []byte
+			if tt.files != nil {
+				content = newZip(t, tt.files)
+			}
+			got, err := epub.NewAnalyzer().Analyze(context.Background(),
+				analyzer.Input{MimeType: "application/zip", Content: content})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
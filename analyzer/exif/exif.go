@@ -0,0 +1,332 @@
+// Package exif extracts EXIF and XMP metadata from images
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	// register decoders for image dimensions
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Metadata denotes the metadata of an image
+type Metadata struct {
+	Make        string
+	Model       string
+	Taken       time.Time
+	Width       int
+	Height      int
+	Orientation int
+
+	// GPS is the location the image was taken at, if known
+	GPS *Location
+
+	Title       string
+	Description string
+	Keywords    []string
+}
+
+// Location denotes GPS coordinates, in decimal degrees
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// dateLayout is the layout of EXIF dates
+const dateLayout = "2006:01:02 15:04:05"
+
+// Parse extracts EXIF and XMP metadata from JPEG, TIFF or HEIC images. Other
+// formats are supported if their EXIF data is stored like JPEG's, and the
+// dimensions of PNG and GIF images are always available. Missing or malformed
+// metadata is ignored.
+func Parse(content []byte) *Metadata {
+	var md = &Metadata{}
+	if tiff := findTIFF(content); tiff != nil {
+		md.parseTIFF(tiff)
+	}
+	if packet := findXMP(content); packet != nil {
+		md.parseXMP(packet)
+	}
+	if md.Width == 0 || md.Height == 0 {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(content)); err == nil {
+			md.Width, md.Height = cfg.Width, cfg.Height
+		}
+	}
+	return md
+}
+
+// Attributes formats the metadata as attributes for indexing
+func (md *Metadata) Attributes() map[string]string {
+	var attrs = make(map[string]string)
+	var set = func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			attrs[key] = value
+		}
+	}
+	set("make", md.Make)
+	set("model", md.Model)
+	var camera = md.Model
+	if md.Make != "" && !strings.HasPrefix(strings.ToLower(md.Model), strings.ToLower(md.Make)) {
+		camera = strings.TrimSpace(md.Make + " " + md.Model)
+	}
+	set("camera", camera)
+	if !md.Taken.IsZero() {
+		set("taken", md.Taken.Format("2006-01-02T15:04:05"))
+		set("year", strconv.Itoa(md.Taken.Year()))
+	}
+	if md.Width > 0 && md.Height > 0 {
+		set("width", strconv.Itoa(md.Width))
+		set("height", strconv.Itoa(md.Height))
+	}
+	if md.Orientation > 0 {
+		set("orientation", strconv.Itoa(md.Orientation))
+	}
+	if md.GPS != nil {
+		set("latitude", strconv.FormatFloat(md.GPS.Latitude, 'f', 6, 64))
+		set("longitude", strconv.FormatFloat(md.GPS.Longitude, 'f', 6, 64))
+	}
+	set("title", md.Title)
+	set("description", md.Description)
+	set("keywords", strings.Join(md.Keywords, ", "))
+	return attrs
+}
+
+// exifMarker precedes EXIF data in JPEG and HEIC images
+var exifMarker = []byte("Exif\x00\x00")
+
+// findTIFF locates the TIFF structure holding EXIF data, which is the image
+// itself for TIFF files, is stored in an APP1 segment in JPEG images, and
+// follows an "Exif" marker otherwise
+func findTIFF(content []byte) []byte {
+	if isTIFF(content) {
+		return content
+	}
+	if bytes.HasPrefix(content, []byte{0xFF, 0xD8}) {
+		return findJPEGTIFF(content)
+	}
+	for offset := 0; offset < len(content); {
+		var i = bytes.Index(content[offset:], exifMarker)
+		if i < 0 {
+			return nil
+		}
+		var tiff = content[offset+i+len(exifMarker):]
+		if isTIFF(tiff) {
+			return tiff
+		}
+		offset += i + len(exifMarker)
+	}
+	return nil
+}
+
+// findJPEGTIFF locates EXIF data in the segments of a JPEG image, so that the
+// TIFF structure does not extend into other segments
+func findJPEGTIFF(content []byte) []byte {
+	for i := 2; i+4 <= len(content) && content[i] == 0xFF; {
+		var marker = content[i+1]
+		if marker == 0xDA {
+			// image data follows the start of scan
+			return nil
+		}
+		var end = i + 2 + int(binary.BigEndian.Uint16(content[i+2:]))
+		if end > len(content) {
+			return nil
+		}
+		var segment = content[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifMarker) && isTIFF(segment[len(exifMarker):]) {
+			return segment[len(exifMarker):]
+		}
+		i = end
+	}
+	return nil
+}
+
+func isTIFF(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*"))
+}
+
+// EXIF tags that are extracted
+const (
+	tagImageWidth       = 0x0100
+	tagImageLength      = 0x0101
+	tagImageDescription = 0x010E
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagPixelXDimension  = 0xA002
+	tagPixelYDimension  = 0xA003
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+)
+
+// maxEntries limits the number of entries read from each IFD
+const maxEntries = 512
+
+// entry is an IFD entry
+type entry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// typeSizes are the sizes of each EXIF value type, indexed by type
+var typeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+var errMalformed = errors.New("malformed tiff structure")
+
+// tiffReader reads IFDs from a TIFF structure
+type tiffReader struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func (md *Metadata) parseTIFF(b []byte) {
+	if len(b) < 8 {
+		return
+	}
+	var r = &tiffReader{b: b, order: binary.LittleEndian}
+	if b[0] == 'M' {
+		r.order = binary.BigEndian
+	}
+	ifd0, err := r.ifd(r.order.Uint32(b[4:8]))
+	if err != nil {
+		return
+	}
+	md.Make = r.ascii(ifd0[tagMake])
+	md.Model = r.ascii(ifd0[tagModel])
+	md.Description = r.ascii(ifd0[tagImageDescription])
+	md.Orientation = r.uint(ifd0[tagOrientation])
+	md.Width = r.uint(ifd0[tagImageWidth])
+	md.Height = r.uint(ifd0[tagImageLength])
+	md.Taken, _ = time.Parse(dateLayout, r.ascii(ifd0[tagDateTime]))
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if exif, err := r.ifd(uint32(r.uint(e))); err == nil {
+			if taken, err := time.Parse(dateLayout, r.ascii(exif[tagDateTimeOriginal])); err == nil {
+				md.Taken = taken
+			}
+			if w, h := r.uint(exif[tagPixelXDimension]), r.uint(exif[tagPixelYDimension]); w > 0 && h > 0 {
+				md.Width, md.Height = w, h
+			}
+		}
+	}
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		if gps, err := r.ifd(uint32(r.uint(e))); err == nil {
+			md.GPS = r.location(gps)
+		}
+	}
+}
+
+// ifd reads the entries of the IFD at the given offset
+func (r *tiffReader) ifd(offset uint32) (map[uint16]entry, error) {
+	if int64(offset)+2 > int64(len(r.b)) {
+		return nil, errMalformed
+	}
+	var n = int(r.order.Uint16(r.b[offset:]))
+	if n > maxEntries {
+		return nil, errMalformed
+	}
+	var entries = make(map[uint16]entry, n)
+	for i := 0; i < n; i++ {
+		var start = int64(offset) + 2 + int64(i)*12
+		if start+12 > int64(len(r.b)) {
+			return nil, errMalformed
+		}
+		var raw = r.b[start : start+12]
+		var e = entry{
+			typ:   r.order.Uint16(raw[2:4]),
+			count: r.order.Uint32(raw[4:8]),
+		}
+		if int(e.typ) >= len(typeSizes) || e.typ == 0 {
+			continue
+		}
+		var size = int64(typeSizes[e.typ]) * int64(e.count)
+		if size <= 4 {
+			e.value = raw[8 : 8+size]
+		} else {
+			var at = int64(r.order.Uint32(raw[8:12]))
+			if at+size > int64(len(r.b)) {
+				continue
+			}
+			e.value = r.b[at : at+size]
+		}
+		entries[r.order.Uint16(raw[0:2])] = e
+	}
+	return entries, nil
+}
+
+// ascii reads a string value
+func (r *tiffReader) ascii(e entry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	if i := bytes.IndexByte(e.value, 0); i >= 0 {
+		return strings.TrimSpace(string(e.value[:i]))
+	}
+	return strings.TrimSpace(string(e.value))
+}
+
+// uint reads the first value of an unsigned integer entry
+func (r *tiffReader) uint(e entry) int {
+	switch {
+	case e.typ == 1 && len(e.value) >= 1:
+		return int(e.value[0])
+	case e.typ == 3 && len(e.value) >= 2:
+		return int(r.order.Uint16(e.value))
+	case e.typ == 4 && len(e.value) >= 4:
+		return int(r.order.Uint32(e.value))
+	}
+	return 0
+}
+
+// rationals reads the values of an unsigned rational entry
+func (r *tiffReader) rationals(e entry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	var out = make([]float64, 0, e.count)
+	for i := 0; i+8 <= len(e.value); i += 8 {
+		var num, den = r.order.Uint32(e.value[i:]), r.order.Uint32(e.value[i+4:])
+		if den == 0 {
+			return nil
+		}
+		out = append(out, float64(num)/float64(den))
+	}
+	return out
+}
+
+// location reads coordinates from a GPS IFD
+func (r *tiffReader) location(gps map[uint16]entry) *Location {
+	var lat, lon = r.rationals(gps[tagGPSLatitude]), r.rationals(gps[tagGPSLongitude])
+	if len(lat) != 3 || len(lon) != 3 {
+		return nil
+	}
+	var loc = &Location{
+		Latitude:  lat[0] + lat[1]/60 + lat[2]/3600,
+		Longitude: lon[0] + lon[1]/60 + lon[2]/3600,
+	}
+	if strings.EqualFold(r.ascii(gps[tagGPSLatitudeRef]), "S") {
+		loc.Latitude = -loc.Latitude
+	}
+	if strings.EqualFold(r.ascii(gps[tagGPSLongitudeRef]), "W") {
+		loc.Longitude = -loc.Longitude
+	}
+	if math.Abs(loc.Latitude) > 90 || math.Abs(loc.Longitude) > 180 {
+		return nil
+	}
+	return loc
+}
@@ -0,0 +1,242 @@
+package exif_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer/exif"
+)
+
+// field is a TIFF IFD entry
+type field struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func ascii(tag uint16, s string) field {
+	return field{tag, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func short(o binary.ByteOrder, tag uint16, v uint16) field {
+	var b = make([]byte, 2)
+	o.PutUint16(b, v)
+	return field{tag, 3, 1, b}
+}
+
+func long(o binary.ByteOrder, tag uint16, v uint32) field {
+	var b = make([]byte, 4)
+	o.PutUint32(b, v)
+	return field{tag, 4, 1, b}
+}
+
+func rationals(o binary.ByteOrder, tag uint16, values ...uint32) field {
+	var b = make([]byte, 4*len(values))
+	for i, v := range values {
+		o.PutUint32(b[i*4:], v)
+	}
+	return field{tag, 5, uint32(len(values) / 2), b}
+}
+
+// ifd encodes an IFD at the given offset, followed by its out-of-line values
+func ifd(o binary.ByteOrder, offset uint32, fields []field) []byte {
+	var head, data bytes.Buffer
+	var dataOffset = offset + 2 + 12*uint32(len(fields)) + 4
+	binary.Write(&head, o, uint16(len(fields)))
+	for _, f := range fields {
+		binary.Write(&head, o, f.tag)
+		binary.Write(&head, o, f.typ)
+		binary.Write(&head, o, f.count)
+		if len(f.data) <= 4 {
+			var v = make([]byte, 4)
+			copy(v, f.data)
+			head.Write(v)
+		} else {
+			binary.Write(&head, o, dataOffset+uint32(data.Len()))
+			data.Write(f.data)
+			if data.Len()%2 == 1 {
+				data.WriteByte(0)
+			}
+		}
+	}
+	binary.Write(&head, o, uint32(0))
+	return append(head.Bytes(), data.Bytes()...)
+}
+
+// newTIFF encodes a TIFF structure with the given IFD0, EXIF and GPS entries
+func newTIFF(o binary.ByteOrder, ifd0, exifIFD, gps []field) []byte {
+	var header = []byte("II*\x00\x08\x00\x00\x00")
+	if o == binary.BigEndian {
+		header = []byte("MM\x00*\x00\x00\x00\x08")
+	}
+	var pointers = func(exifOffset, gpsOffset uint32) []field {
+		var fields = append([]field{}, ifd0...)
+		if exifIFD != nil {
+			fields = append(fields, long(o, 0x8769, exifOffset))
+		}
+		if gps != nil {
+			fields = append(fields, long(o, 0x8825, gpsOffset))
+		}
+		return fields
+	}
+	var exifOffset = 8 + uint32(len(ifd(o, 8, pointers(0, 0))))
+	var exifBytes []byte
+	if exifIFD != nil {
+		exifBytes = ifd(o, exifOffset, exifIFD)
+	}
+	var gpsOffset = exifOffset + uint32(len(exifBytes))
+	var out = append(header, ifd(o, 8, pointers(exifOffset, gpsOffset))...)
+	out = append(out, exifBytes...)
+	if gps != nil {
+		out = append(out, ifd(o, gpsOffset, gps)...)
+	}
+	return out
+}
+
+// newJPEG encodes a JPEG with the given APP1 segments
+func newJPEG(t *testing.T, segments ...[]byte) []byte {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, image.NewGray(image.Rect(0, 0, 4, 3)), nil); err != nil {
+		t.Fatal(err)
+	}
+	var out = append([]byte{}, b.Bytes()[:2]...)
+	for _, s := range segments {
+		out = append(out, 0xFF, 0xE1, byte((len(s)+2)>>8), byte(len(s)+2))
+		out = append(out, s...)
+	}
+	return append(out, b.Bytes()[2:]...)
+}
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+	<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+		<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+			<dc:subject><rdf:Bag><rdf:li>beach</rdf:li><rdf:li>sunset</rdf:li></rdf:Bag></dc:subject>
+			<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Kitsilano</rdf:li></rdf:Alt></dc:title>
+		</rdf:Description>
+	</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestParse(t *testing.T) {
+	var (
+		le    = binary.LittleEndian
+		be    = binary.BigEndian
+		taken = time.Date(2018, 7, 1, 18, 30, 0, 0, time.UTC)
+		photo = newTIFF(le,
+			[]field{
+				ascii(0x010F, "Canon"),
+				ascii(0x0110, "Canon EOS 5D"),
+				short(le, 0x0112, 6),
+				ascii(0x010E, "a day at the beach"),
+				ascii(0x0132, "2019:01:01 00:00:00"),
+			},
+			[]field{
+				ascii(0x9003, "2018:07:01 18:30:00"),
+				long(le, 0xA002, 4000),
+				long(le, 0xA003, 3000),
+			},
+			[]field{
+				ascii(0x0001, "N"),
+				rationals(le, 0x0002, 49, 1, 16, 1, 12, 1),
+				ascii(0x0003, "W"),
+				rationals(le, 0x0004, 123, 1, 9, 1, 36, 1),
+			})
+	)
+	var png1x2 bytes.Buffer
+	png.Encode(&png1x2, image.NewGray(image.Rect(0, 0, 1, 2)))
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    *exif.Metadata
+	}{
+		{"not an image", []byte("hello world"), &exif.Metadata{}},
+		{"png dimensions", png1x2.Bytes(), &exif.Metadata{Width: 1, Height: 2}},
+		{"jpeg without metadata", newJPEG(t), &exif.Metadata{Width: 4, Height: 3}},
+		{"jpeg with exif and xmp", newJPEG(t,
+			append([]byte("Exif\x00\x00"), photo...),
+			append([]byte("http://ns.adobe.com/xap/1.0/\x00"), testXMP...),
+		), &exif.Metadata{
+			Make:        "Canon",
+			Model:       "Canon EOS 5D",
+			Taken:       taken,
+			Width:       4000,
+			Height:      3000,
+			Orientation: 6,
+			GPS:         &exif.Location{Latitude: 49.27, Longitude: -123.16},
+			Title:       "Kitsilano",
+			Description: "a day at the beach",
+			Keywords:    []string{"beach", "sunset"},
+		}},
+		{"big-endian tiff", newTIFF(be,
+			[]field{
+				ascii(0x0110, "scanner"),
+				short(be, 0x0100, 640),
+				long(be, 0x0101, 480),
+			}, nil, nil,
+		), &exif.Metadata{Model: "scanner", Width: 640, Height: 480}},
+		{"heic exif item", append(
+			[]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic\x00\x00\x00\x06Exif\x00\x00"),
+			newTIFF(be, []field{ascii(0x0110, "iPhone XS")}, nil, nil)...,
+		), &exif.Metadata{Model: "iPhone XS"}},
+		{"truncated exif", newJPEG(t,
+			append([]byte("Exif\x00\x00"), photo[:40]...),
+		), &exif.Metadata{Width: 4, Height: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = exif.Parse(tt.content)
+			if got.GPS != nil && tt.want.GPS != nil {
+				// compare coordinates approximately
+				if d := got.GPS.Latitude - tt.want.GPS.Latitude; d > 0.001 || d < -0.001 {
+					t.Errorf("Parse() latitude = %f, want %f", got.GPS.Latitude, tt.want.GPS.Latitude)
+				}
+				if d := got.GPS.Longitude - tt.want.GPS.Longitude; d > 0.001 || d < -0.001 {
+					t.Errorf("Parse() longitude = %f, want %f", got.GPS.Longitude, tt.want.GPS.Longitude)
+				}
+				got.GPS, tt.want.GPS = nil, nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadata_Attributes(t *testing.T) {
+	var md = &exif.Metadata{
+		Make:     "Canon",
+		Model:    "EOS 5D",
+		Taken:    time.Date(2018, 7, 1, 18, 30, 0, 0, time.UTC),
+		Width:    4000,
+		Height:   3000,
+		GPS:      &exif.Location{Latitude: 49.27, Longitude: -123.16},
+		Keywords: []string{"beach", "sunset"},
+	}
+	var want = map[string]string{
+		"make":      "Canon",
+		"model":     "EOS 5D",
+		"camera":    "Canon EOS 5D",
+		"taken":     "2018-07-01T18:30:00",
+		"year":      "2018",
+		"width":     "4000",
+		"height":    "3000",
+		"latitude":  "49.270000",
+		"longitude": "-123.160000",
+		"keywords":  "beach, sunset",
+	}
+	if got := md.Attributes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Attributes() = %v, want %v", got, want)
+	}
+	if got := (&exif.Metadata{}).Attributes(); len(got) != 0 {
+		t.Errorf("Attributes() = %v, want none", got)
+	}
+}
@@ -0,0 +1,72 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// findXMP locates the XMP packet embedded in the image, if there is one
+func findXMP(content []byte) []byte {
+	var start = bytes.Index(content, []byte("<x:xmpmeta"))
+	if start < 0 {
+		return nil
+	}
+	var end = bytes.Index(content[start:], []byte("</x:xmpmeta>"))
+	if end < 0 {
+		return nil
+	}
+	return content[start : start+end+len("</x:xmpmeta>")]
+}
+
+// parseXMP extracts Dublin Core properties from an XMP packet - keywords are
+// stored as dc:subject, and the title and description are language
+// alternatives, of which the first is used
+func (md *Metadata) parseXMP(packet []byte) {
+	const dc = "http://purl.org/dc/elements/1.1/"
+	var (
+		dec      = xml.NewDecoder(bytes.NewReader(packet))
+		property string
+		text     strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == dc {
+				property = t.Name.Local
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if t.Name.Local == "li" && property != "" {
+				md.setXMP(property, strings.TrimSpace(text.String()))
+			} else if t.Name.Space == dc {
+				property = ""
+			}
+			text.Reset()
+		}
+	}
+}
+
+func (md *Metadata) setXMP(property, value string) {
+	if value == "" {
+		return
+	}
+	switch property {
+	case "subject":
+		md.Keywords = append(md.Keywords, value)
+	case "title":
+		if md.Title == "" {
+			md.Title = value
+		}
+	case "description":
+		if md.Description == "" {
+			md.Description = value
+		}
+	}
+}
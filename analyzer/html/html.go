@@ -0,0 +1,199 @@
+// Package html provides analysis of HTML documents
+package html
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+)
+
+// Analyzer extracts text and metadata from HTML documents
+type Analyzer struct{}
+
+// NewAnalyzer instantiates an HTML analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze strips markup from the document, and extracts its title, meta
+// description and keywords, headings, and links to IPFS content
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	root, err := xhtml.Parse(bytes.NewReader(in.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	var d = &document{seen: make(map[string]bool)}
+	d.walk(root)
+
+	var result = &analyzer.Result{
+		Content:     strings.TrimSpace(d.text.String()),
+		DisplayName: d.title,
+		Tags:        d.keywords,
+		Headings:    d.headings,
+		References:  d.refs,
+	}
+	if d.title != "" {
+		result.Content = d.title + "\n" + result.Content
+	}
+	if d.description != "" {
+		result.Tags = append(result.Tags, d.description)
+		result.Attributes = map[string]string{"description": d.description}
+	}
+	return result, nil
+}
+
+// document accumulates the contents of a parsed HTML document
+type document struct {
+	text        strings.Builder
+	title       string
+	description string
+	keywords    []string
+	headings    []string
+	refs        []string
+	seen        map[string]bool
+
+	// newline is set if the text ends a line
+	newline bool
+}
+
+func (d *document) walk(n *xhtml.Node) {
+	switch n.Type {
+	case xhtml.TextNode:
+		d.write(n.Data)
+		return
+	case xhtml.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Template:
+			return
+		case atom.Title:
+			if d.title == "" {
+				d.title = collapse(textOf(n))
+			}
+			return
+		case atom.Meta:
+			d.meta(n)
+		case atom.A:
+			if ref, ok := Reference(attr(n, "href")); ok && !d.seen[ref] {
+				d.seen[ref] = true
+				d.refs = append(d.refs, ref)
+			}
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			if heading := collapse(textOf(n)); heading != "" {
+				d.headings = append(d.headings, heading)
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		d.walk(c)
+	}
+	if n.Type == xhtml.ElementNode && isBlock(n.DataAtom) {
+		d.breakLine()
+	}
+}
+
+// meta records the description and keywords meta tags
+func (d *document) meta(n *xhtml.Node) {
+	var content = collapse(attr(n, "content"))
+	switch strings.ToLower(attr(n, "name")) {
+	case "description":
+		d.description = content
+	case "keywords":
+		for _, k := range strings.Split(content, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				d.keywords = append(d.keywords, k)
+			}
+		}
+	}
+}
+
+// write appends text to the document, collapsing whitespace
+func (d *document) write(s string) {
+	var words = strings.Fields(s)
+	if len(words) == 0 {
+		return
+	}
+	if d.text.Len() > 0 && !d.newline {
+		d.text.WriteByte(' ')
+	}
+	d.text.WriteString(strings.Join(words, " "))
+	d.newline = false
+}
+
+// breakLine ends the current line of text, if there is one
+func (d *document) breakLine() {
+	if d.text.Len() > 0 && !d.newline {
+		d.text.WriteByte('\n')
+		d.newline = true
+	}
+}
+
+// isBlock checks if the element separates its contents from surrounding text
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Br, atom.Li, atom.Tr, atom.Table, atom.Section,
+		atom.Article, atom.Header, atom.Footer, atom.Blockquote, atom.Pre,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
+// textOf returns the text within the given node
+func textOf(n *xhtml.Node) string {
+	if n.Type == xhtml.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textOf(c))
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// attr returns the value of the given attribute of the node
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// collapse trims and collapses whitespace in the given text
+func collapse(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+// Reference extracts the CID from links to IPFS content, which may be
+// IPFS URIs, IPFS paths, or links to IPFS gateways
+func Reference(href string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	var candidate string
+	switch {
+	case strings.EqualFold(u.Scheme, "ipfs"):
+		// ipfs://<cid>/path
+		candidate = u.Host
+	case strings.HasPrefix(u.Path, "/ipfs/"):
+		// /ipfs/<cid>/path, on any host
+		candidate = strings.SplitN(strings.TrimPrefix(u.Path, "/ipfs/"), "/", 2)[0]
+	case strings.Contains(u.Hostname(), ".ipfs."):
+		// <cid>.ipfs.<gateway>
+		candidate = strings.SplitN(u.Hostname(), ".", 2)[0]
+	default:
+		return "", false
+	}
+	id, err := gocid.Decode(candidate)
+	if err != nil {
+		return "", false
+	}
+	return id.String(), true
+}
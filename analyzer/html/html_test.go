@@ -0,0 +1,75 @@
+package html_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/html"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>  Temporal
+		Docs </title>
+	<meta name="description" content="Documentation for Temporal">
+	<meta name="Keywords" content="ipfs, storage,, api ">
+	<style>body { color: red; }</style>
+	<script>var secret = "do not index";</script>
+</head>
+<body>
+	<h1>Getting <em>Started</em></h1>
+	<p>Upload files to   <a href="https://gateway.temporal.cloud/ipfs/QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW/readme">IPFS</a>.</p>
+	<noscript>enable javascript</noscript>
+	<h2>Links</h2>
+	<ul>
+		<li><a href="ipfs://bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme">v1</a></li>
+		<li><a href="/ipfs/QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW">duplicate</a></li>
+		<li><a href="https://bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme.ipfs.dweb.link">subdomain</a></li>
+		<li><a href="https://temporal.cloud/ipfs/not-a-cid">invalid</a></li>
+		<li><a href="https://temporal.cloud">external</a></li>
+	</ul>
+</body>
+</html>`
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *analyzer.Result
+	}{
+		{"empty", "", &analyzer.Result{}},
+		{"plain text", "hello   world", &analyzer.Result{Content: "hello world"}},
+		{"page", testPage, &analyzer.Result{
+			Content: "Temporal Docs\n" +
+				"Getting Started\n" +
+				"Upload files to IPFS .\n" +
+				"Links\n" +
+				"v1\nduplicate\nsubdomain\ninvalid\nexternal",
+			DisplayName: "Temporal Docs",
+			Tags:        []string{"ipfs", "storage", "api", "Documentation for Temporal"},
+			Headings:    []string{"Getting Started", "Links"},
+			References: []string{
+				"QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW",
+				"bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme",
+			},
+			Attributes: map[string]string{"description": "Documentation for Temporal"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := html.NewAnalyzer().Analyze(context.Background(), analyzer.Input{
+				MimeType: "text/html; charset=utf-8",
+				Content:  []byte(tt.content),
+			})
+			if err != nil {
+				t.Fatalf("Analyzer.Analyze() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyzer.Analyze() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/exif"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// ImageAnalyzer classifies images, and extracts any text in them
+type ImageAnalyzer struct {
+	tf   TensorflowAnalyzer
+	text analyzer.Analyzer
+
+	l *zap.SugaredLogger
+}
+
+// NewImageAnalyzer instantiates an image analyzer that classifies images with
+// the given TensorFlow analyzer, and extracts text with the given text analyzer
+// (ie OCR) if one is provided
+func NewImageAnalyzer(tf TensorflowAnalyzer, text analyzer.Analyzer, logger *zap.SugaredLogger) *ImageAnalyzer {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	return &ImageAnalyzer{tf, text, logger}
+}
+
+// Analyze classifies the image, and uses its labels as tags, also recording
+// them with their confidence so that searches can prefer confident matches.
+// Text in the image is used as content if any is found, otherwise the labels
+// are used instead. EXIF and XMP metadata, such as the camera, capture date and
+// location, are recorded as attributes, and XMP keywords are also used as tags.
+// Images that can't be classified, ie formats such as TIFF and HEIC that can't
+// be decoded, are still indexed by their text and metadata if they have any.
+func (a *ImageAnalyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	var l = a.l.With("job_id", in.JobID)
+	labels, classifyErr := a.tf.Analyze(in.JobID, in.Content)
+	if classifyErr != nil {
+		l.Warnw("failed to categorize image", "error", classifyErr)
+	}
+	var (
+		names    = make([]string, 0, len(labels))
+		weighted map[string]float64
+	)
+	for _, label := range labels {
+		var name = strings.ToLower(strings.TrimSpace(label.Name))
+		if name == "" {
+			continue
+		}
+		if weighted == nil {
+			weighted = make(map[string]float64, len(labels))
+		}
+		names = append(names, name)
+		weighted[name] = float64(label.Confidence)
+	}
+
+	// grab any text in image
+	var content = strings.Join(names, ", ")
+	if a.text != nil {
+		if text, err := a.text.Analyze(ctx, in); err != nil {
+			l.Warnw("failed to extract text from image", "error", err)
+		} else {
+			content = text.Content
+		}
+	}
+
+	var result = &analyzer.Result{
+		Content: content,
+		Labels:  weighted,
+	}
+	if len(names) > 0 {
+		result.Tags = names
+	}
+
+	// record embedded metadata
+	var md = exif.Parse(in.Content)
+	for _, s := range []string{md.Title, md.Description} {
+		if s == "" {
+			continue
+		}
+		if result.Content != "" {
+			result.Content += "\n"
+		}
+		result.Content += s
+	}
+	result.DisplayName = md.Title
+	result.Tags = append(result.Tags, md.Keywords...)
+	if attrs := md.Attributes(); len(attrs) > 0 {
+		result.Attributes = attrs
+	}
+	if md.GPS != nil {
+		result.Location = &models.GeoPoint{Lat: md.GPS.Latitude, Lon: md.GPS.Longitude}
+	}
+
+	// without a classification, images are only worth indexing if something
+	// else was found - dimensions alone don't describe an image
+	if classifyErr != nil && strings.TrimSpace(result.Content) == "" &&
+		len(result.Tags) == 0 && !describes(result.Attributes) {
+		return nil, errors.New("failed to categorize image")
+	}
+	return result, nil
+}
+
+// describes checks if the given attributes describe more than the dimensions of
+// an image
+func describes(attrs map[string]string) bool {
+	for k := range attrs {
+		switch k {
+		case "width", "height", "orientation":
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package images_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/images"
+	"github.com/RTradeLtd/Lens/v2/mocks"
+)
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title><rdf:Alt><rdf:li xml:lang="x-default">Harbour</rdf:li></rdf:Alt></dc:title>
+<dc:subject><rdf:Bag><rdf:li>boats</rdf:li><rdf:li>sunset</rdf:li></rdf:Bag></dc:subject>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`
+
+func TestImageAnalyzer_Analyze(t *testing.T) {
+	var (
+		testLabels = []images.Label{{Name: "Tabby Cat", Confidence: 0.5}, {Name: "boat", Confidence: 0.25}}
+		wantTags   = []string{"tabby cat", "boat"}
+		wantLabels = map[string]float64{"tabby cat": 0.5, "boat": 0.25}
+	)
+	type returns struct {
+		labels    []images.Label
+		tensorErr error
+		text      string
+		textErr   error
+	}
+	tests := []struct {
+		name        string
+		noText      bool
+		content     string
+		returns     returns
+		wantContent string
+		wantTags    []string
+		wantLabels  map[string]float64
+		wantAttrs   map[string]string
+		wantErr     bool
+	}{
+		{"classification error", false, "image", returns{nil, errors.New("oh no"), "", nil},
+			"", nil, nil, nil, true},
+		{"ok: classification error with text", false, "image", returns{nil, errors.New("oh no"), "hello world", nil},
+			"hello world", nil, nil, nil, false},
+		{"ok: classification error with metadata", true, "image" + testXMP, returns{nil, errors.New("oh no"), "", nil},
+			"Harbour", []string{"boats", "sunset"}, nil,
+			map[string]string{"title": "Harbour", "keywords": "boats, sunset"}, false},
+		{"ok: no text analyzer", true, "image", returns{testLabels, nil, "", nil},
+			"tabby cat, boat", wantTags, wantLabels, nil, false},
+		{"ok: no labels", true, "image", returns{nil, nil, "", nil},
+			"", nil, nil, nil, false},
+		{"ok: text error", false, "image", returns{testLabels, nil, "", errors.New("oh no")},
+			"tabby cat, boat", wantTags, wantLabels, nil, false},
+		{"ok: with text", false, "image", returns{testLabels, nil, "hello world", nil},
+			"hello world", wantTags, wantLabels, nil, false},
+		{"ok: with metadata", true, "image" + testXMP, returns{testLabels, nil, "", nil},
+			"tabby cat, boat\nHarbour", []string{"tabby cat", "boat", "boats", "sunset"}, wantLabels,
+			map[string]string{"title": "Harbour", "keywords": "boats, sunset"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tensor = &mocks.FakeTensorflowAnalyzer{}
+			tensor.AnalyzeReturns(tt.returns.labels, tt.returns.tensorErr)
+			var text analyzer.Analyzer = analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+				if tt.returns.textErr != nil {
+					return nil, tt.returns.textErr
+				}
+				return &analyzer.Result{Content: tt.returns.text}, nil
+			})
+			if tt.noText {
+				text = nil
+			}
+
+			var a = images.NewImageAnalyzer(tensor, text, nil)
+			got, err := a.Analyze(context.Background(), analyzer.Input{Content: []byte(tt.content)})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ImageAnalyzer.Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Content != tt.wantContent {
+				t.Errorf("ImageAnalyzer.Analyze() content = %v, want %v", got.Content, tt.wantContent)
+			}
+			if !reflect.DeepEqual(got.Tags, tt.wantTags) {
+				t.Errorf("ImageAnalyzer.Analyze() tags = %v, want %v", got.Tags, tt.wantTags)
+			}
+			if !reflect.DeepEqual(got.Labels, tt.wantLabels) {
+				t.Errorf("ImageAnalyzer.Analyze() labels = %v, want %v", got.Labels, tt.wantLabels)
+			}
+			if !reflect.DeepEqual(got.Attributes, tt.wantAttrs) {
+				t.Errorf("ImageAnalyzer.Analyze() attributes = %v, want %v", got.Attributes, tt.wantAttrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// TensorflowAnalyzer represents a wrapper around a Tensorflow-based analyzer
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -o ../../mocks/images.mock.go github.com/RTradeLtd/Lens/v2/analyzer/images.TensorflowAnalyzer
+type TensorflowAnalyzer interface {
+	// Analyze classifies an image, returning its most likely labels, most
+	// confident first
+	Analyze(jobID string, content []byte) (labels []Label, err error)
+
+	// Version identifies the model used for analysis, and changes whenever the
+	// model does
+	Version() string
+}
+
+// Label denotes a classification of an image
+type Label struct {
+	Name string
+
+	// Confidence is the probability of the label, between 0 and 1
+	Confidence float32
+}
+
+// Defaults for classification, used if they are not configured
+const (
+	DefaultTopK          = 5
+	DefaultMinConfidence = 0.1
+)
+
+// ConfigOpts is used to configure our image analyzer
+type ConfigOpts struct {
+	// Classifier selects the classifier used by NewClassifier - see
+	// ClassifierTensorflow and ClassifierHeuristic
+	Classifier string `json:"classifier"`
+
+	// ModelLocation is the directory models are loaded from - models are
+	// never downloaded, see Fetch
+	ModelLocation string `json:"model_location"`
+
+	// Model is the name of the registered model to use, Inception5h if not set
+	Model string `json:"model"`
+
+	// TopK is the maximum number of labels reported for an image, and
+	// MinConfidence is the lowest confidence of a reported label - see
+	// DefaultTopK and DefaultMinConfidence
+	TopK          int     `json:"top_k"`
+	MinConfidence float32 `json:"min_confidence"`
+}
+
+// Classifiers available to NewClassifier
+const (
+	// ClassifierTensorflow classifies images with a TensorFlow model, see
+	// NewAnalyzer
+	ClassifierTensorflow = "tensorflow"
+
+	// ClassifierHeuristic classifies images by their colors, shape and text
+	// density, without TensorFlow - see NewHeuristicAnalyzer
+	ClassifierHeuristic = "heuristic"
+)
+
+// ErrTensorflowUnavailable is returned by NewAnalyzer in builds without
+// TensorFlow, which are built with the notensorflow tag
+var ErrTensorflowUnavailable = errors.New("images: built without TensorFlow support")
+
+// NewClassifier creates the classifier selected by opts.Classifier. If no
+// classifier is selected, TensorFlow is used if this build supports it, and
+// the heuristic classifier otherwise.
+func NewClassifier(opts ConfigOpts, logger *zap.SugaredLogger) (TensorflowAnalyzer, error) {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	switch opts.Classifier {
+	case "":
+		if !tensorflowAvailable {
+			logger.Warnw("built without TensorFlow - images are classified heuristically",
+				"classifier", ClassifierHeuristic)
+			return NewHeuristicAnalyzer(opts, logger), nil
+		}
+		return newTensorflowClassifier(opts, logger)
+	case ClassifierTensorflow:
+		return newTensorflowClassifier(opts, logger)
+	case ClassifierHeuristic:
+		return NewHeuristicAnalyzer(opts, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown image classifier %q - available classifiers are %s, %s",
+			opts.Classifier, ClassifierTensorflow, ClassifierHeuristic)
+	}
+}
+
+// newTensorflowClassifier wraps NewAnalyzer, so that failures do not return a
+// non-nil TensorflowAnalyzer
+func newTensorflowClassifier(opts ConfigOpts, logger *zap.SugaredLogger) (TensorflowAnalyzer, error) {
+	a, err := NewAnalyzer(opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,55 @@
+package images_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer/images"
+)
+
+const (
+	testImg = "../../test/assets/image.jpg"
+	testPNG = "../../test/assets/text.png"
+)
+
+func TestNewClassifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens-models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name        string
+		classifier  string
+		wantVersion string
+		wantErr     bool
+	}{
+		{"heuristic", images.ClassifierHeuristic, "heuristic-1-top3-0.5", false},
+		{"tensorflow without model", images.ClassifierTensorflow, "", true},
+		{"unknown", "magic", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := images.NewClassifier(images.ConfigOpts{
+				Classifier:    tt.classifier,
+				ModelLocation: dir,
+				TopK:          3,
+				MinConfidence: 0.5,
+			}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClassifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("NewClassifier() = %v, want nil on error", got)
+				}
+				return
+			}
+			if v := got.Version(); v != tt.wantVersion {
+				t.Errorf("NewClassifier().Version() = %v, want %v", v, tt.wantVersion)
+			}
+		})
+	}
+}
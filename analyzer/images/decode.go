@@ -0,0 +1,53 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+
+	// register supported image formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels is the largest image, in pixels, that will be decoded
+const maxPixels = 64 << 20
+
+// decodeRGB decodes a JPEG, PNG, GIF, BMP or WebP image into its RGB pixels,
+// row by row, returning them along with the image's dimensions. Only the first
+// frame of animated images is decoded, and transparent pixels are blended onto
+// a white background.
+func decodeRGB(content []byte) (pixels []byte, width, height int, err error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, 0, 0, errors.New("image has no pixels")
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, 0, 0, fmt.Errorf("%dx%d %s image is too large to decode",
+			cfg.Width, cfg.Height, format)
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode %s image: %v", format, err)
+	}
+
+	var bounds = img.Bounds()
+	var rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Over)
+
+	pixels = make([]byte, 0, 3*bounds.Dx()*bounds.Dy())
+	for i := 0; i+3 < len(rgba.Pix); i += 4 {
+		pixels = append(pixels, rgba.Pix[i:i+3]...)
+	}
+	return pixels, bounds.Dx(), bounds.Dy(), nil
+}
@@ -0,0 +1,104 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+// testWebP is a lossless 1x1 WebP image
+const testWebP = "RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00"
+
+func Test_decodeRGB(t *testing.T) {
+	textPNG, err := ioutil.ReadFile("../../test/assets/text.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a transparent red pixel and a half transparent black pixel
+	var transparent = image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	transparent.Set(0, 0, color.NRGBA{255, 0, 0, 0})
+	transparent.Set(1, 0, color.NRGBA{0, 0, 0, 128})
+
+	// an animation of a red frame followed by a blue one
+	var palette = color.Palette{color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	var red, blue = image.NewPaletted(image.Rect(0, 0, 2, 2), palette), image.NewPaletted(image.Rect(0, 0, 2, 2), palette)
+	for i := range blue.Pix {
+		blue.Pix[i] = 1
+	}
+
+	var green = image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 2; y++ {
+			green.Set(x, y, color.RGBA{0, 255, 0, 255})
+		}
+	}
+
+	tests := []struct {
+		name       string
+		content    []byte
+		wantWidth  int
+		wantHeight int
+		wantPixels []byte // leading pixels
+		wantErr    bool
+	}{
+		{"png asset", textPNG, 2102, 1200, []byte{0, 0, 0}, false},
+		{"png with alpha", encode(t, png.Encode, transparent), 2, 1,
+			[]byte{255, 255, 255, 127, 127, 127}, false},
+		{"animated gif", encodeGIF(t, red, blue), 2, 2,
+			[]byte{255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0}, false},
+		{"bmp", encode(t, bmp.Encode, green), 3, 2,
+			[]byte{0, 255, 0, 0, 255, 0, 0, 255, 0}, false},
+		{"jpeg", encodeJPEG(t, green), 3, 2, nil, false},
+		{"webp", []byte(testWebP), 1, 1, []byte{255, 255, 255}, false},
+		{"not an image", []byte("hello world"), 0, 0, nil, true},
+		{"truncated image", textPNG[:len(textPNG)/2], 0, 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pixels, width, height, err := decodeRGB(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeRGB() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("decodeRGB() = %dx%d, want %dx%d", width, height, tt.wantWidth, tt.wantHeight)
+			}
+			if len(pixels) != 3*width*height {
+				t.Errorf("decodeRGB() returned %d bytes, want %d", len(pixels), 3*width*height)
+			}
+			if !bytes.HasPrefix(pixels, tt.wantPixels) {
+				t.Errorf("decodeRGB() pixels = %v, want %v", pixels[:len(tt.wantPixels)], tt.wantPixels)
+			}
+		})
+	}
+}
+
+func encode(t *testing.T, enc func(w io.Writer, m image.Image) error, img image.Image) []byte {
+	var b bytes.Buffer
+	if err := enc(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	return encode(t, func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) }, img)
+}
+
+func encodeGIF(t *testing.T, frames ...*image.Paletted) []byte {
+	return encode(t, func(w io.Writer, _ image.Image) error {
+		return gif.EncodeAll(w, &gif.GIF{Image: frames, Delay: make([]int, len(frames))})
+	}, nil)
+}
@@ -0,0 +1,65 @@
+package images
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_download(t *testing.T) {
+	var stall = make(chan struct{})
+	var srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/model.zip":
+			w.Write([]byte("archive"))
+		case "/stalled.zip":
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			<-stall
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	defer close(stall)
+
+	var client = downloadClient
+	downloadClient = &http.Client{Timeout: 100 * time.Millisecond}
+	defer func() { downloadClient = client }()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"ok", "/model.zip", "archive", false},
+		{"not found", "/other.zip", "", true},
+		{"stalled", "/stalled.zip", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "lens-download")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			var file = filepath.Join(dir, "model.zip")
+
+			if err := download(srv.URL+tt.path, file); (err != nil) != tt.wantErr {
+				t.Errorf("download() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, err := ioutil.ReadFile(file); err != nil || string(got) != tt.want {
+				t.Errorf("download() wrote %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package images
+
+import (
+	"fmt"
+	"math"
+
+	"go.uber.org/zap"
+)
+
+// heuristicVersion is the version of the heuristics used by HeuristicAnalyzer,
+// which must be incremented whenever they change
+const heuristicVersion = 1
+
+// heuristicMaxSamples is the most pixels of an image that are examined
+const heuristicMaxSamples = 1 << 16
+
+// Labels reported by HeuristicAnalyzer
+var (
+	// colorLabels are the names of dominant colors
+	colorLabels = []string{
+		"black", "white", "gray", "red", "orange", "brown", "yellow", "green",
+		"blue", "purple", "pink",
+	}
+	// textLabel denotes images that appear to contain text, such as scanned
+	// documents and screenshots
+	textLabel = "text"
+	// monochromeLabel denotes images without color
+	monochromeLabel = "monochrome"
+	// shapeLabels describe the aspect ratio of images
+	shapeLabels = []string{"panorama", "landscape", "portrait", "square"}
+
+	heuristicLabels = append(append(append([]string{}, colorLabels...),
+		textLabel, monochromeLabel), shapeLabels...)
+)
+
+// HeuristicAnalyzer classifies images by their dominant colors, aspect ratio
+// and text density. It is far less capable than a TensorFlow model, but needs
+// neither TensorFlow nor a model, so images can still be indexed without them.
+// It is safe for concurrent use.
+type HeuristicAnalyzer struct {
+	version       string
+	topK          int
+	minConfidence float32
+
+	l *zap.SugaredLogger
+}
+
+// NewHeuristicAnalyzer creates a heuristic image classifier. Only the TopK and
+// MinConfidence options are used.
+func NewHeuristicAnalyzer(opts ConfigOpts, logger *zap.SugaredLogger) *HeuristicAnalyzer {
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = DefaultTopK
+	}
+	if opts.MinConfidence <= 0 {
+		opts.MinConfidence = DefaultMinConfidence
+	}
+	return &HeuristicAnalyzer{
+		// classification options change results, so they are part of the version
+		version:       fmt.Sprintf("heuristic-%d-top%d-%g", heuristicVersion, opts.TopK, opts.MinConfidence),
+		topK:          opts.TopK,
+		minConfidence: opts.MinConfidence,
+		l:             logger,
+	}
+}
+
+// Version reports the version of the heuristics and the classification options
+func (h *HeuristicAnalyzer) Version() string { return h.version }
+
+// Analyze classifies an image by its dominant colors, aspect ratio and text
+// density
+func (h *HeuristicAnalyzer) Analyze(jobID string, content []byte) ([]Label, error) {
+	pixels, width, height, err := decodeRGB(content)
+	if err != nil {
+		return nil, err
+	}
+	var labels = topLabels(heuristicProbabilities(pixels, width, height),
+		heuristicLabels, h.topK, h.minConfidence)
+	h.l.Debugw("image classified heuristically",
+		"job_id", jobID, "labels", len(labels))
+	return labels, nil
+}
+
+// heuristicProbabilities computes the confidence of each of heuristicLabels for
+// the given RGB pixels
+func heuristicProbabilities(pixels []byte, width, height int) []float32 {
+	var (
+		probabilities = make([]float32, len(heuristicLabels))
+		colors        = probabilities[:len(colorLabels)]
+
+		// luminance histogram, used to find the background of text
+		luminance [16]int
+		samples   int
+		edges     int
+		gray      int
+	)
+
+	// large images are sampled evenly, rather than examined in full
+	var step = 1 + int(math.Sqrt(float64(width*height)/heuristicMaxSamples))
+	for y := 0; y < height; y += step {
+		for x := 0; x < width; x += step {
+			var i = 3 * (y*width + x)
+			var r, g, b = pixels[i], pixels[i+1], pixels[i+2]
+			var c = colorOf(r, g, b)
+			colors[c]++
+			if c <= 2 { // black, white or gray
+				gray++
+			}
+			var lum = luma(r, g, b)
+			luminance[lum/16]++
+			// text consists of sharp changes between a glyph and its background
+			if x+1 < width {
+				var j = i + 3
+				if math.Abs(float64(lum)-float64(luma(pixels[j], pixels[j+1], pixels[j+2]))) > 64 {
+					edges++
+				}
+			}
+			samples++
+		}
+	}
+	for c := range colors {
+		colors[c] /= float32(samples)
+	}
+
+	// text is dense with edges on a largely uniform background
+	var background int
+	for _, n := range luminance {
+		if n > background {
+			background = n
+		}
+	}
+	var (
+		edgeRatio       = float64(edges) / float64(samples)
+		backgroundRatio = float64(background) / float64(samples)
+		grayRatio       = float64(gray) / float64(samples)
+	)
+	probabilities[len(colorLabels)] = float32(clamp(10*edgeRatio) * backgroundRatio)
+	probabilities[len(colorLabels)+1] = float32(clamp(10 * (grayRatio - 0.9)))
+
+	var shape = len(colorLabels) + 2
+	switch ratio := float64(width) / float64(height); {
+	case ratio >= 2:
+		probabilities[shape] = 1
+	case ratio > 1.1:
+		probabilities[shape+1] = 1
+	case ratio < 0.9:
+		probabilities[shape+2] = 1
+	default:
+		probabilities[shape+3] = 1
+	}
+	return probabilities
+}
+
+// colorOf returns the index in colorLabels of the name of the given color
+func colorOf(r, g, b byte) int {
+	var max, min = float64(r), float64(r)
+	for _, c := range []float64{float64(g), float64(b)} {
+		max, min = math.Max(max, c), math.Min(min, c)
+	}
+	var value = max / 255
+	switch {
+	case value < 0.2:
+		return 0 // black
+	case (max-min)/max < 0.15:
+		if value > 0.85 {
+			return 1 // white
+		}
+		return 2 // gray
+	}
+
+	var hue float64
+	switch delta := max - min; max {
+	case float64(r):
+		hue = math.Mod((float64(g)-float64(b))/delta+6, 6)
+	case float64(g):
+		hue = (float64(b)-float64(r))/delta + 2
+	default:
+		hue = (float64(r)-float64(g))/delta + 4
+	}
+	switch hue *= 60; {
+	case hue < 15 || hue >= 345:
+		return 3 // red
+	case hue < 45:
+		if value < 0.6 {
+			return 5 // brown
+		}
+		return 4 // orange
+	case hue < 70:
+		return 6 // yellow
+	case hue < 170:
+		return 7 // green
+	case hue < 260:
+		return 8 // blue
+	case hue < 290:
+		return 9 // purple
+	default:
+		return 10 // pink
+	}
+}
+
+// luma returns the perceived brightness of a color
+func luma(r, g, b byte) int {
+	return (299*int(r) + 587*int(g) + 114*int(b)) / 1000
+}
+
+// clamp limits v to between 0 and 1
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,100 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+// fill returns an image of the given size filled by the given function
+func fill(width, height int, f func(x, y int) color.Color) image.Image {
+	var img = image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, f(x, y))
+		}
+	}
+	return img
+}
+
+func TestHeuristicAnalyzer_Analyze(t *testing.T) {
+	textPNG, err := ioutil.ReadFile("../../test/assets/text.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		black = color.RGBA{0, 0, 0, 255}
+		white = color.RGBA{255, 255, 255, 255}
+		blue  = color.RGBA{30, 60, 200, 255}
+		green = color.RGBA{40, 180, 60, 255}
+	)
+	// a page of black glyphs on a white background
+	var page = fill(200, 300, func(x, y int) color.Color {
+		if y%12 < 8 && x%5 < 2 && x > 10 && x < 190 {
+			return black
+		}
+		return white
+	})
+	// a blue sky over green grass
+	var landscape = fill(300, 200, func(x, y int) color.Color {
+		if y < 120 {
+			return blue
+		}
+		return green
+	})
+	var panorama = fill(400, 100, func(x, y int) color.Color { return blue })
+	var square = fill(100, 100, func(x, y int) color.Color { return color.RGBA{128, 128, 128, 255} })
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    []string // labels, in any order
+		notWant []string
+		wantErr bool
+	}{
+		{"document", encode(t, png.Encode, page),
+			[]string{"text", "white", "black", "monochrome", "portrait"}, nil, false},
+		{"landscape", encode(t, png.Encode, landscape),
+			[]string{"blue", "green", "landscape"}, []string{"text", "monochrome"}, false},
+		{"panorama", encode(t, png.Encode, panorama),
+			[]string{"blue", "panorama"}, []string{"landscape", "text"}, false},
+		{"gray square", encode(t, png.Encode, square),
+			[]string{"gray", "monochrome", "square"}, []string{"text"}, false},
+		{"png asset", textPNG, []string{"black", "landscape"}, []string{"portrait"}, false},
+		{"invalid image", []byte("not an image"), nil, nil, true},
+	}
+	var h = NewHeuristicAnalyzer(ConfigOpts{}, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels, err := h.Analyze("", tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(labels) > DefaultTopK {
+				t.Errorf("Analyze() = %v, want at most %d labels", labels, DefaultTopK)
+			}
+			var got = make(map[string]float32)
+			for i, l := range labels {
+				got[l.Name] = l.Confidence
+				if l.Confidence < DefaultMinConfidence || l.Confidence > 1 {
+					t.Errorf("Analyze() label %v has invalid confidence", l)
+				}
+				if i > 0 && l.Confidence > labels[i-1].Confidence {
+					t.Errorf("Analyze() = %v, want most confident first", labels)
+				}
+			}
+			for _, name := range tt.want {
+				if _, ok := got[name]; !ok {
+					t.Errorf("Analyze() = %v, want label %q", labels, name)
+				}
+			}
+			for _, name := range tt.notWant {
+				if _, ok := got[name]; ok {
+					t.Errorf("Analyze() = %v, want no label %q", labels, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package images
+
+import "sort"
+
+// topLabels returns the k most probable labels with at least the given
+// confidence, most confident first. Probabilities without a label are ignored.
+func topLabels(probabilities []float32, labels []string, k int, min float32) []Label {
+	var ranked = make([]int, 0, len(probabilities))
+	for i, p := range probabilities {
+		if i < len(labels) && p >= min {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return probabilities[ranked[i]] > probabilities[ranked[j]]
+	})
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+	var out = make([]Label, len(ranked))
+	for i, idx := range ranked {
+		out[i] = Label{Name: labels[idx], Confidence: probabilities[idx]}
+	}
+	return out
+}
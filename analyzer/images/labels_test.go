@@ -0,0 +1,35 @@
+package images
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_topLabels(t *testing.T) {
+	var labels = []string{"dog", "cat", "boat", "car"}
+	tests := []struct {
+		name          string
+		probabilities []float32
+		k             int
+		min           float32
+		want          []Label
+	}{
+		{"ranked by confidence", []float32{0.2, 0.5, 0.1, 0.2}, 5, 0,
+			[]Label{{"cat", 0.5}, {"dog", 0.2}, {"car", 0.2}, {"boat", 0.1}}},
+		{"limited to k", []float32{0.2, 0.5, 0.1, 0.2}, 2, 0,
+			[]Label{{"cat", 0.5}, {"dog", 0.2}}},
+		{"below minimum confidence", []float32{0.2, 0.5, 0.1, 0.2}, 5, 0.3,
+			[]Label{{"cat", 0.5}}},
+		{"nothing confident", []float32{0.2, 0.5, 0.1, 0.2}, 5, 0.9,
+			[]Label{}},
+		{"unlabelled probabilities", []float32{0.1, 0, 0, 0, 0.9}, 5, 0,
+			[]Label{{"dog", 0.1}, {"cat", 0}, {"boat", 0}, {"car", 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topLabels(tt.probabilities, labels, tt.k, tt.min); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("topLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
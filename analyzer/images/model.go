@@ -0,0 +1,369 @@
+package images
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Model describes a frozen TensorFlow graph that classifies images, and how
+// images must be prepared for it
+type Model struct {
+	Name string `json:"name"`
+
+	// URL is a zip archive containing the graph and labels, used by Fetch
+	URL string `json:"url,omitempty"`
+
+	// Graph and Labels are the names of the graph and labels files within the
+	// model directory - the labels file contains one label per line
+	Graph  string `json:"graph"`
+	Labels string `json:"labels"`
+
+	// Checksum is the hex-encoded SHA-256 checksum of the graph - if set,
+	// graphs that do not match are rejected
+	Checksum string `json:"checksum,omitempty"`
+
+	// Input and Output are the names of the graph's input and output
+	// operations. The input takes a batch of images as a tensor of shape
+	// [BatchSize, Height, Width, Colors=3], and the output produces the
+	// probability of each label for each image.
+	Input  string `json:"input"`
+	Output string `json:"output"`
+
+	// Height and Width are the size images are scaled to, and Mean and Scale
+	// normalize each color as (value - Mean)/Scale
+	Height int     `json:"height"`
+	Width  int     `json:"width"`
+	Mean   float32 `json:"mean"`
+	Scale  float32 `json:"scale"`
+}
+
+// Inception5h is the default model, available from
+// https://storage.googleapis.com/download.tensorflow.org/models/inception5h.zip
+var Inception5h = Model{
+	Name:   "inception5h",
+	URL:    "https://storage.googleapis.com/download.tensorflow.org/models/inception5h.zip",
+	Graph:  "tensorflow_inception_graph.pb",
+	Labels: "imagenet_comp_graph_label_strings.txt",
+	Input:  "input",
+	Output: "output",
+	// The model was trained with images scaled to 224x224 pixels, and
+	// colors, represented as R, G, B in 1-byte each, were converted to float
+	// using (value - Mean)/Scale.
+	Height: 224,
+	Width:  224,
+	Mean:   117,
+	Scale:  1,
+}
+
+var (
+	registeredMux sync.RWMutex
+	registered    = map[string]Model{Inception5h.Name: Inception5h}
+)
+
+// RegisterModel makes a model available by name, replacing any model already
+// registered with the same name
+func RegisterModel(m Model) error {
+	if err := m.validate(); err != nil {
+		return fmt.Errorf("invalid model %q: %v", m.Name, err)
+	}
+	registeredMux.Lock()
+	registered[m.Name] = m
+	registeredMux.Unlock()
+	return nil
+}
+
+// LookupModel retrieves a registered model by name
+func LookupModel(name string) (Model, error) {
+	registeredMux.RLock()
+	defer registeredMux.RUnlock()
+	if m, ok := registered[name]; ok {
+		return m, nil
+	}
+	var names = make([]string, 0, len(registered))
+	for n := range registered {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return Model{}, fmt.Errorf("unknown model %q - registered models are %s",
+		name, strings.Join(names, ", "))
+}
+
+// validate checks that the model is fully described
+func (m Model) validate() error {
+	switch {
+	case m.Name == "":
+		return errors.New("no name provided")
+	case m.Graph == "" || m.Labels == "":
+		return errors.New("graph and labels files must be provided")
+	case filepath.Base(m.Graph) != m.Graph || filepath.Base(m.Labels) != m.Labels:
+		return errors.New("graph and labels must be file names, not paths")
+	case m.Input == "" || m.Output == "":
+		return errors.New("input and output operations must be provided")
+	case m.Height <= 0 || m.Width <= 0:
+		return errors.New("image height and width must be positive")
+	case m.Scale == 0:
+		return errors.New("scale must not be zero")
+	}
+	if m.Checksum != "" {
+		if b, err := hex.DecodeString(m.Checksum); err != nil || len(b) != sha256.Size {
+			return errors.New("checksum must be a hex-encoded SHA-256 checksum")
+		}
+	}
+	return nil
+}
+
+// files returns the paths of the model's graph and labels within dir
+func (m Model) files(dir string) (graph, labels string) {
+	return filepath.Join(dir, m.Graph), filepath.Join(dir, m.Labels)
+}
+
+// checksumFile is where Fetch records the checksum of a fetched graph
+func checksumFile(graph string) string { return graph + ".sha256" }
+
+// load reads the model's graph and labels from dir, without downloading
+// anything. The graph is verified against the model's checksum and the
+// checksum recorded when it was fetched, if either are available.
+func (m Model) load(dir string) (graph []byte, labels []string, err error) {
+	var graphFile, labelsFile = m.files(dir)
+	if err := filesExist(graphFile, labelsFile); err != nil {
+		return nil, nil, fmt.Errorf("model %s not found in %s - use 'temporal-lens fetch' to download it: %v",
+			m.Name, dir, err)
+	}
+	if graph, err = ioutil.ReadFile(graphFile); err != nil {
+		return nil, nil, err
+	}
+	var sum = sha256.Sum256(graph)
+	if err := m.verify(sum[:]); err != nil {
+		return nil, nil, err
+	}
+	if recorded, err := ioutil.ReadFile(checksumFile(graphFile)); err == nil &&
+		strings.TrimSpace(string(recorded)) != hex.EncodeToString(sum[:]) {
+		return nil, nil, fmt.Errorf("model %s graph %s has changed since it was fetched",
+			m.Name, graphFile)
+	}
+	if labels, err = readLabels(labelsFile); err != nil {
+		return nil, nil, err
+	}
+	return graph, labels, nil
+}
+
+// verify checks the given graph checksum against the model's checksum
+func (m Model) verify(sum []byte) error {
+	if m.Checksum != "" && !strings.EqualFold(m.Checksum, hex.EncodeToString(sum)) {
+		return fmt.Errorf("model %s graph checksum %x does not match expected checksum %s",
+			m.Name, sum, m.Checksum)
+	}
+	return nil
+}
+
+// Fetch downloads the model's archive and extracts it into dir, verifying the
+// graph's checksum and recording it so that it can be verified when loaded.
+// Models that are already present are not downloaded again.
+func Fetch(m Model, dir string) error {
+	if err := m.validate(); err != nil {
+		return fmt.Errorf("invalid model %q: %v", m.Name, err)
+	}
+	if _, _, err := m.load(dir); err == nil {
+		return nil
+	}
+	if m.URL == "" {
+		return fmt.Errorf("model %s has no URL to fetch it from", m.Name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".download-*.zip")
+	if err != nil {
+		return err
+	}
+	var zipfile = tmp.Name()
+	tmp.Close()
+	defer os.Remove(zipfile)
+	if err := download(m.URL, zipfile); err != nil {
+		return fmt.Errorf("failed to download %v - %v", m.URL, err)
+	}
+	if err := unzip(dir, zipfile, maxArchiveSize); err != nil {
+		return fmt.Errorf("failed to extract contents from model archive: %v", err)
+	}
+
+	var graphFile, labelsFile = m.files(dir)
+	if err := filesExist(graphFile, labelsFile); err != nil {
+		return fmt.Errorf("model archive is missing files: %v", err)
+	}
+	graph, err := ioutil.ReadFile(graphFile)
+	if err != nil {
+		return err
+	}
+	var sum = sha256.Sum256(graph)
+	if err := m.verify(sum[:]); err != nil {
+		os.Remove(graphFile)
+		return err
+	}
+	return ioutil.WriteFile(checksumFile(graphFile), []byte(hex.EncodeToString(sum[:])+"\n"), 0644)
+}
+
+func filesExist(files ...string) error {
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			return fmt.Errorf("unable to stat %s: %v", f, err)
+		}
+	}
+	return nil
+}
+
+// downloadClient is used to download model archives - archives are large, so
+// the timeout is generous, but stalled downloads still fail eventually
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+func download(URL, filename string) error {
+	resp, err := downloadClient.Get(URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, resp.Body)
+	return err
+}
+
+// Limits on model archives, which guard against malicious or corrupt archives
+const (
+	maxArchiveEntries = 1000
+	maxArchiveSize    = 2 << 30
+)
+
+// unzip extracts the files in zipfile into dir. Entries must be regular files
+// or directories within dir, and the archive must not decompress to more than
+// maxSize bytes. Each file is written to a temporary file that replaces any
+// existing file once it is complete, so that files are never left partially
+// written.
+func unzip(dir, zipfile string, maxSize int64) error {
+	r, err := zip.OpenReader(zipfile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if len(r.File) > maxArchiveEntries {
+		return fmt.Errorf("archive has more than %d entries", maxArchiveEntries)
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, f := range r.File {
+		target, err := extractPath(root, f.Name)
+		if err != nil {
+			return err
+		}
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		case !mode.IsRegular():
+			return fmt.Errorf("archive entry %s is not a regular file", f.Name)
+		}
+		n, err := extractFile(target, f, maxSize-total)
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %v", f.Name, err)
+		}
+		total += n
+	}
+	return nil
+}
+
+// extractPath resolves where an archive entry is extracted to, which must be
+// within root
+func extractPath(root, name string) (string, error) {
+	if name == "" || strings.Contains(name, `\`) || path.IsAbs(name) ||
+		filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry %q has an invalid path", name)
+	}
+	var target = filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q is outside of the model directory", name)
+	}
+	return target, nil
+}
+
+// extractFile writes an archive entry of at most limit bytes to target,
+// returning its size
+func extractFile(target string, f *zip.File, limit int64) (int64, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return 0, errors.New("archive is too large when decompressed")
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".")
+	if err != nil {
+		return 0, err
+	}
+	// the declared size may be wrong, so the size read is limited as well
+	n, err := io.Copy(tmp, io.LimitReader(src, limit+1))
+	if err == nil && n > limit {
+		err = errors.New("archive is too large when decompressed")
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return n, err
+	}
+	return n, nil
+}
+
+// readLabels reads labelsFile, which contains one line per label.
+func readLabels(labelsFile string) ([]string, error) {
+	file, err := os.Open(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var labels []string
+	for scanner.Scan() {
+		labels = append(labels, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ERROR: failed to read %s: %v", labelsFile, err)
+	}
+	return labels, nil
+}
@@ -0,0 +1,162 @@
+package images_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer/images"
+)
+
+// testModel is a model whose files are served by newModelServer
+var testModel = images.Model{
+	Name:   "test",
+	Graph:  "graph.pb",
+	Labels: "labels.txt",
+	Input:  "input",
+	Output: "output",
+	Height: 32,
+	Width:  32,
+	Scale:  1,
+}
+
+const testGraph = "not really a graph"
+
+func testChecksum() string {
+	var sum = sha256.Sum256([]byte(testGraph))
+	return hex.EncodeToString(sum[:])
+}
+
+// newModelServer serves a zip archive containing the given files, counting
+// the requests it receives
+func newModelServer(t *testing.T, files map[string]string) (*httptest.Server, *int) {
+	var b bytes.Buffer
+	var w = zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var requests int
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/model.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b.Bytes())
+	})), &requests
+}
+
+func TestRegisterModel(t *testing.T) {
+	var withChecksum = testModel
+	withChecksum.Name = "with checksum"
+	withChecksum.Checksum = testChecksum()
+	var noName = testModel
+	noName.Name = ""
+	var noOutput = testModel
+	noOutput.Output = ""
+	var path = testModel
+	path.Graph = "../graph.pb"
+	var noSize = testModel
+	noSize.Height = 0
+	var badChecksum = testModel
+	badChecksum.Checksum = "abc"
+
+	tests := []struct {
+		name    string
+		model   images.Model
+		wantErr bool
+	}{
+		{"ok", testModel, false},
+		{"ok: with checksum", withChecksum, false},
+		{"no name", noName, true},
+		{"no output operation", noOutput, true},
+		{"graph path", path, true},
+		{"no image size", noSize, true},
+		{"invalid checksum", badChecksum, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := images.RegisterModel(tt.model); (err != nil) != tt.wantErr {
+				t.Errorf("RegisterModel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, err := images.LookupModel(tt.model.Name); err != nil || got != tt.model {
+				t.Errorf("LookupModel() = %v, %v, want %v", got, err, tt.model)
+			}
+		})
+	}
+
+	if _, err := images.LookupModel(images.Inception5h.Name); err != nil {
+		t.Errorf("LookupModel() default model error = %v", err)
+	}
+	if _, err := images.LookupModel("unknown"); err == nil {
+		t.Error("LookupModel() expected error for unknown model")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	var complete = map[string]string{"graph.pb": testGraph, "labels.txt": "cat\ndog\n"}
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string
+		checksum string
+		wantErr  bool
+	}{
+		{"ok", complete, "/model.zip", "", false},
+		{"ok: with checksum", complete, "/model.zip", testChecksum(), false},
+		{"checksum mismatch", complete, "/model.zip", strings.Repeat("0", 64), true},
+		{"missing labels", map[string]string{"graph.pb": testGraph}, "/model.zip", "", true},
+		{"not found", complete, "/other.zip", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "lens-models")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			var srv, requests = newModelServer(t, tt.files)
+			defer srv.Close()
+
+			var m = testModel
+			m.URL = srv.URL + tt.path
+			m.Checksum = tt.checksum
+			if err := images.Fetch(m, dir); (err != nil) != tt.wantErr {
+				t.Errorf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(filepath.Join(dir, m.Graph)); err == nil && tt.checksum != "" {
+					t.Error("Fetch() kept graph that did not match checksum")
+				}
+				return
+			}
+			if _, err := os.Stat(filepath.Join(dir, m.Graph)); err != nil {
+				t.Errorf("Fetch() did not extract graph: %v", err)
+			}
+
+			// models that are already present are not downloaded again
+			if err := images.Fetch(m, dir); err != nil || *requests != 1 {
+				t.Errorf("Fetch() again = %v with %d requests, want no error and 1 request", err, *requests)
+			}
+		})
+	}
+}
@@ -1,14 +1,12 @@
+//go:build !notensorflow
+// +build !notensorflow
+
 package images
 
 import (
-	"archive/zip"
-	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"path/filepath"
 
 	"go.uber.org/zap"
 
@@ -16,38 +14,47 @@ import (
 	"github.com/tensorflow/tensorflow/tensorflow/go/op"
 )
 
-// TensorflowAnalyzer represents a wrapper around a Tensorflow-based analyzer
-//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -o ../../mocks/images.mock.go github.com/RTradeLtd/Lens/v2/analyzer/images.TensorflowAnalyzer
-type TensorflowAnalyzer interface {
-	Analyze(jobID string, content []byte) (category string, err error)
-}
+// tensorflowAvailable indicates that this build supports TensorFlow
+const tensorflowAvailable = true
 
 // All credits for this go to the developers of the example in the following link
 // https://godoc.org/github.com/tensorflow/tensorflow/tensorflow/go
 // This is simply a modified version, intended to be run as a analyzer method by the Lens service
 
-// Analyzer is used to analyze images
+// Analyzer is used to analyze images. It is safe for concurrent use, as
+// TensorFlow sessions can be run concurrently.
 type Analyzer struct {
-	session    *tf.Session
-	graph      *tf.Graph
-	labelsFile string
+	session *tf.Session
+	input   tf.Output
+	output  tf.Output
+	labels  []string
+	version string
 
-	l *zap.SugaredLogger
-}
+	// normalizer runs the graph that converts images to model input
+	normalizer *tf.Session
+	normInput  tf.Output
+	normOutput tf.Output
+
+	topK          int
+	minConfidence float32
 
-// ConfigOpts is used to configure our image analyzer
-type ConfigOpts struct {
-	ModelLocation string `json:"model_location"`
+	l *zap.SugaredLogger
 }
 
 // NewAnalyzer is used to analyze an image and classify it
 func NewAnalyzer(opts ConfigOpts, logger *zap.SugaredLogger) (*Analyzer, error) {
-	// load a seralized graph definition
-	modelFile, labelsFile, err := modelFiles(opts.ModelLocation)
+	if logger == nil {
+		logger = zap.NewNop().Sugar()
+	}
+	if opts.Model == "" {
+		opts.Model = Inception5h.Name
+	}
+	m, err := LookupModel(opts.Model)
 	if err != nil {
 		return nil, err
 	}
-	model, err := ioutil.ReadFile(modelFile)
+	// load a seralized graph definition
+	model, labels, err := m.load(opts.ModelLocation)
 	if err != nil {
 		return nil, err
 	}
@@ -56,86 +63,138 @@ func NewAnalyzer(opts ConfigOpts, logger *zap.SugaredLogger) (*Analyzer, error)
 	if err = graph.Import(model, ""); err != nil {
 		return nil, err
 	}
+	var input, output = graph.Operation(m.Input), graph.Operation(m.Output)
+	if input == nil || output == nil {
+		return nil, fmt.Errorf("model %s has no %s input or %s output operation",
+			m.Name, m.Input, m.Output)
+	}
 	// create a session
 	session, err := tf.NewSession(graph, nil)
 	if err != nil {
 		return nil, err
 	}
+	// create a session to normalize images, shared by all analyses
+	normGraph, normInput, normOutput, err := constructGraphToNormalizeImage(m)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	normalizer, err := tf.NewSession(normGraph, nil)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = DefaultTopK
+	}
+	if opts.MinConfidence <= 0 {
+		opts.MinConfidence = DefaultMinConfidence
+	}
+	logger.Infow("image model loaded",
+		"model", m.Name, "labels", len(labels))
+	var sum = sha256.Sum256(model)
 	return &Analyzer{
-		session:    session,
-		labelsFile: labelsFile,
-		graph:      graph,
+		session: session,
+		input:   input.Output(0),
+		output:  output.Output(0),
+		labels:  labels,
+		// classification options change results, so they are part of the version
+		version:       fmt.Sprintf("%s-%x-top%d-%g", m.Name, sum[:6], opts.TopK, opts.MinConfidence),
+		normalizer:    normalizer,
+		normInput:     normInput,
+		normOutput:    normOutput,
+		topK:          opts.TopK,
+		minConfidence: opts.MinConfidence,
+		l:             logger,
 	}, nil
 }
 
-// Analyze is used to run an image against the Inception v5 pre-trained model
-func (a *Analyzer) Analyze(jobID string, content []byte) (string, error) {
-	tensor, err := makeTensorFromImage(content)
-	if err != nil {
-		return "", err
+// Version reports the version of the model, derived from its checksum, and the
+// classification options
+func (a *Analyzer) Version() string { return a.version }
+
+// Close releases the analyzer's TensorFlow sessions
+func (a *Analyzer) Close() error {
+	var err = a.normalizer.Close()
+	if serr := a.session.Close(); serr != nil {
+		err = serr
 	}
-	output, err := a.session.Run(
-		map[tf.Output]*tf.Tensor{
-			a.graph.Operation("input").Output(0): tensor,
-		},
-		[]tf.Output{
-			a.graph.Operation("output").Output(0),
-		},
-		nil,
-	)
+	return err
+}
+
+// Analyze is used to run an image against the configured pre-trained model
+func (a *Analyzer) Analyze(jobID string, content []byte) ([]Label, error) {
+	labels, err := a.AnalyzeBatch(jobID, [][]byte{content})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	probabilities := output[0].Value().([][]float32)[0]
-	return a.classify(probabilities, a.labelsFile)
+	return labels[0], nil
 }
 
-func (a *Analyzer) classify(probabilities []float32, labelsFile string) (string, error) {
-	bestIdx := 0
-	for i, p := range probabilities {
-		if p > probabilities[bestIdx] {
-			bestIdx = i
+// AnalyzeBatch classifies several images with a single run of the model,
+// returning the labels of each image in order. The batch fails if any of the
+// images cannot be decoded.
+func (a *Analyzer) AnalyzeBatch(jobID string, contents [][]byte) ([][]Label, error) {
+	if len(contents) == 0 {
+		return nil, nil
+	}
+	var batch bytes.Buffer
+	var shape []int64
+	for i, content := range contents {
+		tensor, err := a.makeTensorFromImage(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare image %d: %v", i, err)
+		}
+		// images are normalized into a batch of size 1, so they can be joined
+		// to form a larger batch
+		if shape == nil {
+			shape = append([]int64{int64(len(contents))}, tensor.Shape()[1:]...)
+		}
+		if _, err := tensor.WriteContentsTo(&batch); err != nil {
+			return nil, err
 		}
 	}
-	// Found the best match. Read the string from labelsFile, which
-	// contains one line per label.
-	file, err := os.Open(labelsFile)
+	tensor, err := tf.ReadTensor(tf.Float, shape, &batch)
 	if err != nil {
-		return "", nil
+		return nil, err
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	var labels []string
-	for scanner.Scan() {
-		labels = append(labels, scanner.Text())
+	output, err := a.session.Run(
+		map[tf.Output]*tf.Tensor{a.input: tensor},
+		[]tf.Output{a.output},
+		nil,
+	)
+	if err != nil {
+		return nil, err
 	}
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("ERROR: failed to read %s: %v", labelsFile, err)
+	var (
+		probabilities = output[0].Value().([][]float32)
+		results       = make([][]Label, len(probabilities))
+	)
+	for i, p := range probabilities {
+		results[i] = topLabels(p, a.labels, a.topK, a.minConfidence)
 	}
-	return labels[bestIdx], nil
+	a.l.Debugw("images classified",
+		"job_id", jobID, "images", len(contents))
+	return results, nil
 }
 
 // Convert the image in filename to a Tensor suitable as input to the Inception model.
-func makeTensorFromImage(content []byte) (*tf.Tensor, error) {
-	// DecodeJpeg uses a scalar String-valued tensor as input.
-	tensor, err := tf.NewTensor(string(content))
-	if err != nil {
-		return nil, err
-	}
-	// Construct a graph to normalize the image
-	graph, input, output, err := constructGraphToNormalizeImage()
+func (a *Analyzer) makeTensorFromImage(content []byte) (*tf.Tensor, error) {
+	// Images are decoded in Go, as TensorFlow's decoders only support some
+	// formats, and provided as a [Height, Width, Colors=3] tensor of bytes.
+	pixels, width, height, err := decodeRGB(content)
 	if err != nil {
 		return nil, err
 	}
-	// Execute that graph to normalize this one image
-	session, err := tf.NewSession(graph, nil)
+	tensor, err := tf.ReadTensor(tf.Uint8,
+		[]int64{int64(height), int64(width), 3}, bytes.NewReader(pixels))
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
-	normalized, err := session.Run(
-		map[tf.Output]*tf.Tensor{input: tensor},
-		[]tf.Output{output},
+	// Execute the normalization graph on this one image
+	normalized, err := a.normalizer.Run(
+		map[tf.Output]*tf.Tensor{a.normInput: tensor},
+		[]tf.Output{a.normOutput},
 		nil)
 	if err != nil {
 		return nil, err
@@ -143,113 +202,32 @@ func makeTensorFromImage(content []byte) (*tf.Tensor, error) {
 	return normalized[0], nil
 }
 
-// The inception model takes as input the image described by a Tensor in a very
-// specific normalized format (a particular image size, shape of the input tensor,
+// Models take as input the image described by a Tensor in a very specific
+// normalized format (a particular image size, shape of the input tensor,
 // normalized pixel values etc.).
 //
 // This function constructs a graph of TensorFlow operations which takes as
-// input a JPEG-encoded string and returns a tensor suitable as input to the
-// inception model.
-func constructGraphToNormalizeImage() (graph *tf.Graph, input, output tf.Output, err error) {
-	// Some constants specific to the pre-trained model at:
-	// https://storage.googleapis.com/download.tensorflow.org/models/inception5h.zip
-	//
-	// - The model was trained after with images scaled to 224x224 pixels.
-	// - The colors, represented as R, G, B in 1-byte each were converted to
-	//   float using (value - Mean)/Scale.
-	const (
-		H, W  = 224, 224
-		Mean  = float32(117)
-		Scale = float32(1)
-	)
-	// - input is a String-Tensor, where the string the JPEG-encoded image.
-	// - The inception model takes a 4D tensor of shape
+// input the RGB pixels of an image and returns a tensor suitable as input to
+// the given model.
+func constructGraphToNormalizeImage(m Model) (graph *tf.Graph, input, output tf.Output, err error) {
+	// - input is a 3D tensor of shape [Height, Width, Colors=3], where each
+	//   pixel is represented as a triplet of bytes
+	// - The model takes a 4D tensor of shape
 	//   [BatchSize, Height, Width, Colors=3], where each pixel is
 	//   represented as a triplet of floats
 	// - Apply normalization on each pixel and use ExpandDims to make
 	//   this single image be a "batch" of size 1 for ResizeBilinear.
 	s := op.NewScope()
-	input = op.Placeholder(s, tf.String)
+	input = op.Placeholder(s, tf.Uint8)
 	output = op.Div(s,
 		op.Sub(s,
 			op.ResizeBilinear(s,
 				op.ExpandDims(s,
-					op.Cast(s,
-						op.DecodeJpeg(s, input, op.DecodeJpegChannels(3)), tf.Float),
+					op.Cast(s, input, tf.Float),
 					op.Const(s.SubScope("make_batch"), int32(0))),
-				op.Const(s.SubScope("size"), []int32{H, W})),
-			op.Const(s.SubScope("mean"), Mean)),
-		op.Const(s.SubScope("scale"), Scale))
+				op.Const(s.SubScope("size"), []int32{int32(m.Height), int32(m.Width)})),
+			op.Const(s.SubScope("mean"), m.Mean)),
+		op.Const(s.SubScope("scale"), m.Scale))
 	graph, err = s.Finalize()
 	return graph, input, output, err
 }
-
-func modelFiles(dir string) (modelfile, labelsfile string, err error) {
-	const URL = "https://storage.googleapis.com/download.tensorflow.org/models/inception5h.zip"
-	var (
-		model   = filepath.Join(dir, "tensorflow_inception_graph.pb")
-		labels  = filepath.Join(dir, "imagenet_comp_graph_label_strings.txt")
-		zipfile = filepath.Join(dir, "inception5h.zip")
-	)
-	if filesExist(model, labels) == nil {
-		return model, labels, nil
-	}
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", "", err
-	}
-	if err := download(URL, zipfile); err != nil {
-		return "", "", fmt.Errorf("failed to download %v - %v", URL, err)
-	}
-	if err := unzip(dir, zipfile); err != nil {
-		return "", "", fmt.Errorf("failed to extract contents from model archive: %v", err)
-	}
-	os.Remove(zipfile)
-	return model, labels, filesExist(model, labels)
-}
-
-func filesExist(files ...string) error {
-	for _, f := range files {
-		if _, err := os.Stat(f); err != nil {
-			return fmt.Errorf("unable to stat %s: %v", f, err)
-		}
-	}
-	return nil
-}
-
-func download(URL, filename string) error {
-	resp, err := http.Get(URL)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	_, err = io.Copy(file, resp.Body)
-	return err
-}
-
-func unzip(dir, zipfile string) error {
-	r, err := zip.OpenReader(zipfile)
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-	for _, f := range r.File {
-		src, err := f.Open()
-		if err != nil {
-			return err
-		}
-		dst, err := os.OpenFile(filepath.Join(dir, f.Name), os.O_WRONLY|os.O_CREATE, 0644)
-		if err != nil {
-			return err
-		}
-		if _, err := io.Copy(dst, src); err != nil {
-			return err
-		}
-		dst.Close()
-	}
-	return nil
-}
@@ -0,0 +1,35 @@
+//go:build notensorflow
+// +build notensorflow
+
+package images
+
+import "go.uber.org/zap"
+
+// tensorflowAvailable indicates that this build supports TensorFlow
+const tensorflowAvailable = false
+
+// Analyzer classifies images using TensorFlow, which is not supported by
+// builds with the notensorflow tag - see HeuristicAnalyzer
+type Analyzer struct{}
+
+// NewAnalyzer always returns ErrTensorflowUnavailable, as this build does not
+// support TensorFlow
+func NewAnalyzer(opts ConfigOpts, logger *zap.SugaredLogger) (*Analyzer, error) {
+	return nil, ErrTensorflowUnavailable
+}
+
+// Version is not supported without TensorFlow
+func (a *Analyzer) Version() string { return "" }
+
+// Close is not supported without TensorFlow
+func (a *Analyzer) Close() error { return ErrTensorflowUnavailable }
+
+// Analyze is not supported without TensorFlow
+func (a *Analyzer) Analyze(jobID string, content []byte) ([]Label, error) {
+	return nil, ErrTensorflowUnavailable
+}
+
+// AnalyzeBatch is not supported without TensorFlow
+func (a *Analyzer) AnalyzeBatch(jobID string, contents [][]byte) ([][]Label, error) {
+	return nil, ErrTensorflowUnavailable
+}
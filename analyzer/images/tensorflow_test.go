@@ -1,25 +1,36 @@
+//go:build !notensorflow
+// +build !notensorflow
+
 package images_test
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/RTradeLtd/Lens/v2/analyzer/images"
 	"go.uber.org/zap/zaptest"
 )
 
-const (
-	testImg = "../../test/assets/image.jpg"
-)
-
-func TestTendorize(t *testing.T) {
-	var l = zaptest.NewLogger(t)
+func newTestAnalyzer(t *testing.T) *images.Analyzer {
+	if err := images.Fetch(images.Inception5h, "models"); err != nil {
+		t.Fatal(err)
+	}
 	analyzer, err := images.NewAnalyzer(images.ConfigOpts{
 		ModelLocation: "models",
-	}, l.Sugar())
+	}, zaptest.NewLogger(t).Sugar())
 	if err != nil {
 		t.Fatal(err)
 	}
+	return analyzer
+}
+
+func TestTendorize(t *testing.T) {
+	var analyzer = newTestAnalyzer(t)
+	defer analyzer.Close()
 
 	b, err := ioutil.ReadFile(testImg)
 	if err != nil {
@@ -32,3 +43,119 @@ func TestTendorize(t *testing.T) {
 	}
 	t.Log(guess)
 }
+
+func TestAnalyzer_AnalyzeBatch(t *testing.T) {
+	var analyzer = newTestAnalyzer(t)
+	defer analyzer.Close()
+
+	jpg, err := ioutil.ReadFile(testImg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png, err := ioutil.ReadFile(testPNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		contents [][]byte
+		wantErr  bool
+	}{
+		{"no images", nil, false},
+		{"single image", [][]byte{jpg}, false},
+		{"several images", [][]byte{jpg, png, jpg}, false},
+		{"invalid image", [][]byte{jpg, []byte("hello world")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := analyzer.AnalyzeBatch("test", tt.contents)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Analyzer.AnalyzeBatch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.contents) {
+				t.Fatalf("Analyzer.AnalyzeBatch() returned %d results, want %d", len(got), len(tt.contents))
+			}
+			// batched results should match individual analyses
+			for i, c := range tt.contents {
+				want, err := analyzer.Analyze("test", c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(want) == 0 || len(got[i]) == 0 || want[0].Name != got[i][0].Name {
+					t.Errorf("Analyzer.AnalyzeBatch()[%d] = %v, want %v", i, got[i], want)
+				}
+			}
+		})
+	}
+
+	// analyses should be safe to run concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := analyzer.Analyze("test", jpg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewAnalyzer_model(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens-models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var srv, _ = newModelServer(t, map[string]string{"graph.pb": testGraph, "labels.txt": "cat\n"})
+	defer srv.Close()
+
+	var fetched = testModel
+	fetched.Name = "fetched"
+	fetched.URL = srv.URL + "/model.zip"
+	if err := images.RegisterModel(fetched); err != nil {
+		t.Fatal(err)
+	}
+	if err := images.Fetch(fetched, dir); err != nil {
+		t.Fatal(err)
+	}
+	var pinned = fetched
+	pinned.Name = "pinned"
+	pinned.Checksum = strings.Repeat("0", 64)
+	if err := images.RegisterModel(pinned); err != nil {
+		t.Fatal(err)
+	}
+	// modify the fetched graph
+	if err := ioutil.WriteFile(filepath.Join(dir, "graph.pb"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		model   string
+		wantErr string
+	}{
+		{"model not present", filepath.Join(dir, "empty"), "", "temporal-lens fetch"},
+		{"unknown model", dir, "unknown", "unknown model"},
+		{"checksum mismatch", dir, "pinned", "does not match"},
+		{"graph changed since fetched", dir, "fetched", "changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := images.NewAnalyzer(images.ConfigOpts{
+				ModelLocation: tt.dir,
+				Model:         tt.model,
+			}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewAnalyzer() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
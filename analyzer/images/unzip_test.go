@@ -0,0 +1,127 @@
+package images
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testEntry is an entry of a crafted zip archive
+type testEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+// writeZip writes a zip archive with the given entries to path
+func writeZip(t *testing.T, path string, entries []testEntry) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w = zip.NewWriter(f)
+	for _, e := range entries {
+		var hdr = &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			hdr.SetMode(e.mode)
+		}
+		fw, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(e.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_unzip(t *testing.T) {
+	var many = make([]testEntry, maxArchiveEntries+1)
+	for i := range many {
+		many[i] = testEntry{name: fmt.Sprintf("%d.txt", i)}
+	}
+
+	tests := []struct {
+		name    string
+		entries []testEntry
+		want    map[string]string // files in the model directory
+		wantErr bool
+	}{
+		{"ok", []testEntry{
+			{name: "graph.pb", content: "graph"},
+			{name: "sub/", mode: os.ModeDir | 0755},
+			{name: "sub/labels.txt", content: "cat"},
+		}, map[string]string{"graph.pb": "graph", "sub/labels.txt": "cat", "existing.txt": "stale content"}, false},
+		{"ok: replaces existing file", []testEntry{
+			{name: "existing.txt", content: "new"},
+		}, map[string]string{"existing.txt": "new"}, false},
+		{"parent directory", []testEntry{
+			{name: "../evil.txt", content: "evil"},
+		}, map[string]string{"existing.txt": "stale content"}, true},
+		{"nested parent directory", []testEntry{
+			{name: "sub/../../evil.txt", content: "evil"},
+		}, map[string]string{"existing.txt": "stale content"}, true},
+		{"absolute path", []testEntry{
+			{name: "/evil.txt", content: "evil"},
+		}, map[string]string{"existing.txt": "stale content"}, true},
+		{"backslashes", []testEntry{
+			{name: `..\evil.txt`, content: "evil"},
+		}, map[string]string{"existing.txt": "stale content"}, true},
+		{"model directory", []testEntry{
+			{name: "sub/..", content: "evil"},
+		}, map[string]string{"existing.txt": "stale content"}, true},
+		{"symlink", []testEntry{
+			{name: "link", content: "/etc/passwd", mode: os.ModeSymlink | 0777},
+		}, map[string]string{"existing.txt": "stale content"}, true},
+		{"too large", []testEntry{
+			{name: "a.txt", content: "123456"},
+			{name: "existing.txt", content: "123456"},
+		}, map[string]string{"a.txt": "123456", "existing.txt": "stale content"}, true},
+		{"too many entries", many, map[string]string{"existing.txt": "stale content"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "lens-unzip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			var dir = filepath.Join(root, "models")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, "existing.txt"), []byte("stale content"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var zipfile = filepath.Join(root, "model.zip")
+			writeZip(t, zipfile, tt.entries)
+
+			if err := unzip(dir, zipfile, 10); (err != nil) != tt.wantErr {
+				t.Errorf("unzip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// check the model directory contains exactly the expected files,
+			// and that nothing was written outside of it
+			var got = make(map[string]string)
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err == nil && info.Mode().IsRegular() {
+					var rel, _ = filepath.Rel(dir, path)
+					b, _ := ioutil.ReadFile(path)
+					got[filepath.ToSlash(rel)] = string(b)
+				}
+				return nil
+			})
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("unzip() extracted %v, want %v", got, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(root, "evil.txt")); err == nil {
+				t.Error("unzip() wrote outside of the model directory")
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+// Package markdown provides analysis of Markdown documents
+package markdown
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	sourcecode "github.com/RTradeLtd/Lens/v2/analyzer/code"
+	"github.com/RTradeLtd/Lens/v2/analyzer/html"
+)
+
+// Analyzer renders Markdown documents as plain text
+type Analyzer struct{}
+
+// NewAnalyzer instantiates a Markdown analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze strips Markdown syntax from the document, and extracts its headings,
+// links to IPFS content, and the title, tags and date from its YAML front
+// matter. Documents are recognized by their mime type or name, by starting with
+// front matter, or by starting with a heading and using other Markdown syntax -
+// other content is not supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	if !detect(in) {
+		return nil, analyzer.ErrUnsupported
+	}
+	var body, fm = frontMatter(in.Content)
+
+	var d = &document{seen: make(map[string]bool), blank: true}
+	d.render(body)
+
+	var result = &analyzer.Result{
+		Content:    strings.TrimSpace(d.text.String()),
+		MimeType:   "text/markdown",
+		Headings:   d.headings,
+		References: d.refs,
+	}
+	if fm != nil {
+		var attrs = make(map[string]string)
+		if title := scalar(fm["title"]); title != "" {
+			result.DisplayName = title
+			attrs["title"] = title
+		}
+		for _, key := range []string{"author", "date"} {
+			if v := scalar(fm[key]); v != "" {
+				attrs[key] = v
+			}
+		}
+		result.Tags = list(fm["tags"])
+		if len(attrs) > 0 {
+			result.Attributes = attrs
+		}
+	}
+	return result, nil
+}
+
+// extensions are the file extensions used by Markdown documents
+var extensions = map[string]bool{
+	".md": true, ".markdown": true, ".mdown": true, ".mkd": true,
+}
+
+// others are the extensions of other formats that may resemble Markdown, ie
+// YAML documents that look like front matter
+var others = map[string]bool{
+	".txt": true, ".html": true, ".htm": true, ".xml": true, ".json": true,
+	".yaml": true, ".yml": true, ".csv": true, ".tsv": true,
+}
+
+// atxHeading matches headings such as "## Usage ##"
+var atxHeading = regexp.MustCompile(`^#{1,6}(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+
+// detect checks if the given content is a Markdown document
+func detect(in analyzer.Input) bool {
+	switch analyzer.MediaType(in.MimeType) {
+	case "text/markdown", "text/x-markdown":
+		return true
+	}
+	// names may be display names rather than file names, ie "notes v1.2", so
+	// only known extensions are trusted
+	switch ext := strings.ToLower(path.Ext(in.Name)); {
+	case extensions[ext]:
+		return true
+	case others[ext]:
+		return false
+	}
+	if _, ok := sourcecode.Detect(in.Name, nil); ok {
+		return false
+	}
+	var trimmed = bytes.TrimLeft(in.Content, " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("---\n")) {
+		_, fm := frontMatter(trimmed)
+		return fm != nil
+	}
+	var first = trimmed
+	if i := bytes.IndexByte(trimmed, '\n'); i >= 0 {
+		first = trimmed[:i]
+	}
+	if !bytes.HasPrefix(first, []byte("#")) || !atxHeading.Match(bytes.TrimSpace(first)) {
+		return false
+	}
+
+	// scripts often start with a comment that looks like a heading
+	if _, ok := sourcecode.Detect("", trimmed); ok {
+		return false
+	}
+	return hasSyntax(string(trimmed))
+}
+
+// hasSyntax checks if the given content uses Markdown syntax other than its
+// first heading, such as lists, links, emphasis or further headings
+func hasSyntax(content string) bool {
+	var lines = strings.Split(content, "\n")
+	for i, line := range lines {
+		var trimmed = strings.TrimSpace(line)
+		if i > 0 {
+			switch {
+			case fence.MatchString(trimmed),
+				listMarker.MatchString(trimmed),
+				strings.HasPrefix(trimmed, "> "),
+				tableDivider.MatchString(trimmed) && strings.Contains(trimmed, "|"),
+				setextUnderline.MatchString(trimmed) && strings.TrimSpace(lines[i-1]) != "",
+				// headings are usually set apart by blank lines, unlike
+				// consecutive comment lines
+				atxHeading.MatchString(trimmed) && strings.HasPrefix(trimmed, "#") &&
+					strings.TrimSpace(lines[i-1]) == "":
+				return true
+			}
+		}
+		for _, re := range []*regexp.Regexp{link, refLink, autolink, code, emphasis, italic} {
+			if re.MatchString(trimmed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// frontMatter separates YAML front matter, delimited by "---" lines, from the
+// body of the document
+func frontMatter(content []byte) ([]byte, map[string]interface{}) {
+	var normalized = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+	if !bytes.HasPrefix(normalized, []byte("---\n")) {
+		return normalized, nil
+	}
+	var end = bytes.Index(normalized[4:], []byte("\n---"))
+	if end < 0 {
+		return normalized, nil
+	}
+	var fm map[string]interface{}
+	if err := yaml.Unmarshal(normalized[4:4+end], &fm); err != nil || fm == nil {
+		return normalized, nil
+	}
+	var body = normalized[4+end+4:]
+	if i := bytes.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = nil
+	}
+	return body, fm
+}
+
+// scalar formats a front matter value
+func scalar(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if s.Hour() == 0 && s.Minute() == 0 && s.Second() == 0 {
+			return s.Format("2006-01-02")
+		}
+		return s.Format(time.RFC3339)
+	default:
+		return strings.TrimSpace(fmt.Sprint(s))
+	}
+}
+
+// list formats a front matter value that may be a list or a comma-separated
+// string
+func list(v interface{}) []string {
+	var items []string
+	switch l := v.(type) {
+	case []interface{}:
+		for _, item := range l {
+			items = append(items, scalar(item))
+		}
+	case string:
+		items = strings.Split(l, ",")
+	}
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+var (
+	setextUnderline = regexp.MustCompile(`^(=+|-+)\s*$`)
+	rule            = regexp.MustCompile(`^((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	fence           = regexp.MustCompile("^(```|~~~)")
+	listMarker      = regexp.MustCompile(`^([-*+]|\d+[.)])\s+(\[[ xX]\]\s+)?`)
+	tableDivider    = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+	definition      = regexp.MustCompile(`^\[[^\]]+\]:\s*<?(\S+?)>?(\s+.*)?$`)
+
+	image     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]*)[^)]*\)`)
+	link      = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]*)[^)]*\)`)
+	refLink   = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	autolink  = regexp.MustCompile(`<((?:https?|ipfs|ipns)://[^>\s]+)>`)
+	code      = regexp.MustCompile("`+([^`]*)`+")
+	tag       = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	emphasis  = regexp.MustCompile(`(\*\*|__|~~)(\S(?:.*?\S)?)(\*\*|__|~~)`)
+	italic    = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:.*?\S)?)[*_]([^\w*]|$)`)
+	backslash = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|>])`)
+)
+
+// document accumulates the contents of a rendered Markdown document
+type document struct {
+	text     strings.Builder
+	headings []string
+	refs     []string
+	seen     map[string]bool
+
+	// blank is set if the text is empty or ends with a blank line
+	blank bool
+}
+
+// render renders the given Markdown as plain text, line by line
+func (d *document) render(body []byte) {
+	var (
+		lines  = strings.Split(string(body), "\n")
+		fenced string
+	)
+	for i := 0; i < len(lines); i++ {
+		var line = strings.TrimRight(lines[i], " \t")
+
+		// code blocks are kept as they are
+		if fenced != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fenced) {
+				fenced = ""
+			} else {
+				d.line(line)
+			}
+			continue
+		}
+		var trimmed = strings.TrimSpace(line)
+		if m := fence.FindString(trimmed); m != "" {
+			fenced = m
+			continue
+		}
+
+		// strip block quotes
+		for strings.HasPrefix(trimmed, ">") {
+			trimmed = strings.TrimSpace(trimmed[1:])
+		}
+
+		switch {
+		case trimmed == "":
+			d.line("")
+		case atxHeading.MatchString(trimmed) && strings.HasPrefix(trimmed, "#"):
+			d.heading(atxHeading.FindStringSubmatch(trimmed)[1])
+		case i+1 < len(lines) && setextUnderline.MatchString(strings.TrimSpace(lines[i+1])) &&
+			!listMarker.MatchString(trimmed):
+			d.heading(trimmed)
+			i++
+		case rule.MatchString(trimmed), tableDivider.MatchString(trimmed) && strings.Contains(trimmed, "-"):
+			// horizontal rules and table dividers have no content
+		case definition.MatchString(trimmed):
+			d.reference(definition.FindStringSubmatch(trimmed)[1])
+		default:
+			trimmed = listMarker.ReplaceAllString(trimmed, "")
+			if strings.HasPrefix(trimmed, "|") || strings.HasSuffix(trimmed, "|") {
+				trimmed = strings.Trim(trimmed, "|")
+				trimmed = strings.Join(strings.Fields(strings.Replace(trimmed, "|", " ", -1)), " ")
+			}
+			d.line(d.inline(trimmed))
+		}
+	}
+}
+
+// inline strips inline syntax from the given text, recording links
+func (d *document) inline(s string) string {
+	for _, m := range link.FindAllStringSubmatch(s, -1) {
+		d.reference(m[2])
+	}
+	for _, m := range autolink.FindAllStringSubmatch(s, -1) {
+		d.reference(m[1])
+	}
+	s = code.ReplaceAllString(s, "$1")
+	s = image.ReplaceAllString(s, "$1")
+	s = link.ReplaceAllString(s, "$1")
+	s = refLink.ReplaceAllString(s, "$1")
+	s = autolink.ReplaceAllString(s, "$1")
+	s = tag.ReplaceAllString(s, "")
+	s = emphasis.ReplaceAllString(s, "$2")
+	s = italic.ReplaceAllString(s, "$1$2$3")
+	s = backslash.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(s)
+}
+
+// heading records a heading, which is also part of the content
+func (d *document) heading(s string) {
+	if s = d.inline(s); s != "" {
+		d.headings = append(d.headings, s)
+		d.line(s)
+	}
+}
+
+// reference records a link if it refers to IPFS content
+func (d *document) reference(href string) {
+	if ref, ok := html.Reference(href); ok && !d.seen[ref] {
+		d.seen[ref] = true
+		d.refs = append(d.refs, ref)
+	}
+}
+
+// line appends a line of text, collapsing consecutive blank lines
+func (d *document) line(s string) {
+	if s == "" && d.blank {
+		return
+	}
+	d.text.WriteString(s)
+	d.text.WriteByte('\n')
+	d.blank = s == ""
+}
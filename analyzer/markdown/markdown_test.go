@@ -0,0 +1,142 @@
+package markdown_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/markdown"
+)
+
+const testDocument = `---
+title: Getting Started
+tags: [ipfs, guide]
+date: 2019-07-01
+author: bob
+---
+
+Introduction
+============
+
+Welcome to **Lens**, a _search engine_ for the [distributed web](https://temporal.cloud).
+See the [spec](ipfs://QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW/spec.md)
+or ![a diagram](/ipfs/QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW) and [the docs][docs].
+
+## Usage ##
+
+- run ` + "`lens v2`" + `
+- [x] search for my_snake_case things
+> quoted <em>text</em>
+
+` + "```sh" + `
+# not a heading
+$ lens --help
+` + "```" + `
+
+| flag | description |
+|------|-------------|
+| dev  | dev mode    |
+
+***
+
+[docs]: https://ipfs.io/ipfs/bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme "Docs"
+`
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      analyzer.Input
+		want    *analyzer.Result
+		wantErr error
+	}{
+		{"not markdown",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("hello world")},
+			nil, analyzer.ErrUnsupported},
+		{"other extension",
+			analyzer.Input{Name: "notes.txt", Content: []byte("# hello world")},
+			nil, analyzer.ErrUnsupported},
+		{"hashtag is not a heading",
+			analyzer.Input{Content: []byte("#ipfs is cool")},
+			nil, analyzer.ErrUnsupported},
+		{"display name with dot",
+			analyzer.Input{Name: "notes v1.2", Content: []byte("# Hello *World*\n\nsome text\n")},
+			&analyzer.Result{
+				Content:  "Hello World\n\nsome text",
+				MimeType: "text/markdown",
+				Headings: []string{"Hello World"},
+			}, nil},
+		{"code extension",
+			analyzer.Input{Name: "build.sh", Content: []byte("# Build *everything*\n\nmake all\n")},
+			nil, analyzer.ErrUnsupported},
+		{"yaml documents",
+			analyzer.Input{Name: "config.yml", Content: []byte("---\ntitle: a\n---\ntitle: b\n")},
+			nil, analyzer.ErrUnsupported},
+		{"python script with comment",
+			analyzer.Input{Content: []byte("# Copyright 2018 RTrade\nimport os\n\ndef main():\n    pass\n")},
+			nil, analyzer.ErrUnsupported},
+		{"shell script with comment",
+			analyzer.Input{Content: []byte("# build script\nset -e\ngo build ./...\n")},
+			nil, analyzer.ErrUnsupported},
+		{"heading without other syntax",
+			analyzer.Input{Content: []byte("# notes\n# more notes\nsome text\n")},
+			nil, analyzer.ErrUnsupported},
+		{"heading",
+			analyzer.Input{Content: []byte("# Hello *World*\n\nsome text\n")},
+			&analyzer.Result{
+				Content:  "Hello World\n\nsome text",
+				MimeType: "text/markdown",
+				Headings: []string{"Hello World"},
+			}, nil},
+		{"document",
+			analyzer.Input{Name: "README.md", Content: []byte(testDocument)},
+			&analyzer.Result{
+				Content: "Introduction\n\n" +
+					"Welcome to Lens, a search engine for the distributed web.\n" +
+					"See the spec\n" +
+					"or a diagram and the docs.\n\n" +
+					"Usage\n\n" +
+					"run lens v2\n" +
+					"search for my_snake_case things\n" +
+					"quoted text\n\n" +
+					"# not a heading\n" +
+					"$ lens --help\n\n" +
+					"flag description\n" +
+					"dev dev mode",
+				MimeType:    "text/markdown",
+				DisplayName: "Getting Started",
+				Tags:        []string{"ipfs", "guide"},
+				Headings:    []string{"Introduction", "Usage"},
+				References: []string{
+					"QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW",
+					"bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme",
+				},
+				Attributes: map[string]string{
+					"title":  "Getting Started",
+					"author": "bob",
+					"date":   "2019-07-01",
+				},
+			}, nil},
+		{"front matter with comma-separated tags",
+			analyzer.Input{Content: []byte("---\ntitle: Notes\ntags: a, b\n---\ntext")},
+			&analyzer.Result{
+				Content:     "text",
+				MimeType:    "text/markdown",
+				DisplayName: "Notes",
+				Tags:        []string{"a", "b"},
+				Attributes:  map[string]string{"title": "Notes"},
+			}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := markdown.NewAnalyzer().Analyze(context.Background(), tt.in)
+			if err != tt.wantErr {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package media
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"time"
+)
+
+// EBML element IDs used by Matroska and WebM
+const (
+	ebmlHeader       = 0x1A45DFA3
+	ebmlDocType      = 0x4282
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeSc    = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvTitle         = 0x7BA9
+	mkvTracks        = 0x1654AE6B
+	mkvTrackEntry    = 0xAE
+	mkvTrackNumber   = 0xD7
+	mkvTrackType     = 0x83
+	mkvCodecID       = 0x86
+	mkvVideo         = 0xE0
+	mkvPixelWidth    = 0xB0
+	mkvPixelHeight   = 0xBA
+	mkvTags          = 0x1254C367
+	mkvTag           = 0x7373
+	mkvSimpleTag     = 0x67C8
+	mkvTagName       = 0x45A3
+	mkvTagString     = 0x4487
+	mkvCluster       = 0x1F43B675
+	mkvBlockGroup    = 0xA0
+	mkvBlock         = 0xA1
+	mkvSimpleBlock   = 0xA3
+	mkvTrackVideo    = 1
+	mkvTrackAudio    = 2
+	mkvTrackSubtitle = 17
+)
+
+// mkvCodecs maps Matroska codec IDs to codec names
+var mkvCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC": "h264", "V_MPEGH/ISO/HEVC": "hevc", "V_VP8": "vp8",
+	"V_VP9": "vp9", "V_AV1": "av1", "A_AAC": "aac", "A_OPUS": "opus",
+	"A_VORBIS": "vorbis", "A_FLAC": "flac", "A_MPEG/L3": "mp3", "A_AC3": "ac3",
+	"A_EAC3": "eac3",
+}
+
+// isMatroska checks for an EBML header
+func isMatroska(content []byte) bool {
+	return len(content) >= 4 && binary.BigEndian.Uint32(content) == ebmlHeader
+}
+
+// mkvTrack denotes a track of a Matroska file
+type mkvTrack struct {
+	kind  uint64
+	codec string
+}
+
+// matroska accumulates the details of a Matroska file as it is read
+type matroska struct {
+	md       *metadata
+	scale    uint64
+	duration float64
+	tracks   map[uint64]mkvTrack
+}
+
+// parseMatroska reads the segment information, tracks and tags of Matroska
+// and WebM files. Blocks of text subtitle tracks are read as subtitles, and
+// all other blocks are skipped.
+func parseMatroska(content []byte) *metadata {
+	var m = &matroska{
+		md:     &metadata{format: FormatMatroska, mimeType: "video/x-matroska"},
+		scale:  1000000,
+		tracks: make(map[uint64]mkvTrack),
+	}
+	m.walk(content, 0)
+	if m.duration > 0 {
+		m.md.duration = time.Duration(m.duration * float64(m.scale))
+	}
+	if !m.md.video {
+		if m.md.format == FormatWebM {
+			m.md.mimeType = "audio/webm"
+		} else {
+			m.md.mimeType = "audio/x-matroska"
+		}
+	}
+	return m.md
+}
+
+// walk reads the elements in b. Elements are identified regardless of their
+// position, and elements of unknown size, which are used when streaming, are
+// treated as if their children follow them directly.
+func (m *matroska) walk(b []byte, depth int) {
+	for len(b) > 0 && depth < 8 {
+		id, body, rest, ok := ebmlElement(b)
+		if !ok {
+			return
+		}
+		b = rest
+		if body == nil {
+			continue // unknown size
+		}
+		switch id {
+		case ebmlHeader, mkvSegment, mkvInfo, mkvTracks, mkvTags, mkvTag,
+			mkvCluster, mkvBlockGroup:
+			m.walk(body, depth+1)
+		case ebmlDocType:
+			if string(body) == "webm" {
+				m.md.format, m.md.mimeType = FormatWebM, "video/webm"
+			}
+		case mkvTimecodeSc:
+			if s := ebmlUint(body); s > 0 {
+				m.scale = s
+			}
+		case mkvDuration:
+			m.duration = ebmlFloat(body)
+		case mkvTitle:
+			m.md.set("TITLE", string(body))
+		case mkvTrackEntry:
+			m.track(body)
+		case mkvSimpleTag:
+			m.tag(body)
+		case mkvBlock, mkvSimpleBlock:
+			m.block(body)
+		}
+	}
+}
+
+// track records the details of a track entry
+func (m *matroska) track(b []byte) {
+	var (
+		number        uint64
+		t             mkvTrack
+		width, height uint64
+	)
+	for len(b) > 0 {
+		id, body, rest, ok := ebmlElement(b)
+		if !ok || body == nil {
+			break
+		}
+		b = rest
+		switch id {
+		case mkvTrackNumber:
+			number = ebmlUint(body)
+		case mkvTrackType:
+			t.kind = ebmlUint(body)
+		case mkvCodecID:
+			t.codec = string(body)
+		case mkvVideo:
+			for v := body; len(v) > 0; {
+				vid, vbody, vrest, ok := ebmlElement(v)
+				if !ok || vbody == nil {
+					break
+				}
+				v = vrest
+				switch vid {
+				case mkvPixelWidth:
+					width = ebmlUint(vbody)
+				case mkvPixelHeight:
+					height = ebmlUint(vbody)
+				}
+			}
+		}
+	}
+	m.tracks[number] = t
+
+	switch t.kind {
+	case mkvTrackVideo:
+		m.md.video = true
+		if width > 0 && height > 0 && m.md.width == 0 {
+			m.md.width, m.md.height = int(width), int(height)
+		}
+		fallthrough
+	case mkvTrackAudio:
+		if c, ok := mkvCodecs[t.codec]; ok {
+			m.md.codec(c)
+		} else {
+			m.md.codec(strings.ToLower(t.codec))
+		}
+	}
+}
+
+// tag records the value of a simple tag
+func (m *matroska) tag(b []byte) {
+	var name, value string
+	for len(b) > 0 {
+		id, body, rest, ok := ebmlElement(b)
+		if !ok || body == nil {
+			break
+		}
+		b = rest
+		switch id {
+		case mkvTagName:
+			name = string(body)
+		case mkvTagString:
+			value = string(body)
+		}
+	}
+	m.md.set(name, value)
+}
+
+// block records the text of blocks of text subtitle tracks
+func (m *matroska) block(b []byte) {
+	number, n := ebmlVint(b, false)
+	if n == 0 || len(b) < n+3 {
+		return
+	}
+	var t, ok = m.tracks[number]
+	if !ok || t.kind != mkvTrackSubtitle || b[n+2]&0x06 != 0 { // laced
+		return
+	}
+	var text = string(b[n+3:])
+	switch t.codec {
+	case "S_TEXT/UTF8", "S_TEXT/WEBVTT":
+	case "S_TEXT/ASS", "S_TEXT/SSA":
+		// ReadOrder, Layer, Style, Name, MarginL, MarginR, MarginV, Effect, Text
+		var fields = strings.SplitN(text, ",", 9)
+		if len(fields) < 9 {
+			return
+		}
+		text = fields[8]
+	default:
+		return
+	}
+	if text = subtitleText(text); text != "" {
+		m.md.subtitles = append(m.md.subtitles, text)
+	}
+}
+
+// ebmlElement reads the element at the start of b, returning its ID, its
+// body, and the data following it. The body is nil if the element's size is
+// unknown, in which case the rest of the data follows its header.
+func ebmlElement(b []byte) (id uint64, body, rest []byte, ok bool) {
+	id, n := ebmlVint(b, true)
+	if n == 0 {
+		return 0, nil, nil, false
+	}
+	size, m := ebmlVint(b[n:], false)
+	if m == 0 {
+		return 0, nil, nil, false
+	}
+	var start = n + m
+	if size == 1<<(7*uint(m))-1 { // unknown size
+		return id, nil, b[start:], true
+	}
+	if size > uint64(len(b)-start) {
+		return 0, nil, nil, false
+	}
+	return id, b[start : start+int(size)], b[start+int(size):], true
+}
+
+// ebmlVint reads a variable-length integer, returning its value and length.
+// The length marker is kept for element IDs.
+func ebmlVint(b []byte, keepMarker bool) (uint64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	var n = 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 || n > len(b) {
+		return 0, 0
+	}
+	var v = uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> uint(n))
+	}
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n
+}
+
+// ebmlUint reads an unsigned integer element
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b[:min(len(b), 8)] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// ebmlFloat reads a 4 or 8 byte float element
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+// Package media provides analysis of audio and video files, such as MP3, FLAC,
+// Ogg, MP4 and Matroska, using the metadata in their containers
+package media
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// Supported formats, as recorded in the "format" attribute
+const (
+	FormatMP3      = "mp3"
+	FormatFLAC     = "flac"
+	FormatOgg      = "ogg"
+	FormatMP4      = "mp4"
+	FormatMOV      = "mov"
+	FormatMatroska = "matroska"
+	FormatWebM     = "webm"
+)
+
+// Analyzer reads the tags, technical details and embedded lyrics and
+// subtitles of audio and video files. Media streams themselves are not
+// decoded.
+type Analyzer struct{}
+
+// NewAnalyzer instantiates a media analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze detects the container format of the content, and indexes its title,
+// artist, album, lyrics and subtitles as content. Tags and technical details,
+// such as the duration and codecs, are recorded as attributes. Content in
+// other formats is not supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	var md *metadata
+	switch {
+	case isFLAC(in.Content):
+		md = parseFLAC(in.Content)
+	case isOgg(in.Content):
+		md = parseOgg(in.Content)
+	case isMatroska(in.Content):
+		md = parseMatroska(in.Content)
+	case isMP4(in.Content):
+		md = parseMP4(in.Content)
+	case isMP3(in.Content):
+		md = parseMP3(in.Content)
+	}
+	if md == nil {
+		return nil, analyzer.ErrUnsupported
+	}
+	return md.result(), nil
+}
+
+// metadata is the information found in a media container
+type metadata struct {
+	format   string
+	mimeType string
+	video    bool
+
+	title  string
+	artist string
+	album  string
+	year   string
+	genre  string
+
+	duration time.Duration
+	codecs   []string
+	width    int
+	height   int
+
+	lyrics    []string
+	comments  []string
+	subtitles []string
+}
+
+// set records a tag using its common name, ie "TITLE" or "ARTIST" in Vorbis
+// comments and Matroska tags. Only the first value of each tag is used.
+func (md *metadata) set(name, value string) {
+	if value = strings.TrimSpace(value); value == "" {
+		return
+	}
+	var first = func(s *string) {
+		if *s == "" {
+			*s = value
+		}
+	}
+	switch strings.ToUpper(name) {
+	case "TITLE":
+		first(&md.title)
+	case "ARTIST", "PERFORMER":
+		first(&md.artist)
+	case "ALBUM":
+		first(&md.album)
+	case "DATE", "YEAR", "DATE_RELEASED", "DATE_RECORDED":
+		if len(value) >= 4 {
+			if _, err := strconv.Atoi(value[:4]); err == nil {
+				value = value[:4]
+				first(&md.year)
+			}
+		}
+	case "GENRE":
+		first(&md.genre)
+	case "LYRICS", "UNSYNCEDLYRICS":
+		md.lyrics = append(md.lyrics, value)
+	case "DESCRIPTION", "COMMENT", "SUMMARY":
+		md.comments = append(md.comments, value)
+	}
+}
+
+// codec records the name of a stream's codec, if it has not been seen before
+func (md *metadata) codec(name string) {
+	if name == "" {
+		return
+	}
+	for _, c := range md.codecs {
+		if c == name {
+			return
+		}
+	}
+	md.codecs = append(md.codecs, name)
+}
+
+func (md *metadata) result() *analyzer.Result {
+	var lines []string
+	for _, s := range []string{md.title, md.artist, md.album, md.genre} {
+		if s != "" {
+			lines = append(lines, s)
+		}
+	}
+	lines = append(lines, md.comments...)
+	lines = append(lines, md.lyrics...)
+	lines = append(lines, md.subtitles...)
+
+	var attrs = map[string]string{"format": md.format}
+	var set = func(k, v string) {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+	set("title", md.title)
+	set("artist", md.artist)
+	set("album", md.album)
+	set("year", md.year)
+	set("genre", md.genre)
+	set("codecs", strings.Join(md.codecs, ", "))
+	if seconds := int64(md.duration.Round(time.Second) / time.Second); seconds > 0 {
+		set("duration", strconv.FormatInt(seconds, 10))
+	}
+	if md.width > 0 && md.height > 0 {
+		set("width", strconv.Itoa(md.width))
+		set("height", strconv.Itoa(md.height))
+	}
+
+	var category = models.MimeTypeAudio
+	if md.video {
+		category = models.MimeTypeVideo
+	}
+	var tags []string
+	if md.genre != "" {
+		tags = []string{strings.ToLower(md.genre)}
+	}
+	return &analyzer.Result{
+		Content:     strings.Join(lines, "\n"),
+		Category:    category,
+		MimeType:    md.mimeType,
+		DisplayName: md.title,
+		Tags:        tags,
+		Attributes:  attrs,
+	}
+}
+
+var (
+	markupTags   = regexp.MustCompile(`<[^>]*>`)
+	assOverrides = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// subtitleText strips formatting from the text of a subtitle
+func subtitleText(s string) string {
+	s = markupTags.ReplaceAllString(s, "")
+	s = assOverrides.ReplaceAllString(s, "")
+	s = strings.NewReplacer(`\N`, " ", `\n`, " ", "\r", "", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,383 @@
+package media_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/media"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    *analyzer.Result
+		wantErr bool
+	}{
+		{"unsupported", []byte("hello world"), nil, true},
+		{"unsupported: mpeg frame sync only", []byte{0xFF, 0xFB, 0x90, 0x64, 1, 2, 3}, nil, true},
+		{"mp3 with id3v2 and xing header", newMP3(true), &analyzer.Result{
+			Content:     "Harbour Lights\nThe Dockers\nQuayside\nRock\nthe boats come in",
+			Category:    models.MimeTypeAudio,
+			MimeType:    "audio/mpeg",
+			DisplayName: "Harbour Lights",
+			Tags:        []string{"rock"},
+			Attributes: map[string]string{
+				"format":   "mp3",
+				"title":    "Harbour Lights",
+				"artist":   "The Dockers",
+				"album":    "Quayside",
+				"year":     "1999",
+				"genre":    "Rock",
+				"codecs":   "mp3",
+				"duration": "26",
+			},
+		}, false},
+		{"mp3 with id3v1", newMP3(false), &analyzer.Result{
+			Content:     "Old Song\nOld Band\nOld Album\nJazz",
+			Category:    models.MimeTypeAudio,
+			MimeType:    "audio/mpeg",
+			DisplayName: "Old Song",
+			Tags:        []string{"jazz"},
+			Attributes: map[string]string{
+				"format":   "mp3",
+				"title":    "Old Song",
+				"artist":   "Old Band",
+				"album":    "Old Album",
+				"year":     "1985",
+				"genre":    "Jazz",
+				"codecs":   "mp3",
+				"duration": "1",
+			},
+		}, false},
+		{"flac", newFLAC(), &analyzer.Result{
+			Content:     "Tide\nWaves\nSea shanty\nheave away",
+			Category:    models.MimeTypeAudio,
+			MimeType:    "audio/flac",
+			DisplayName: "Tide",
+			Tags:        []string{"sea shanty"},
+			Attributes: map[string]string{
+				"format":   "flac",
+				"title":    "Tide",
+				"artist":   "Waves",
+				"genre":    "Sea shanty",
+				"year":     "2018",
+				"codecs":   "flac",
+				"duration": "30",
+			},
+		}, false},
+		{"ogg opus", newOgg(), &analyzer.Result{
+			Content:     "Podcast 42\nLens Radio\nall about search",
+			Category:    models.MimeTypeAudio,
+			MimeType:    "audio/opus",
+			DisplayName: "Podcast 42",
+			Attributes: map[string]string{
+				"format":   "ogg",
+				"title":    "Podcast 42",
+				"artist":   "Lens Radio",
+				"codecs":   "opus",
+				"duration": "5",
+			},
+		}, false},
+		{"mp4 video with subtitles", newMP4(true), &analyzer.Result{
+			Content:     "Launch Day\nRTrade\nDocumentary\nHello and welcome\nto the launch",
+			Category:    models.MimeTypeVideo,
+			MimeType:    "video/mp4",
+			DisplayName: "Launch Day",
+			Tags:        []string{"documentary"},
+			Attributes: map[string]string{
+				"format":   "mp4",
+				"title":    "Launch Day",
+				"artist":   "RTrade",
+				"genre":    "Documentary",
+				"codecs":   "aac, h264",
+				"duration": "90",
+				"width":    "1920",
+				"height":   "1080",
+			},
+		}, false},
+		{"mp4 audio", newMP4(false), &analyzer.Result{
+			Content:     "Launch Day\nRTrade",
+			Category:    models.MimeTypeAudio,
+			MimeType:    "audio/mp4",
+			DisplayName: "Launch Day",
+			Attributes: map[string]string{
+				"format":   "mp4",
+				"title":    "Launch Day",
+				"artist":   "RTrade",
+				"codecs":   "aac",
+				"duration": "90",
+			},
+		}, false},
+		{"webm with subtitles", newWebM(), &analyzer.Result{
+			Content:     "Conference Talk\nAda\nHello there\nWelcome to the talk",
+			Category:    models.MimeTypeVideo,
+			MimeType:    "video/webm",
+			DisplayName: "Conference Talk",
+			Attributes: map[string]string{
+				"format":   "webm",
+				"title":    "Conference Talk",
+				"artist":   "Ada",
+				"codecs":   "vp9, opus",
+				"duration": "12",
+				"width":    "640",
+				"height":   "360",
+			},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := media.NewAnalyzer().Analyze(context.Background(), analyzer.Input{Content: tt.content})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mp3Frame is the header of a 128kbps, 44.1kHz MPEG-1 Layer III frame, which
+// is 417 bytes long
+var mp3Frame = []byte{0xFF, 0xFB, 0x90, 0x64}
+
+func newMP3(v2 bool) []byte {
+	var b bytes.Buffer
+	if v2 {
+		var frames bytes.Buffer
+		var frame = func(id string, data []byte) {
+			frames.WriteString(id)
+			binary.Write(&frames, binary.BigEndian, uint32(len(data)))
+			frames.Write([]byte{0, 0})
+			frames.Write(data)
+		}
+		frame("TIT2", append([]byte{3}, "Harbour Lights"...))
+		frame("TPE1", append([]byte{1}, utf16LE("The Dockers")...))
+		frame("TALB", append([]byte{0}, "Quayside"...))
+		frame("TYER", append([]byte{0}, "1999"...))
+		frame("TCON", append([]byte{0}, "(17)"...))
+		frame("COMM", append([]byte{0}, "eng"+"iTunNORM\x00 0000"...))
+		frame("USLT", append([]byte{3}, "eng"+"\x00the boats come in"...))
+		frames.Write(make([]byte, 16)) // padding
+		var size = frames.Len()
+		b.WriteString("ID3\x03\x00\x00")
+		b.Write([]byte{byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)})
+		b.Write(frames.Bytes())
+
+		// first frame is a Xing header declaring 1000 frames
+		var xing = make([]byte, 417)
+		copy(xing, mp3Frame)
+		copy(xing[36:], "Xing\x00\x00\x00\x01")
+		binary.BigEndian.PutUint32(xing[44:], 1000)
+		b.Write(xing)
+	}
+	for i := 0; i < 40; i++ {
+		var frame = make([]byte, 417)
+		copy(frame, mp3Frame)
+		b.Write(frame)
+	}
+	if !v2 {
+		var tag = make([]byte, 128)
+		copy(tag, "TAG")
+		copy(tag[3:], "Old Song")
+		copy(tag[33:], "Old Band")
+		copy(tag[63:], "Old Album")
+		copy(tag[93:], "1985")
+		tag[127] = 8
+		b.Write(tag)
+	}
+	return b.Bytes()
+}
+
+func utf16LE(s string) []byte {
+	var b = []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+func vorbisComment(comments ...string) []byte {
+	var b bytes.Buffer
+	var str = func(s string) {
+		binary.Write(&b, binary.LittleEndian, uint32(len(s)))
+		b.WriteString(s)
+	}
+	str("lens test")
+	binary.Write(&b, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		str(c)
+	}
+	return b.Bytes()
+}
+
+func newFLAC() []byte {
+	var b bytes.Buffer
+	b.WriteString("fLaC")
+
+	// 44.1kHz stereo 16-bit, 30 seconds of samples
+	var info = make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|44100*30)
+	b.Write([]byte{0, 0, 0, 34})
+	b.Write(info)
+
+	var comments = vorbisComment("title=Tide", "ARTIST=Waves", "GENRE=Sea shanty",
+		"DATE=2018-06-01", "LYRICS=heave away", "broken")
+	b.Write([]byte{0x84, 0, byte(len(comments) >> 8), byte(len(comments))})
+	b.Write(comments)
+	return b.Bytes()
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("OggS\x00\x00")
+	binary.Write(&b, binary.LittleEndian, granule)
+	b.Write([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}) // serial, sequence, checksum
+	var lacing []byte
+	for l := len(packet); ; l -= 255 {
+		if l < 255 {
+			lacing = append(lacing, byte(l))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+	b.WriteByte(byte(len(lacing)))
+	b.Write(lacing)
+	b.Write(packet)
+	return b.Bytes()
+}
+
+func newOgg() []byte {
+	var head = []byte("OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+	var tags = append([]byte("OpusTags"), vorbisComment("TITLE=Podcast 42", "ARTIST=Lens Radio",
+		"DESCRIPTION=all about search", "PADDING="+string(make([]byte, 300)))...)
+	var b bytes.Buffer
+	b.Write(oggPage(0, head))
+	b.Write(oggPage(0, tags))
+	b.Write(oggPage(312+48000*5, make([]byte, 10)))
+	return b.Bytes()
+}
+
+func atom(kind string, children ...[]byte) []byte {
+	var body = bytes.Join(children, nil)
+	var b = make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], kind)
+	return append(b, body...)
+}
+
+func u32(vs ...uint32) []byte {
+	var b = make([]byte, 4*len(vs))
+	for i, v := range vs {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+func mp4Track(handler, format string, tkhd []byte, stbl ...[]byte) []byte {
+	var entry = atom(format, make([]byte, 8))
+	return atom("trak",
+		atom("tkhd", tkhd),
+		atom("mdia",
+			atom("hdlr", u32(0, 0), []byte(handler), make([]byte, 12)),
+			atom("minf", atom("stbl", append([][]byte{
+				atom("stsd", u32(0, 1), entry),
+			}, stbl...)...))))
+}
+
+func newMP4(video bool) []byte {
+	var ftyp = atom("ftyp", []byte("isom"), u32(0x200), []byte("isommp41"))
+	var samples = [][]byte{
+		append([]byte{0, 17}, "Hello and welcome"...),
+		append([]byte{0, 20}, "<b>to</b> the launch"...),
+	}
+	var mdat = atom("mdat", samples...)
+
+	// tkhd holds the presentation size at the end of its header
+	var tkhd = make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], 1920<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], 1080<<16)
+
+	var item = func(kind string, data []byte) []byte {
+		return atom(kind, atom("data", u32(1, 0), data))
+	}
+	var tracks = [][]byte{
+		atom("mvhd", u32(0, 0, 0, 1000, 90000), make([]byte, 80)),
+		mp4Track("soun", "mp4a", make([]byte, 84)),
+	}
+	if video {
+		var offset = uint32(len(ftyp) + 8)
+		tracks = append(tracks,
+			mp4Track("vide", "avc1", tkhd),
+			mp4Track("sbtl", "tx3g", make([]byte, 84),
+				atom("stsz", u32(0, 0, 2, uint32(len(samples[0])), uint32(len(samples[1])))),
+				atom("stsc", u32(0, 1, 1, 2, 1)),
+				atom("stco", u32(0, 1, offset))))
+	}
+	var ilst = [][]byte{
+		item("\xa9nam", []byte("Launch Day")),
+		item("\xa9ART", []byte("RTrade")),
+	}
+	if video {
+		ilst = append(ilst, item("\xa9gen", []byte("Documentary")))
+	}
+	var moov = atom("moov", append(tracks,
+		atom("udta", atom("meta", u32(0), atom("hdlr", make([]byte, 25)), atom("ilst", ilst...))))...)
+	return bytes.Join([][]byte{ftyp, mdat, moov}, nil)
+}
+
+func ebml(id uint32, children ...[]byte) []byte {
+	var body = bytes.Join(children, nil)
+	var b []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if v := byte(id >> uint(shift)); v != 0 || len(b) > 0 {
+			b = append(b, v)
+		}
+	}
+	// 8 byte sizes are always valid
+	var size = make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01
+	return append(append(b, size...), body...)
+}
+
+func newWebM() []byte {
+	var duration = make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(12000))
+	var track = func(n, kind byte, codec string, extra ...[]byte) []byte {
+		return ebml(0xAE, append([][]byte{
+			ebml(0xD7, []byte{n}), ebml(0x83, []byte{kind}), ebml(0x86, []byte(codec)),
+		}, extra...)...)
+	}
+	var block = func(track byte, text string) []byte {
+		return ebml(0xA3, []byte{0x80 | track, 0, 0, 0x80}, []byte(text))
+	}
+	return bytes.Join([][]byte{
+		ebml(0x1A45DFA3, ebml(0x4282, []byte("webm"))),
+		// the segment and cluster have unknown sizes, as when streamed
+		{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		ebml(0x1549A966,
+			ebml(0x2AD7B1, []byte{0x0F, 0x42, 0x40}),
+			ebml(0x4489, duration),
+			ebml(0x7BA9, []byte("Conference Talk"))),
+		ebml(0x1654AE6B,
+			track(1, 1, "V_VP9", ebml(0xE0, ebml(0xB0, []byte{0x02, 0x80}), ebml(0xBA, []byte{0x01, 0x68}))),
+			track(2, 2, "A_OPUS"),
+			track(3, 17, "S_TEXT/UTF8")),
+		ebml(0x1254C367, ebml(0x7373,
+			ebml(0x67C8, ebml(0x45A3, []byte("ARTIST")), ebml(0x4487, []byte("Ada"))))),
+		{0x1F, 0x43, 0xB6, 0x75, 0xFF},
+		block(1, "\x00\x01\x02"),
+		block(3, "<i>Hello</i> there"),
+		block(3, "Welcome to\nthe talk"),
+	}, nil)
+}
@@ -0,0 +1,378 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// isMP3 checks for an ID3v2 tag, or for consecutive MPEG audio frames
+func isMP3(content []byte) bool {
+	if bytes.HasPrefix(content, []byte("ID3")) && len(content) >= 10 {
+		return true
+	}
+	f, ok := parseFrameHeader(content)
+	if !ok {
+		return false
+	}
+	_, ok = parseFrameHeader(content[min(f.length, len(content)):])
+	return ok
+}
+
+// parseMP3 reads ID3v2 and ID3v1 tags, and estimates the duration of the audio
+// from its first frame
+func parseMP3(content []byte) *metadata {
+	var md = &metadata{format: FormatMP3, mimeType: "audio/mpeg"}
+	var audio = content
+	if bytes.HasPrefix(content, []byte("ID3")) {
+		audio = content[min(md.parseID3v2(content), len(content)):]
+	}
+	if len(audio) >= 128 && bytes.HasPrefix(audio[len(audio)-128:], []byte("TAG")) {
+		md.parseID3v1(audio[len(audio)-128:])
+		audio = audio[:len(audio)-128]
+	}
+
+	// find the first frame, skipping any padding after the tag
+	for i := 0; i < len(audio) && i < 64<<10; i++ {
+		if f, ok := parseFrameHeader(audio[i:]); ok {
+			md.codec("mp3")
+			if md.duration == 0 {
+				md.duration = f.duration(audio[i:])
+			}
+			break
+		}
+	}
+	return md
+}
+
+// mpegFrame denotes the header of an MPEG audio frame
+type mpegFrame struct {
+	mpeg1      bool
+	mono       bool
+	bitrate    int // bits per second
+	sampleRate int
+	samples    int // samples per frame
+	length     int // bytes, including the header
+}
+
+var (
+	bitratesV1  = [...]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	bitratesV2  = [...]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+	sampleRates = [...][3]int{
+		{11025, 12000, 8000},  // MPEG 2.5
+		{},                    // reserved
+		{22050, 24000, 16000}, // MPEG 2
+		{44100, 48000, 32000}, // MPEG 1
+	}
+)
+
+// parseFrameHeader reads the header of an MPEG-1, 2 or 2.5 Layer III frame
+func parseFrameHeader(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mpegFrame{}, false
+	}
+	var (
+		version = (b[1] >> 3) & 3
+		layer   = (b[1] >> 1) & 3
+		rate    = b[2] >> 4
+		freq    = (b[2] >> 2) & 3
+		padding = int((b[2] >> 1) & 1)
+	)
+	if version == 1 || layer != 1 || rate == 0 || rate == 15 || freq == 3 {
+		return mpegFrame{}, false
+	}
+	var f = mpegFrame{
+		mpeg1:      version == 3,
+		mono:       b[3]>>6 == 3,
+		sampleRate: sampleRates[version][freq],
+	}
+	if f.mpeg1 {
+		f.bitrate, f.samples = bitratesV1[rate]*1000, 1152
+	} else {
+		f.bitrate, f.samples = bitratesV2[rate]*1000, 576
+	}
+	f.length = f.samples/8*f.bitrate/f.sampleRate + padding
+	return f, true
+}
+
+// duration reads the number of frames from a Xing or Info header in the given
+// first frame if there is one, or otherwise assumes a constant bitrate
+func (f mpegFrame) duration(audio []byte) time.Duration {
+	var side int
+	switch {
+	case f.mpeg1 && !f.mono:
+		side = 32
+	case f.mpeg1 || !f.mono:
+		side = 17
+	default:
+		side = 9
+	}
+	if x := audio[min(4+side, len(audio)):]; len(x) >= 12 &&
+		(bytes.HasPrefix(x, []byte("Xing")) || bytes.HasPrefix(x, []byte("Info"))) &&
+		binary.BigEndian.Uint32(x[4:])&1 != 0 {
+		var frames = int64(binary.BigEndian.Uint32(x[8:]))
+		return time.Duration(frames * int64(f.samples) * int64(time.Second) / int64(f.sampleRate))
+	}
+	return time.Duration(int64(len(audio)) * 8 * int64(time.Second) / int64(f.bitrate))
+}
+
+// id3Frames maps ID3v2.2 frame IDs to their ID3v2.3 equivalents
+var id3Frames = map[string]string{
+	"TT2": "TIT2", "TP1": "TPE1", "TAL": "TALB", "TYE": "TYER",
+	"TCO": "TCON", "TLE": "TLEN", "ULT": "USLT", "COM": "COMM",
+}
+
+// parseID3v2 reads the frames of an ID3v2 tag, returning the size of the tag
+func (md *metadata) parseID3v2(b []byte) int {
+	if len(b) < 10 {
+		return len(b)
+	}
+	var (
+		major = b[3]
+		flags = b[5]
+		size  = syncsafe(b[6:10])
+		total = 10 + size
+	)
+	if flags&0x10 != 0 {
+		total += 10 // footer
+	}
+	if major < 2 || major > 4 {
+		return total
+	}
+	var body = b[10:min(total, len(b))]
+	if flags&0x80 != 0 && major < 4 {
+		body = unsynchronise(body)
+	}
+	if flags&0x40 != 0 && major > 2 && len(body) >= 4 {
+		if major == 3 {
+			body = body[min(4+int(binary.BigEndian.Uint32(body)), len(body)):]
+		} else {
+			body = body[min(syncsafe(body), len(body)):]
+		}
+	}
+
+	for len(body) > 0 && body[0] != 0 {
+		var (
+			id         string
+			frameSize  int
+			headerSize int
+			data       []byte
+		)
+		if major == 2 {
+			if len(body) < 6 {
+				break
+			}
+			id, headerSize = id3Frames[string(body[:3])], 6
+			frameSize = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		} else {
+			if len(body) < 10 {
+				break
+			}
+			id, headerSize = string(body[:4]), 10
+			if major == 4 {
+				frameSize = syncsafe(body[4:8])
+			} else {
+				frameSize = int(binary.BigEndian.Uint32(body[4:8]))
+			}
+		}
+		if frameSize < 0 || headerSize+frameSize > len(body) {
+			break
+		}
+		data = body[headerSize : headerSize+frameSize]
+		if major > 2 && !frameData(major, body[9], &data) {
+			id = ""
+		}
+		md.frame(id, data)
+		body = body[headerSize+frameSize:]
+	}
+	return total
+}
+
+// frameData applies ID3v2.3 and ID3v2.4 frame format flags to the given frame
+// data, returning false if the frame cannot be read
+func frameData(major, flags byte, data *[]byte) bool {
+	if major == 3 {
+		return flags&0xC0 == 0 // compressed or encrypted
+	}
+	if flags&0x0C != 0 {
+		return false
+	}
+	if flags&0x02 != 0 {
+		*data = unsynchronise(*data)
+	}
+	if flags&0x01 != 0 && len(*data) >= 4 {
+		*data = (*data)[4:] // data length indicator
+	}
+	return true
+}
+
+// frame records the value of an ID3v2 frame
+func (md *metadata) frame(id string, data []byte) {
+	if len(data) < 1 {
+		return
+	}
+	switch id {
+	case "TIT2":
+		md.set("TITLE", id3Text(data))
+	case "TPE1":
+		md.set("ARTIST", id3Text(data))
+	case "TALB":
+		md.set("ALBUM", id3Text(data))
+	case "TYER", "TDRC":
+		md.set("YEAR", id3Text(data))
+	case "TCON":
+		md.set("GENRE", genre(id3Text(data)))
+	case "TLEN":
+		if ms, err := strconv.Atoi(id3Text(data)); err == nil && ms > 0 {
+			md.duration = time.Duration(ms) * time.Millisecond
+		}
+	case "USLT", "COMM":
+		// encoding, language and description precede the text
+		if len(data) < 4 {
+			return
+		}
+		var enc = data[0]
+		var desc, text = splitTerminated(enc, data[4:])
+		if id == "USLT" {
+			md.set("LYRICS", decodeText(enc, text))
+		} else if !strings.HasPrefix(decodeText(enc, desc), "iTun") {
+			md.set("COMMENT", decodeText(enc, text))
+		}
+	}
+}
+
+// parseID3v1 reads a fixed-size ID3v1 tag, using fields not set by ID3v2
+func (md *metadata) parseID3v1(b []byte) {
+	var field = func(from, to int) string {
+		var f = b[from:to]
+		if i := bytes.IndexByte(f, 0); i >= 0 {
+			f = f[:i]
+		}
+		return strings.TrimSpace(decodeText(0, f))
+	}
+	md.set("TITLE", field(3, 33))
+	md.set("ARTIST", field(33, 63))
+	md.set("ALBUM", field(63, 93))
+	md.set("YEAR", field(93, 97))
+	if int(b[127]) < len(genres) {
+		md.set("GENRE", genres[b[127]])
+	}
+}
+
+// id3Text decodes the first value of a text frame
+func id3Text(data []byte) string {
+	var values = strings.Split(decodeText(data[0], data[1:]), "\x00")
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// decodeText decodes text in the given ID3v2 encoding
+func decodeText(enc byte, b []byte) string {
+	switch enc {
+	case 0: // ISO-8859-1
+		var r = make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		return strings.TrimRight(string(r), "\x00")
+	case 1, 2: // UTF-16, with a byte order mark if enc is 1
+		var order binary.ByteOrder = binary.BigEndian
+		if enc == 1 && len(b) >= 2 {
+			if b[0] == 0xFF && b[1] == 0xFE {
+				order = binary.LittleEndian
+			}
+			if (b[0] == 0xFF && b[1] == 0xFE) || (b[0] == 0xFE && b[1] == 0xFF) {
+				b = b[2:]
+			}
+		}
+		var u = make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = order.Uint16(b[2*i:])
+		}
+		return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+	default: // UTF-8
+		return strings.TrimRight(string(b), "\x00")
+	}
+}
+
+// splitTerminated splits data at the first string terminator of the given
+// encoding
+func splitTerminated(enc byte, b []byte) ([]byte, []byte) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return b[:i], b[i+2:]
+			}
+		}
+		return b, nil
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
+
+// syncsafe decodes a 28-bit integer stored in the low 7 bits of 4 bytes
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// unsynchronise reverses the ID3v2 unsynchronisation scheme, which inserts a
+// zero byte after each 0xFF byte
+func unsynchronise(b []byte) []byte {
+	return bytes.Replace(b, []byte{0xFF, 0x00}, []byte{0xFF}, -1)
+}
+
+// genre resolves ID3v1 genre references such as "(17)" or "17" in a TCON frame
+func genre(s string) string {
+	var ref = s
+	if strings.HasPrefix(s, "(") {
+		var end = strings.IndexByte(s, ')')
+		if end < 0 {
+			return s
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" {
+			return rest
+		}
+		ref = s[1:end]
+	}
+	if i, err := strconv.Atoi(ref); err == nil {
+		if i >= 0 && i < len(genres) {
+			return genres[i]
+		}
+		return ""
+	}
+	return s
+}
+
+// genres are the standard ID3v1 genres
+var genres = [...]string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,330 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// isMP4 checks for the atoms that start MP4 and QuickTime files
+func isMP4(content []byte) bool {
+	if len(content) < 8 {
+		return false
+	}
+	switch string(content[4:8]) {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip":
+		return true
+	}
+	return false
+}
+
+// mp4Codecs maps sample entry formats to codec names
+var mp4Codecs = map[string]string{
+	"avc1": "h264", "avc3": "h264", "hvc1": "hevc", "hev1": "hevc",
+	"vp09": "vp9", "av01": "av1", "mp4v": "mpeg4", "mp4a": "aac",
+	"ac-3": "ac3", "ec-3": "eac3", "Opus": "opus", "alac": "alac",
+	"fLaC": "flac", ".mp3": "mp3",
+}
+
+// mp4Tags maps iTunes-style metadata items to common tag names
+var mp4Tags = map[string]string{
+	"\xa9nam": "TITLE", "\xa9ART": "ARTIST", "\xa9alb": "ALBUM",
+	"\xa9day": "DATE", "\xa9gen": "GENRE", "\xa9lyr": "LYRICS",
+	"\xa9cmt": "COMMENT", "desc": "DESCRIPTION",
+}
+
+// mp4Track accumulates the details of a track
+type mp4Track struct {
+	handler string
+	format  string
+	width   int
+	height  int
+
+	sizes   []uint32
+	chunks  []uint64
+	samples []mp4SampleRun
+}
+
+// mp4SampleRun denotes the number of samples in each chunk, starting from the
+// given (1-based) chunk
+type mp4SampleRun struct {
+	firstChunk      uint32
+	samplesPerChunk uint32
+}
+
+// parseMP4 reads the movie header, tracks and metadata items of MP4 and
+// QuickTime files. Timed text tracks are read as subtitles.
+func parseMP4(content []byte) *metadata {
+	var (
+		md    = &metadata{format: FormatMP4, mimeType: "video/mp4"}
+		found bool
+		track *mp4Track
+	)
+	var walk func(b []byte, depth int)
+	walk = func(b []byte, depth int) {
+		for len(b) >= 8 && depth < 16 {
+			var (
+				size   = uint64(binary.BigEndian.Uint32(b))
+				kind   = string(b[4:8])
+				header = uint64(8)
+			)
+			switch size {
+			case 0:
+				size = uint64(len(b))
+			case 1:
+				if len(b) < 16 {
+					return
+				}
+				size, header = binary.BigEndian.Uint64(b[8:]), 16
+			}
+			if size < header || size > uint64(len(b)) {
+				return
+			}
+			var body = b[header:size]
+			b = b[size:]
+
+			switch kind {
+			case "ftyp":
+				if bytes.HasPrefix(body, []byte("qt  ")) {
+					md.format, md.mimeType = FormatMOV, "video/quicktime"
+				}
+			case "moov":
+				found = true
+				walk(body, depth+1)
+			case "trak":
+				track = &mp4Track{}
+				walk(body, depth+1)
+				md.addTrack(content, track)
+				track = nil
+			case "mdia", "minf", "stbl", "udta", "ilst":
+				walk(body, depth+1)
+			case "meta":
+				// MP4 meta atoms have a version and flags, QuickTime ones don't
+				if len(body) >= 4 && binary.BigEndian.Uint32(body) == 0 {
+					body = body[4:]
+				}
+				walk(body, depth+1)
+			case "mvhd":
+				md.duration = mvhdDuration(body)
+			case "tkhd":
+				if track != nil {
+					track.width, track.height = tkhdSize(body)
+				}
+			case "hdlr":
+				if track != nil && len(body) >= 12 {
+					track.handler = string(body[8:12])
+				}
+			case "stsd":
+				if track != nil && len(body) >= 16 {
+					track.format = string(body[12:16])
+				}
+			case "stsz":
+				if track != nil {
+					track.sizes = stszSizes(body)
+				}
+			case "stco", "co64":
+				if track != nil {
+					track.chunks = chunkOffsets(body, kind == "co64")
+				}
+			case "stsc":
+				if track != nil {
+					track.samples = sampleRuns(body)
+				}
+			case "data":
+				// handled by the enclosing metadata item
+			default:
+				if name, ok := mp4Tags[kind]; ok {
+					md.set(name, itemValue(body))
+				} else if kind == "gnre" {
+					if v := itemData(body); len(v) >= 2 {
+						if g := int(binary.BigEndian.Uint16(v)) - 1; g >= 0 && g < len(genres) {
+							md.set("GENRE", genres[g])
+						}
+					}
+				}
+			}
+		}
+	}
+	walk(content, 0)
+	if !found {
+		return nil
+	}
+	if !md.video && md.format == FormatMP4 {
+		md.mimeType = "audio/mp4"
+	}
+	return md
+}
+
+// addTrack records the codec and dimensions of a track, and the text of timed
+// text tracks
+func (md *metadata) addTrack(content []byte, t *mp4Track) {
+	switch t.handler {
+	case "vide":
+		md.video = true
+		md.codec(mp4Codec(t.format))
+		if t.width > 0 && t.height > 0 && md.width == 0 {
+			md.width, md.height = t.width, t.height
+		}
+	case "soun":
+		md.codec(mp4Codec(t.format))
+	case "text", "sbtl", "subt":
+		if t.format == "tx3g" || t.format == "text" {
+			md.subtitles = append(md.subtitles, t.text(content)...)
+		}
+	}
+}
+
+func mp4Codec(format string) string {
+	if c, ok := mp4Codecs[format]; ok {
+		return c
+	}
+	return strings.TrimSpace(strings.ToLower(format))
+}
+
+// text reads the samples of a timed text track, each of which is a 16-bit
+// length followed by UTF-8 text
+func (t *mp4Track) text(content []byte) []string {
+	var (
+		out    []string
+		sample int
+	)
+	for i, offset := range t.chunks {
+		// find the number of samples in this chunk
+		var n uint32
+		for _, r := range t.samples {
+			if r.firstChunk <= uint32(i+1) {
+				n = r.samplesPerChunk
+			}
+		}
+		for j := uint32(0); j < n && sample < len(t.sizes); j++ {
+			var size = uint64(t.sizes[sample])
+			sample++
+			if offset+size > uint64(len(content)) || size < 2 {
+				offset += size
+				continue
+			}
+			var s = content[offset : offset+size]
+			if l := uint64(binary.BigEndian.Uint16(s)); l > 0 && 2+l <= size {
+				if text := subtitleText(string(s[2 : 2+l])); text != "" {
+					out = append(out, text)
+				}
+			}
+			offset += size
+		}
+	}
+	return out
+}
+
+// mvhdDuration reads the duration of a movie from its header
+func mvhdDuration(b []byte) time.Duration {
+	var scale, duration uint64
+	switch {
+	case len(b) >= 20 && b[0] == 0:
+		scale, duration = uint64(binary.BigEndian.Uint32(b[12:])), uint64(binary.BigEndian.Uint32(b[16:]))
+	case len(b) >= 32 && b[0] == 1:
+		scale, duration = uint64(binary.BigEndian.Uint32(b[20:])), binary.BigEndian.Uint64(b[24:])
+	}
+	if scale == 0 {
+		return 0
+	}
+	return time.Duration(duration * uint64(time.Second) / scale)
+}
+
+// tkhdSize reads the presentation size of a track from its header
+func tkhdSize(b []byte) (int, int) {
+	var offset = 76
+	if len(b) > 0 && b[0] == 1 {
+		offset = 88
+	}
+	if len(b) < offset+8 {
+		return 0, 0
+	}
+	return int(binary.BigEndian.Uint32(b[offset:]) >> 16), int(binary.BigEndian.Uint32(b[offset+4:]) >> 16)
+}
+
+// stszSizes reads the size of each sample in a track
+func stszSizes(b []byte) []uint32 {
+	if len(b) < 12 {
+		return nil
+	}
+	var (
+		fixed = binary.BigEndian.Uint32(b[4:])
+		count = int(binary.BigEndian.Uint32(b[8:]))
+		sizes []uint32
+	)
+	if fixed == 0 && count > (len(b)-12)/4 {
+		return nil
+	}
+	if count > 1<<20 {
+		return nil
+	}
+	for i := 0; i < count; i++ {
+		if fixed != 0 {
+			sizes = append(sizes, fixed)
+		} else {
+			sizes = append(sizes, binary.BigEndian.Uint32(b[12+4*i:]))
+		}
+	}
+	return sizes
+}
+
+// chunkOffsets reads the position of each chunk of samples in a track
+func chunkOffsets(b []byte, wide bool) []uint64 {
+	if len(b) < 8 {
+		return nil
+	}
+	var (
+		count = int(binary.BigEndian.Uint32(b[4:]))
+		width = 4
+	)
+	if wide {
+		width = 8
+	}
+	if count > (len(b)-8)/width {
+		return nil
+	}
+	var offsets = make([]uint64, count)
+	for i := range offsets {
+		if wide {
+			offsets[i] = binary.BigEndian.Uint64(b[8+8*i:])
+		} else {
+			offsets[i] = uint64(binary.BigEndian.Uint32(b[8+4*i:]))
+		}
+	}
+	return offsets
+}
+
+// sampleRuns reads the sample-to-chunk table of a track
+func sampleRuns(b []byte) []mp4SampleRun {
+	if len(b) < 8 {
+		return nil
+	}
+	var count = int(binary.BigEndian.Uint32(b[4:]))
+	if count > (len(b)-8)/12 {
+		return nil
+	}
+	var runs = make([]mp4SampleRun, count)
+	for i := range runs {
+		runs[i] = mp4SampleRun{
+			firstChunk:      binary.BigEndian.Uint32(b[8+12*i:]),
+			samplesPerChunk: binary.BigEndian.Uint32(b[12+12*i:]),
+		}
+	}
+	return runs
+}
+
+// itemData reads the value of the "data" atom in a metadata item
+func itemData(b []byte) []byte {
+	if len(b) < 16 || string(b[4:8]) != "data" {
+		return nil
+	}
+	var size = int(binary.BigEndian.Uint32(b))
+	if size < 16 || size > len(b) {
+		return nil
+	}
+	return b[16:size]
+}
+
+// itemValue reads the text value of a metadata item
+func itemValue(b []byte) string { return string(itemData(b)) }
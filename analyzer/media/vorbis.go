@@ -0,0 +1,165 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// isFLAC checks for the FLAC stream marker
+func isFLAC(content []byte) bool { return bytes.HasPrefix(content, []byte("fLaC")) }
+
+// parseFLAC reads the stream info and Vorbis comment metadata blocks of a FLAC
+// stream
+func parseFLAC(content []byte) *metadata {
+	var md = &metadata{format: FormatFLAC, mimeType: "audio/flac"}
+	md.codec("flac")
+	var b = content[4:]
+	for len(b) >= 4 {
+		var (
+			last   = b[0]&0x80 != 0
+			kind   = b[0] & 0x7F
+			length = int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+		)
+		if 4+length > len(b) {
+			break
+		}
+		var block = b[4 : 4+length]
+		switch kind {
+		case 0: // STREAMINFO
+			if len(block) >= 18 {
+				var (
+					rate    = int64(block[10])<<12 | int64(block[11])<<4 | int64(block[12])>>4
+					samples = int64(block[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(block[14:]))
+				)
+				if rate > 0 {
+					md.duration = time.Duration(samples * int64(time.Second) / rate)
+				}
+			}
+		case 4: // VORBIS_COMMENT
+			md.parseVorbisComment(block)
+		}
+		if last {
+			break
+		}
+		b = b[4+length:]
+	}
+	return md
+}
+
+// isOgg checks for the capture pattern of an Ogg page
+func isOgg(content []byte) bool { return bytes.HasPrefix(content, []byte("OggS")) }
+
+// parseOgg reads the identification and comment headers of the first logical
+// stream in an Ogg container, which must be Vorbis or Opus audio, and uses the
+// position of its last page to determine its duration
+func parseOgg(content []byte) *metadata {
+	var (
+		md      = &metadata{format: FormatOgg, mimeType: "audio/ogg"}
+		serial  uint32
+		packets [][]byte
+		packet  []byte
+		granule int64
+		rate    int64
+		preSkip int64
+	)
+	for b, first := content, true; len(b) >= 27 && bytes.HasPrefix(b, []byte("OggS")); first = false {
+		var (
+			segments = int(b[26])
+			header   = 27 + segments
+		)
+		if header > len(b) {
+			break
+		}
+		var size int
+		for _, l := range b[27:header] {
+			size += int(l)
+		}
+		if header+size > len(b) {
+			break
+		}
+		var pageSerial = binary.LittleEndian.Uint32(b[14:])
+		if first {
+			serial = pageSerial
+		}
+		if pageSerial == serial {
+			if g := int64(binary.LittleEndian.Uint64(b[6:])); g > 0 {
+				granule = g
+			}
+			// reassemble the header packets from the page's segments
+			var data = b[header : header+size]
+			for _, l := range b[27:header] {
+				if len(packets) >= 2 {
+					break
+				}
+				packet = append(packet, data[:l]...)
+				data = data[l:]
+				if l < 255 {
+					packets = append(packets, packet)
+					packet = nil
+				}
+			}
+		}
+		b = b[header+size:]
+	}
+	if len(packets) < 2 {
+		return nil
+	}
+
+	var id, comments = packets[0], packets[1]
+	switch {
+	case bytes.HasPrefix(id, []byte("\x01vorbis")) && len(id) >= 16 &&
+		bytes.HasPrefix(comments, []byte("\x03vorbis")):
+		md.codec("vorbis")
+		rate = int64(binary.LittleEndian.Uint32(id[12:]))
+		md.parseVorbisComment(comments[7:])
+	case bytes.HasPrefix(id, []byte("OpusHead")) && len(id) >= 12 &&
+		bytes.HasPrefix(comments, []byte("OpusTags")):
+		// Opus positions are always counted at 48kHz
+		md.codec("opus")
+		rate, preSkip = 48000, int64(binary.LittleEndian.Uint16(id[10:]))
+		md.mimeType = "audio/opus"
+		md.parseVorbisComment(comments[8:])
+	default:
+		return nil
+	}
+	if rate > 0 && granule > preSkip {
+		md.duration = time.Duration((granule - preSkip) * int64(time.Second) / rate)
+	}
+	return md
+}
+
+// parseVorbisComment reads "NAME=value" comments, as used by Vorbis, Opus and
+// FLAC streams
+func (md *metadata) parseVorbisComment(b []byte) {
+	var next = func() ([]byte, bool) {
+		if len(b) < 4 {
+			return nil, false
+		}
+		var l = int(binary.LittleEndian.Uint32(b))
+		if l < 0 || 4+l > len(b) {
+			return nil, false
+		}
+		var v = b[4 : 4+l]
+		b = b[4+l:]
+		return v, true
+	}
+	if _, ok := next(); !ok { // vendor
+		return
+	}
+	if len(b) < 4 {
+		return
+	}
+	var count = int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	for i := 0; i < count; i++ {
+		c, ok := next()
+		if !ok {
+			return
+		}
+		if eq := bytes.IndexByte(c, '='); eq > 0 {
+			md.set(strings.ToUpper(string(c[:eq])), string(c[eq+1:]))
+		}
+	}
+}
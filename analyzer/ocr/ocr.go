@@ -2,11 +2,13 @@ package ocr
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image/png"
 	"time"
 
+	"github.com/RTradeLtd/Lens/v2/analyzer"
 	"github.com/RTradeLtd/Lens/v2/logs"
 
 	"go.uber.org/zap"
@@ -30,6 +32,29 @@ func NewAnalyzer(configPath string, logger *zap.SugaredLogger) *Analyzer {
 // Version reports the version of Tesseract
 func (a *Analyzer) Version() string { return gosseract.Version() }
 
+// PDF returns an analyzer that extracts text from PDF documents, using OCR on
+// pages without enough text
+func (a *Analyzer) PDF() analyzer.Analyzer {
+	return analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+		text, err := a.Analyze(in.JobID, in.Content, "pdf")
+		if err != nil {
+			return nil, err
+		}
+		return &analyzer.Result{Content: text}, nil
+	})
+}
+
+// Image returns an analyzer that extracts text from images
+func (a *Analyzer) Image() analyzer.Analyzer {
+	return analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+		text, err := a.Analyze(in.JobID, in.Content, "image")
+		if err != nil {
+			return nil, err
+		}
+		return &analyzer.Result{Content: text}, nil
+	})
+}
+
 // Analyze executes OCR on text
 func (a *Analyzer) Analyze(jobID string, content []byte, assetType string) (contents string, err error) {
 	if len(content) < 1 {
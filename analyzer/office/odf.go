@@ -0,0 +1,61 @@
+package office
+
+import (
+	"strings"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+)
+
+// odfTypes are the supported OpenDocument mime types
+var odfTypes = map[string]bool{
+	"application/vnd.oasis.opendocument.text":         true,
+	"application/vnd.oasis.opendocument.spreadsheet":  true,
+	"application/vnd.oasis.opendocument.presentation": true,
+}
+
+var odfText = textSpec{
+	breaks: set("p", "h", "line-break", "table-row"),
+	spaces: set("tab", "s", "table-cell"),
+}
+
+// odfMeta is the metadata of an OpenDocument document
+type odfMeta struct {
+	Title          string   `xml:"meta>title"`
+	Creator        string   `xml:"meta>creator"`
+	InitialCreator string   `xml:"meta>initial-creator"`
+	Created        string   `xml:"meta>creation-date"`
+	Keywords       []string `xml:"meta>keyword"`
+}
+
+// odf extracts the text of an OpenDocument text document, spreadsheet or
+// presentation, along with its metadata
+func (c *container) odf() (*analyzer.Result, error) {
+	text, err := c.text("content.xml", odfText)
+	if err != nil {
+		return nil, err
+	}
+	var result = &analyzer.Result{Content: text}
+	if !c.has("meta.xml") {
+		return result, nil
+	}
+
+	var meta odfMeta
+	if err := c.decode("meta.xml", &meta); err != nil {
+		return nil, err
+	}
+	var props = properties{
+		Title:   strings.TrimSpace(meta.Title),
+		Author:  strings.TrimSpace(meta.InitialCreator),
+		Created: strings.TrimSpace(meta.Created),
+	}
+	if props.Author == "" {
+		props.Author = strings.TrimSpace(meta.Creator)
+	}
+	for _, k := range meta.Keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			props.Keywords = append(props.Keywords, k)
+		}
+	}
+	props.apply(result)
+	return result, nil
+}
@@ -0,0 +1,259 @@
+// Package office provides analysis of Office Open XML (DOCX, XLSX, PPTX) and
+// OpenDocument (ODT, ODS, ODP) documents
+package office
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+)
+
+// MaxPartSize is the maximum decompressed size of each part of a document that
+// is read, which guards against zip bombs
+const MaxPartSize = 32 << 20
+
+// Analyzer extracts text and properties from Office documents, which are zip
+// containers of XML parts
+type Analyzer struct{}
+
+// NewAnalyzer instantiates an Office document analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze extracts the text and core properties of the document. Containers
+// that are not Office documents are not supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	zr, err := zip.NewReader(bytes.NewReader(in.Content), int64(len(in.Content)))
+	if err != nil {
+		return nil, analyzer.ErrUnsupported
+	}
+	var doc = &container{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		doc.files[f.Name] = f
+	}
+
+	var format string
+	switch {
+	case doc.has("word/document.xml"):
+		format = formatDOCX
+	case doc.has("xl/workbook.xml"):
+		format = formatXLSX
+	case doc.has("ppt/presentation.xml"):
+		format = formatPPTX
+	case doc.has("mimetype") && doc.has("content.xml"):
+		mimeType, err := doc.read("mimetype")
+		if err != nil {
+			return nil, err
+		}
+		format = strings.TrimSpace(string(mimeType))
+		if _, ok := odfTypes[format]; !ok {
+			return nil, analyzer.ErrUnsupported
+		}
+	default:
+		return nil, analyzer.ErrUnsupported
+	}
+
+	var result *analyzer.Result
+	switch format {
+	case formatDOCX:
+		result, err = doc.docx()
+	case formatXLSX:
+		result, err = doc.xlsx()
+	case formatPPTX:
+		result, err = doc.pptx()
+	default:
+		result, err = doc.odf()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %s", err.Error())
+	}
+	result.MimeType = format
+	return result, nil
+}
+
+// properties denotes the metadata of a document
+type properties struct {
+	Title    string
+	Author   string
+	Created  string
+	Keywords []string
+}
+
+// apply populates the result with the given properties
+func (p *properties) apply(r *analyzer.Result) {
+	r.DisplayName = p.Title
+	r.Tags = p.Keywords
+	var attrs = make(map[string]string)
+	if p.Title != "" {
+		attrs["title"] = p.Title
+	}
+	if p.Author != "" {
+		attrs["author"] = p.Author
+	}
+	if p.Created != "" {
+		attrs["created"] = p.Created
+	}
+	if len(attrs) > 0 {
+		r.Attributes = attrs
+	}
+}
+
+// container provides access to the parts of a zip container
+type container struct {
+	files map[string]*zip.File
+}
+
+func (c *container) has(name string) bool { _, ok := c.files[name]; return ok }
+
+// open opens the given part, limiting how much of it can be read
+func (c *container) open(name string) (io.ReadCloser, error) {
+	f, ok := c.files[name]
+	if !ok {
+		return nil, fmt.Errorf("missing part '%s'", name)
+	}
+	if f.UncompressedSize64 > MaxPartSize {
+		return nil, fmt.Errorf("part '%s' is too large", name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReadCloser{io.LimitReader(r, MaxPartSize), r}, nil
+}
+
+// read reads the entirety of the given part
+func (c *container) read(name string) ([]byte, error) {
+	r, err := c.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// decode unmarshals the given XML part into out
+func (c *container) decode(name string, out interface{}) error {
+	r, err := c.open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(out)
+}
+
+// text extracts the text of the given XML part
+func (c *container) text(name string, spec textSpec) (string, error) {
+	r, err := c.open(name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return spec.extract(r)
+}
+
+// numbered returns the parts matching the given pattern, which must contain a
+// single number group, in numeric order - ie slide2.xml before slide10.xml
+func (c *container) numbered(pattern *regexp.Regexp) []string {
+	type part struct {
+		name string
+		n    int
+	}
+	var parts = make([]part, 0)
+	for name := range c.files {
+		if m := pattern.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			parts = append(parts, part{name, n})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].n < parts[j].n })
+	var names = make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = p.name
+	}
+	return names
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// textSpec describes how to extract text from an XML part. Elements are
+// identified by their local names, ignoring namespaces.
+type textSpec struct {
+	// text are elements containing text - if empty, all character data is text
+	text map[string]bool
+	// breaks are elements that end a line of text
+	breaks map[string]bool
+	// spaces are elements that separate words
+	spaces map[string]bool
+}
+
+// extract reads the text from the given XML
+func (s textSpec) extract(r io.Reader) (string, error) {
+	var (
+		out   strings.Builder
+		depth int // depth within text elements
+		d     = xml.NewDecoder(r)
+	)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if s.text[t.Name.Local] {
+				depth++
+			}
+			if s.spaces[t.Name.Local] {
+				out.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if s.text[t.Name.Local] {
+				depth--
+			}
+			if s.breaks[t.Name.Local] {
+				out.WriteByte('\n')
+			}
+		case xml.CharData:
+			if len(s.text) == 0 || depth > 0 {
+				out.Write(t)
+			}
+		}
+	}
+	return tidy(out.String()), nil
+}
+
+// tidy collapses whitespace within each line of text, and drops empty lines
+func tidy(text string) string {
+	var lines = strings.Split(text, "\n")
+	var out = make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l = strings.Join(strings.Fields(l), " "); l != "" {
+			out = append(out, l)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// set creates a set of the given names
+func set(names ...string) map[string]bool {
+	var s = make(map[string]bool, len(names))
+	for _, n := range names {
+		s[n] = true
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+package office_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/office"
+)
+
+const coreXML = `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+	xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">
+	<dc:title>Quarterly Report</dc:title>
+	<dc:creator>Bob</dc:creator>
+	<cp:keywords>finance, q3</cp:keywords>
+	<dcterms:created>2019-07-01T00:00:00Z</dcterms:created>
+</cp:coreProperties>`
+
+// newZip creates a zip container with the given files
+func newZip(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	var w = zip.NewWriter(&b)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    *analyzer.Result
+		wantErr error
+	}{
+		{"not a zip", nil, nil, analyzer.ErrUnsupported},
+		{"plain zip", map[string]string{"README.md": "hello"}, nil, analyzer.ErrUnsupported},
+		{"unknown opendocument type", map[string]string{
+			"mimetype":    "application/vnd.oasis.opendocument.graphics",
+			"content.xml": "<content/>",
+		}, nil, analyzer.ErrUnsupported},
+		{"docx", map[string]string{
+			"word/document.xml": `<w:document xmlns:w="w"><w:body>
+				<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve">world </w:t></w:r></w:p>
+				<w:p><w:r><w:instrText>PAGE</w:instrText><w:t>Second paragraph</w:t></w:r></w:p>
+			</w:body></w:document>`,
+			"docProps/core.xml": coreXML,
+		}, &analyzer.Result{
+			Content:     "Hello world\nSecond paragraph",
+			MimeType:    "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			DisplayName: "Quarterly Report",
+			Tags:        []string{"finance", "q3"},
+			Attributes: map[string]string{
+				"title":   "Quarterly Report",
+				"author":  "Bob",
+				"created": "2019-07-01T00:00:00Z",
+			},
+		}, nil},
+		{"xlsx", map[string]string{
+			"xl/workbook.xml":      `<workbook><sheets><sheet name="Revenue"/><sheet name="Costs"/></sheets></workbook>`,
+			"xl/sharedStrings.xml": `<sst><si><t>Region</t></si><si><r><t>North </t></r><r><t>America</t></r></si></sst>`,
+			"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+				<row><c t="s"><v>0</v></c><c t="inlineStr"><is><t>Total</t></is></c></row>
+				<row><c t="s"><v>1</v></c><c><v>42</v></c></row>
+			</sheetData></worksheet>`,
+			"xl/worksheets/sheet10.xml": `<worksheet><sheetData><row><c><v>10</v></c></row></sheetData></worksheet>`,
+			"xl/worksheets/sheet2.xml":  `<worksheet><sheetData><row><c><v>2</v></c></row></sheetData></worksheet>`,
+		}, &analyzer.Result{
+			Content:  "Region\tTotal\nNorth America\t42\n2\n10",
+			MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Headings: []string{"Revenue", "Costs"},
+		}, nil},
+		{"xlsx with reordered sheets", map[string]string{
+			"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>
+				<sheet name="Summary" r:id="rId3"/><sheet name="Revenue" r:id="rId1"/><sheet name="Costs" r:id="rId2"/>
+			</sheets></workbook>`,
+			"xl/_rels/workbook.xml.rels": `<Relationships>
+				<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+				<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/>
+				<Relationship Id="rId3" Target="worksheets/sheet3.xml"/>
+				<Relationship Id="rId4" Target="styles.xml"/>
+			</Relationships>`,
+			"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c><v>1</v></c></row></sheetData></worksheet>`,
+			"xl/worksheets/sheet2.xml": `<worksheet><sheetData><row><c><v>2</v></c></row></sheetData></worksheet>`,
+			"xl/worksheets/sheet3.xml": `<worksheet><sheetData><row><c><v>3</v></c></row></sheetData></worksheet>`,
+		}, &analyzer.Result{
+			Content:  "3\n1\n2",
+			MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Headings: []string{"Summary", "Revenue", "Costs"},
+		}, nil},
+		{"pptx", map[string]string{
+			"ppt/presentation.xml":    `<presentation/>`,
+			"ppt/slides/slide2.xml":   `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>Second slide</a:t></a:r></a:p></p:sld>`,
+			"ppt/slides/slide1.xml":   `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:r><a:t>Title</a:t></a:r></a:p><a:p><a:r><a:t>Subtitle</a:t></a:r></a:p></p:sld>`,
+			"ppt/slides/_rels/x.rels": `<Relationships/>`,
+		}, &analyzer.Result{
+			Content:  "Title\nSubtitle\nSecond slide",
+			MimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		}, nil},
+		{"odt", map[string]string{
+			"mimetype": "application/vnd.oasis.opendocument.text",
+			"content.xml": `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><office:text>
+				<text:h>Chapter 1</text:h>
+				<text:p>Hello<text:s/>world<text:line-break/>again</text:p>
+			</office:text></office:body></office:document-content>`,
+			"meta.xml": `<office:document-meta xmlns:office="o" xmlns:meta="m" xmlns:dc="d"><office:meta>
+				<dc:title>Novel</dc:title>
+				<meta:initial-creator>Alice</meta:initial-creator>
+				<dc:creator>Bob</dc:creator>
+				<meta:creation-date>2019-01-01T00:00:00</meta:creation-date>
+				<meta:keyword>fiction</meta:keyword>
+			</office:meta></office:document-meta>`,
+		}, &analyzer.Result{
+			Content:     "Chapter 1\nHello world\nagain",
+			MimeType:    "application/vnd.oasis.opendocument.text",
+			DisplayName: "Novel",
+			Tags:        []string{"fiction"},
+			Attributes: map[string]string{
+				"title":   "Novel",
+				"author":  "Alice",
+				"created": "2019-01-01T00:00:00",
+			},
+		}, nil},
+		{"ods", map[string]string{
+			"mimetype": "application/vnd.oasis.opendocument.spreadsheet",
+			"content.xml": `<office:document-content xmlns:office="o" xmlns:table="tb" xmlns:text="t"><office:body><office:spreadsheet>
+				<table:table><table:table-row>
+					<table:table-cell><text:p>Region</text:p></table:table-cell>
+					<table:table-cell><text:p>42</text:p></table:table-cell>
+				</table:table-row></table:table>
+			</office:spreadsheet></office:body></office:document-content>`,
+		}, &analyzer.Result{
+			Content:  "Region\n42",
+			MimeType: "application/vnd.oasis.opendocument.spreadsheet",
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content = []byte("not a zip")
+			if tt.files != nil {
+				content = newZip(t, tt.files)
+			}
+			got, err := office.NewAnalyzer().Analyze(context.Background(), analyzer.Input{
+				MimeType: "application/zip",
+				Content:  content,
+			})
+			if err != tt.wantErr {
+				t.Errorf("Analyzer.Analyze() error = %v, want %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyzer.Analyze() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package office
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+)
+
+// Office Open XML mime types
+const (
+	formatDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	formatXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	formatPPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+)
+
+var (
+	docxText = textSpec{
+		text:   set("t"),
+		breaks: set("p", "br", "cr"),
+		spaces: set("tab"),
+	}
+	pptxText = textSpec{
+		text:   set("t"),
+		breaks: set("p", "br"),
+	}
+
+	slidePart = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+	sheetPart = regexp.MustCompile(`^xl/worksheets/sheet(\d+)\.xml$`)
+)
+
+// docx extracts the body text of a word processing document
+func (c *container) docx() (*analyzer.Result, error) {
+	text, err := c.text("word/document.xml", docxText)
+	if err != nil {
+		return nil, err
+	}
+	return c.ooxmlResult(text, nil)
+}
+
+// pptx extracts the text of each slide of a presentation
+func (c *container) pptx() (*analyzer.Result, error) {
+	var slides = make([]string, 0)
+	for _, name := range c.numbered(slidePart) {
+		text, err := c.text(name, pptxText)
+		if err != nil {
+			return nil, err
+		}
+		if text != "" {
+			slides = append(slides, text)
+		}
+	}
+	return c.ooxmlResult(strings.Join(slides, "\n"), nil)
+}
+
+// sharedStrings is the table of strings referenced by spreadsheet cells
+type sharedStrings struct {
+	Items []struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+// worksheet is a spreadsheet sheet
+type worksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline struct {
+				T string `xml:"t"`
+			} `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// workbook lists the sheets of a spreadsheet, in order, along with the IDs of
+// the relationships to their parts
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// relationships are the relationships of a part to other parts
+type relationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// sheets returns the sheet parts of a spreadsheet in workbook order, which
+// need not match the order of their names. If they can't be resolved, the
+// parts are returned in numeric order instead.
+func (c *container) sheets(wb workbook) []string {
+	var rels relationships
+	if !c.has("xl/_rels/workbook.xml.rels") ||
+		c.decode("xl/_rels/workbook.xml.rels", &rels) != nil {
+		return c.numbered(sheetPart)
+	}
+	var targets = make(map[string]string, len(rels.Items))
+	for _, r := range rels.Items {
+		// targets are relative to the workbook, unless they are absolute
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join("xl", r.Target)
+		}
+	}
+	var parts = make([]string, 0, len(wb.Sheets))
+	for _, s := range wb.Sheets {
+		target, ok := targets[s.ID]
+		if !ok || !c.has(target) {
+			return c.numbered(sheetPart)
+		}
+		parts = append(parts, target)
+	}
+	return parts
+}
+
+// xlsx extracts the cell values of each sheet of a spreadsheet, with one row
+// of cells per line. Sheet names are used as headings.
+func (c *container) xlsx() (*analyzer.Result, error) {
+	var wb workbook
+	if err := c.decode("xl/workbook.xml", &wb); err != nil {
+		return nil, err
+	}
+
+	var strs []string
+	if c.has("xl/sharedStrings.xml") {
+		var sst sharedStrings
+		if err := c.decode("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		strs = make([]string, len(sst.Items))
+		for i, si := range sst.Items {
+			var s = si.T
+			for _, r := range si.Runs {
+				s += r.T
+			}
+			strs[i] = s
+		}
+	}
+
+	var lines = make([]string, 0)
+	for _, name := range c.sheets(wb) {
+		var ws worksheet
+		if err := c.decode(name, &ws); err != nil {
+			return nil, err
+		}
+		for _, row := range ws.Rows {
+			var cells = make([]string, 0, len(row.Cells))
+			for _, cell := range row.Cells {
+				var value = cell.Value
+				switch cell.Type {
+				case "s":
+					if i, err := strconv.Atoi(cell.Value); err == nil && i >= 0 && i < len(strs) {
+						value = strs[i]
+					}
+				case "inlineStr":
+					value = cell.Inline.T
+				}
+				if value = strings.TrimSpace(value); value != "" {
+					cells = append(cells, value)
+				}
+			}
+			if len(cells) > 0 {
+				lines = append(lines, strings.Join(cells, "\t"))
+			}
+		}
+	}
+
+	var names = make([]string, 0, len(wb.Sheets))
+	for _, s := range wb.Sheets {
+		names = append(names, s.Name)
+	}
+	return c.ooxmlResult(strings.Join(lines, "\n"), names)
+}
+
+// coreProperties are the properties of an Office Open XML document
+type coreProperties struct {
+	Title    string `xml:"title"`
+	Creator  string `xml:"creator"`
+	Created  string `xml:"created"`
+	Keywords string `xml:"keywords"`
+}
+
+// ooxmlResult creates a result with the given text and headings, along with
+// the document's core properties if it has any
+func (c *container) ooxmlResult(text string, headings []string) (*analyzer.Result, error) {
+	var result = &analyzer.Result{Content: text, Headings: headings}
+	if !c.has("docProps/core.xml") {
+		return result, nil
+	}
+	var core coreProperties
+	if err := c.decode("docProps/core.xml", &core); err != nil {
+		return nil, err
+	}
+	var props = properties{
+		Title:   strings.TrimSpace(core.Title),
+		Author:  strings.TrimSpace(core.Creator),
+		Created: strings.TrimSpace(core.Created),
+	}
+	for _, k := range strings.FieldsFunc(core.Keywords, func(r rune) bool { return r == ',' || r == ';' }) {
+		if k = strings.TrimSpace(k); k != "" {
+			props.Keywords = append(props.Keywords, k)
+		}
+	}
+	props.apply(result)
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registration denotes an analyzer and the content it handles
+type Registration struct {
+	// Name identifies the analyzer, ie for caching results
+	Name string
+
+	// Version should change whenever the analyzer's results do
+	Version string
+
+	// Patterns are the mime types the analyzer handles, which may include
+	// wildcards, ie "image/*"
+	Patterns []string
+
+	// Priority determines the order in which analyzers that match the same
+	// content are tried, from highest to lowest
+	Priority int
+
+	// Category is the category of content the analyzer handles, ie
+	// models.MimeTypeImage, which is used to apply size limits
+	Category string
+
+	Analyzer Analyzer
+}
+
+// Registry selects analyzers for content based on its mime type
+type Registry struct {
+	regs []Registration
+	mux  sync.RWMutex
+}
+
+// NewRegistry instantiates a registry with the given registrations
+func NewRegistry(regs ...Registration) *Registry {
+	var r = &Registry{}
+	for _, reg := range regs {
+		r.Register(reg)
+	}
+	return r
+}
+
+// Register adds an analyzer to the registry. Analyzers with the same priority
+// are tried in the order they were registered.
+func (r *Registry) Register(reg Registration) {
+	r.mux.Lock()
+	r.regs = append(r.regs, reg)
+	sort.SliceStable(r.regs, func(i, j int) bool {
+		return r.regs[i].Priority > r.regs[j].Priority
+	})
+	r.mux.Unlock()
+}
+
+// Match returns the registrations of analyzers that handle the given mime
+// type, in the order they should be tried
+func (r *Registry) Match(mimeType string) []Registration {
+	var mediaType = MediaType(mimeType)
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	var matched = make([]Registration, 0)
+	for _, reg := range r.regs {
+		for _, p := range reg.Patterns {
+			if ok, _ := path.Match(p, mediaType); ok {
+				matched = append(matched, reg)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Analyze runs the best analyzer for the given content. Analyzers that
+// return ErrUnsupported are skipped in favour of the next match.
+func (r *Registry) Analyze(ctx context.Context, in Input) (*Result, error) {
+	for _, reg := range r.Match(in.MimeType) {
+		result, err := reg.Analyzer.Analyze(ctx, in)
+		if err == ErrUnsupported {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result.Category == "" {
+			result.Category = reg.Category
+		}
+		return result, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// AnalyzeStream runs the best analyzer that can stream the given content, which
+// is read from rd. Analyzers that can't stream content, or that return
+// ErrUnsupported, are skipped in favour of the next match.
+func (r *Registry) AnalyzeStream(ctx context.Context, in Input, rd io.Reader) (*Result, error) {
+	for _, reg := range r.Match(in.MimeType) {
+		sa, ok := reg.Analyzer.(StreamAnalyzer)
+		if !ok {
+			continue
+		}
+		result, err := sa.AnalyzeStream(ctx, in, rd)
+		if err == ErrUnsupported {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result.Category == "" {
+			result.Category = reg.Category
+		}
+		return result, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// MediaType strips parameters from the given mime type, ie
+// "text/plain; charset=utf-8" becomes "text/plain"
+func MediaType(mimeType string) string {
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// heifBrands maps the brands of HEIF containers to the mime type of the images
+// they hold
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"hevc": "image/heic-sequence",
+	"hevx": "image/heic-sequence",
+	"mif1": "image/heif",
+	"msf1": "image/heif-sequence",
+}
+
+// DetectContentType determines the mime type of the given content from its
+// first bytes, as http.DetectContentType does, but also recognizes TIFF and
+// HEIF images, which are common sources of embedded metadata
+func DetectContentType(head []byte) string {
+	var contentType = http.DetectContentType(head)
+	if contentType != "application/octet-stream" {
+		return contentType
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "image/tiff"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if t, ok := heifBrands[string(head[8:12])]; ok {
+			return t
+		}
+	}
+	return contentType
+}
@@ -0,0 +1,216 @@
+package analyzer_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/cache"
+	"github.com/RTradeLtd/Lens/v2/analyzer/text"
+)
+
+// newTestAnalyzer returns an analyzer that returns the given content, or the
+// given error if it is set
+func newTestAnalyzer(content string, err error) analyzer.Analyzer {
+	return analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+		if err != nil {
+			return nil, err
+		}
+		return &analyzer.Result{Content: content}, nil
+	})
+}
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		{"text/plain", "text/plain"},
+		{"text/plain; charset=utf-8", "text/plain"},
+		{" Text/HTML;charset=utf-8", "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mimeType, func(t *testing.T) {
+			if got := analyzer.MediaType(tt.mimeType); got != tt.want {
+				t.Errorf("MediaType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"text", "hello world", "text/plain; charset=utf-8"},
+		{"jpeg", "\xFF\xD8\xFF\xE0", "image/jpeg"},
+		{"little-endian tiff", "II*\x00\x08\x00\x00\x00", "image/tiff"},
+		{"big-endian tiff", "MM\x00*\x00\x00\x00\x08", "image/tiff"},
+		{"heic", "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00", "image/heic"},
+		{"heif", "\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00", "image/heif"},
+		{"other iso media", "\x00\x00\x00\x18ftypqt  \x00\x00\x00\x00", "application/octet-stream"},
+		{"unknown", "\x00\x01\x02\x03", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := analyzer.DetectContentType([]byte(tt.head)); got != tt.want {
+				t.Errorf("DetectContentType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_Match(t *testing.T) {
+	var r = analyzer.NewRegistry(
+		analyzer.Registration{Name: "text", Patterns: []string{"text/*"}},
+		analyzer.Registration{Name: "html", Patterns: []string{"text/html"}, Priority: 1},
+		analyzer.Registration{Name: "any", Patterns: []string{"*/*"}, Priority: -1},
+		analyzer.Registration{Name: "pdf", Patterns: []string{"application/pdf"}},
+	)
+	tests := []struct {
+		name     string
+		mimeType string
+		want     []string
+	}{
+		{"no matches", "", []string{}},
+		{"wildcard", "text/plain; charset=utf-8", []string{"text", "any"}},
+		{"priority", "text/html; charset=utf-8", []string{"html", "text", "any"}},
+		{"exact", "application/pdf", []string{"pdf", "any"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = make([]string, 0)
+			for _, reg := range r.Match(tt.mimeType) {
+				got = append(got, reg.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Registry.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_Analyze(t *testing.T) {
+	tests := []struct {
+		name         string
+		regs         []analyzer.Registration
+		wantContent  string
+		wantCategory string
+		wantErr      error
+	}{
+		{"no analyzers",
+			nil, "", "", analyzer.ErrUnsupported},
+		{"all unsupported",
+			[]analyzer.Registration{
+				{Patterns: []string{"text/*"}, Analyzer: newTestAnalyzer("", analyzer.ErrUnsupported)},
+			}, "", "", analyzer.ErrUnsupported},
+		{"analyzer error",
+			[]analyzer.Registration{
+				{Patterns: []string{"text/*"}, Analyzer: newTestAnalyzer("", errors.New("oh no"))},
+				{Patterns: []string{"text/*"}, Priority: -1, Analyzer: newTestAnalyzer("fallback", nil)},
+			}, "", "", errors.New("oh no")},
+		{"ok: fall through unsupported",
+			[]analyzer.Registration{
+				{Patterns: []string{"text/*"}, Category: "a", Analyzer: newTestAnalyzer("", analyzer.ErrUnsupported)},
+				{Patterns: []string{"text/*"}, Category: "b", Priority: -1, Analyzer: newTestAnalyzer("fallback", nil)},
+			}, "fallback", "b", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = analyzer.NewRegistry(tt.regs...)
+			got, err := r.Analyze(context.Background(), analyzer.Input{MimeType: "text/plain"})
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Errorf("Registry.Analyze() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Registry.Analyze() error = %v", err)
+			}
+			if got.Content != tt.wantContent {
+				t.Errorf("Registry.Analyze() content = %v, want %v", got.Content, tt.wantContent)
+			}
+			if got.Category != tt.wantCategory {
+				t.Errorf("Registry.Analyze() category = %v, want %v", got.Category, tt.wantCategory)
+			}
+		})
+	}
+}
+
+func TestRegistry_AnalyzeStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		regs        []analyzer.Registration
+		wantContent string
+		wantErr     error
+	}{
+		{"no streaming analyzers",
+			[]analyzer.Registration{
+				{Patterns: []string{"text/*"}, Analyzer: newTestAnalyzer("buffered", nil)},
+			}, "", analyzer.ErrUnsupported},
+		{"ok: skips analyzers that can't stream",
+			[]analyzer.Registration{
+				{Patterns: []string{"text/*"}, Priority: 1, Analyzer: newTestAnalyzer("buffered", nil)},
+				{Patterns: []string{"text/*"}, Analyzer: text.NewAnalyzer()},
+			}, "hello world", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = analyzer.NewRegistry(tt.regs...)
+			got, err := r.AnalyzeStream(context.Background(), analyzer.Input{
+				MimeType: "text/plain",
+				Content:  []byte("hello"),
+			}, strings.NewReader("hello world"))
+			if (err != nil) != (tt.wantErr != nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("Registry.AnalyzeStream() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Content != tt.wantContent {
+				t.Errorf("Registry.AnalyzeStream() content = %v, want %v", got.Content, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestCached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := cache.New(cache.Options{Path: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	var reg = analyzer.Cached(c, analyzer.Registration{
+		Name:    "test",
+		Version: "1",
+		Analyzer: analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+			calls++
+			return &analyzer.Result{Content: string(in.Content), Tags: []string{"test"}}, nil
+		}),
+	})
+	for i, content := range []string{"hello", "hello", "world"} {
+		got, err := reg.Analyzer.Analyze(context.Background(), analyzer.Input{Content: []byte(content)})
+		if err != nil {
+			t.Fatalf("Analyze() error = %v", err)
+		}
+		if got.Content != content || !reflect.DeepEqual(got.Tags, []string{"test"}) {
+			t.Errorf("Analyze() = %+v for content %s", got, content)
+		}
+		t.Logf("analysis %d: %d calls", i, calls)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want %d", calls, 2)
+	}
+}
@@ -0,0 +1,99 @@
+package structured
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// latKeys and lonKeys are the names of fields that hold coordinates
+var (
+	latKeys = []string{"lat", "latitude"}
+	lonKeys = []string{"lon", "lng", "long", "longitude"}
+)
+
+// locate finds the coordinates of a single record, ie a JSON or YAML object.
+// Coordinates are read from latitude and longitude fields of the record or of
+// one of its direct children, ie {"location": {"lat": 1, "lon": 2}}, or from a
+// GeoJSON point. Lists of records are not located, since their records may be
+// in different places.
+func locate(v interface{}) *models.GeoPoint {
+	var record, ok = v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if p := locateRecord(record); p != nil {
+		return p
+	}
+	var keys = make([]string, 0, len(record))
+	for k := range record {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if child, ok := record[k].(map[string]interface{}); ok {
+			if p := locateRecord(child); p != nil {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// locateRecord reads coordinates from the fields of the given record
+func locateRecord(record map[string]interface{}) *models.GeoPoint {
+	// GeoJSON points store coordinates as [longitude, latitude]
+	if t, _ := record["type"].(string); t == "Point" {
+		if c, ok := record["coordinates"].([]interface{}); ok && len(c) >= 2 {
+			lon, okLon := toFloat(c[0])
+			lat, okLat := toFloat(c[1])
+			if okLon && okLat {
+				return newGeoPoint(lat, lon)
+			}
+		}
+		return nil
+	}
+
+	var (
+		lat, lon     float64
+		okLat, okLon bool
+	)
+	for k, v := range record {
+		var key = strings.ToLower(k)
+		for _, l := range latKeys {
+			if key == l {
+				lat, okLat = toFloat(v)
+			}
+		}
+		for _, l := range lonKeys {
+			if key == l {
+				lon, okLon = toFloat(v)
+			}
+		}
+	}
+	if !okLat || !okLon {
+		return nil
+	}
+	return newGeoPoint(lat, lon)
+}
+
+// newGeoPoint returns the given coordinates if they are valid
+func newGeoPoint(lat, lon float64) *models.GeoPoint {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil
+	}
+	return &models.GeoPoint{Lat: lat, Lon: lon}
+}
+
+// toFloat converts decoded numbers, or numbers stored as strings
+func toFloat(v interface{}) (float64, bool) {
+	switch v.(type) {
+	case nil, bool, map[string]interface{}, []interface{}:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(v)), 64)
+	return f, err == nil
+}
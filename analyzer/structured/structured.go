@@ -0,0 +1,368 @@
+// Package structured provides analysis of structured data, such as JSON, CSV
+// and YAML datasets
+package structured
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// Supported formats, as recorded in the "format" attribute
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+	FormatYAML   = "yaml"
+)
+
+// mimeTypes maps formats to the mime type recorded for the content
+var mimeTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+	FormatCSV:    "text/csv",
+	FormatYAML:   "application/x-yaml",
+}
+
+// Analyzer parses structured data, and indexes its keys or column headers as
+// fields and its values as content
+type Analyzer struct{}
+
+// NewAnalyzer instantiates a structured data analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze parses the content in the format indicated by its mime type or name,
+// or detected from the content itself. Each value is indexed as a "field:
+// value" line, where nested fields are joined with dots, and the coordinates of
+// single records are used as their location. Content that is not
+// recognized as structured data is not supported, so that it can be indexed as
+// plain text instead.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	var format, records = detect(in)
+	if format == "" {
+		return nil, analyzer.ErrUnsupported
+	}
+
+	var (
+		d        = newDataset()
+		rows     = -1
+		location *models.GeoPoint
+		err      error
+	)
+	switch format {
+	case FormatJSON:
+		var v interface{}
+		if v, err = decodeJSON(in.Content); err == nil {
+			rows = d.add(v)
+			location = locate(v)
+		}
+	case FormatNDJSON:
+		rows, err = d.addNDJSON(in.Content)
+	case FormatYAML:
+		var v interface{}
+		if err = yaml.Unmarshal(in.Content, &v); err == nil {
+			v = normalize(v)
+			rows = d.add(v)
+			location = locate(v)
+		}
+	case FormatCSV:
+		// sniffed content has already been parsed
+		if records == nil {
+			records, err = readCSV(in.Content, delimiter(in))
+		}
+		if err == nil {
+			rows = d.addCSV(records)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", format, err.Error())
+	}
+
+	var attrs = map[string]string{"format": format}
+	if rows >= 0 {
+		attrs["rows"] = strconv.Itoa(rows)
+	}
+	if len(d.fields) > 0 {
+		attrs["columns"] = strings.Join(d.fields, ", ")
+	}
+	return &analyzer.Result{
+		Content:    strings.Join(d.lines, "\n"),
+		MimeType:   mimeTypes[format],
+		Fields:     d.fields,
+		Attributes: attrs,
+		Location:   location,
+	}, nil
+}
+
+// detect determines the format of the given content, returning an empty
+// string if it is not structured data. The records of CSV detected from the
+// content itself are also returned, so that it is only parsed once.
+func detect(in analyzer.Input) (string, [][]string) {
+	switch analyzer.MediaType(in.MimeType) {
+	case "application/json":
+		return FormatJSON, nil
+	case "application/x-ndjson", "application/jsonlines":
+		return FormatNDJSON, nil
+	case "text/csv", "text/tab-separated-values":
+		return FormatCSV, nil
+	case "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML, nil
+	}
+	switch strings.ToLower(path.Ext(in.Name)) {
+	case ".json", ".geojson":
+		return FormatJSON, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	case ".csv", ".tsv":
+		return FormatCSV, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+
+	// sniff the content - plain text is easily mistaken for YAML, so YAML is
+	// only detected by name or type
+	var trimmed = bytes.TrimSpace(in.Content)
+	if len(trimmed) == 0 {
+		return "", nil
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return FormatJSON, nil
+	}
+	if isNDJSON(trimmed) {
+		return FormatNDJSON, nil
+	}
+	if records := sniffCSV(trimmed); records != nil {
+		return FormatCSV, records
+	}
+	return "", nil
+}
+
+// isNDJSON checks if every line of the given content is a JSON object
+func isNDJSON(content []byte) bool {
+	var lines = bytes.Split(content, []byte("\n"))
+	if len(lines) < 2 {
+		return false
+	}
+	for _, line := range lines {
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' || !json.Valid(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// maxHeaderLen is the maximum length of a column header in sniffed CSV, which
+// helps avoid mistaking prose with commas for CSV
+const maxHeaderLen = 32
+
+// minCSVRecords is the minimum number of records, including the header, in
+// sniffed CSV
+const minCSVRecords = 3
+
+// sniffCSV parses the given content as CSV if it has a header and at least two
+// rows, all with the same number of columns, and short headers that look like
+// names rather than words of a sentence. It returns nil otherwise.
+func sniffCSV(content []byte) [][]string {
+	var r = csv.NewReader(bytes.NewReader(content))
+	r.Comma = sniffDelimiter(content)
+	records, err := r.ReadAll()
+	if err != nil || len(records) < minCSVRecords || len(records[0]) < 2 {
+		return nil
+	}
+	for _, h := range records[0] {
+		h = strings.TrimSpace(h)
+		if h == "" || len(h) > maxHeaderLen ||
+			strings.ContainsAny(h, " \t!?;") || strings.HasSuffix(h, ".") {
+			return nil
+		}
+	}
+	return records
+}
+
+// readCSV parses CSV content, tolerating rows of differing length and stray
+// quotes
+func readCSV(content []byte, comma rune) ([][]string, error) {
+	var r = csv.NewReader(bytes.NewReader(content))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing header")
+	}
+	return records, nil
+}
+
+// delimiter returns the delimiter used by the given CSV content
+func delimiter(in analyzer.Input) rune {
+	if analyzer.MediaType(in.MimeType) == "text/tab-separated-values" ||
+		strings.ToLower(path.Ext(in.Name)) == ".tsv" {
+		return '\t'
+	}
+	return sniffDelimiter(in.Content)
+}
+
+// sniffDelimiter guesses whether the given CSV content is tab-separated from
+// its first line
+func sniffDelimiter(content []byte) rune {
+	var first = content
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		first = content[:i]
+	}
+	if bytes.Count(first, []byte("\t")) > bytes.Count(first, []byte(",")) {
+		return '\t'
+	}
+	return ','
+}
+
+// decodeJSON decodes JSON, preserving the representation of numbers
+func decodeJSON(content []byte) (interface{}, error) {
+	var dec = json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// normalize converts the maps decoded from YAML to use string keys
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[interface{}]interface{}:
+		var m = make(map[string]interface{}, len(n))
+		for k, v := range n {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []interface{}:
+		for i := range n {
+			n[i] = normalize(n[i])
+		}
+		return n
+	default:
+		return v
+	}
+}
+
+// dataset accumulates the lines of content and the fields found in structured
+// data
+type dataset struct {
+	lines  []string
+	fields []string
+	seen   map[string]bool
+}
+
+func newDataset() *dataset { return &dataset{seen: make(map[string]bool)} }
+
+// add flattens the given value, returning the number of rows if it is a list
+// of records, or -1 otherwise
+func (d *dataset) add(v interface{}) int {
+	d.flatten("", v)
+	if list, ok := v.([]interface{}); ok {
+		return len(list)
+	}
+	return -1
+}
+
+// addNDJSON flattens each record in newline-delimited JSON
+func (d *dataset) addNDJSON(content []byte) (int, error) {
+	var (
+		s    = bufio.NewScanner(bytes.NewReader(content))
+		rows int
+	)
+	s.Buffer(make([]byte, 64*1024), len(content)+1)
+	for s.Scan() {
+		var line = bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		v, err := decodeJSON(line)
+		if err != nil {
+			return 0, fmt.Errorf("record %d: %s", rows+1, err.Error())
+		}
+		d.flatten("", v)
+		rows++
+	}
+	return rows, s.Err()
+}
+
+// addCSV adds each row of CSV records, using the first row as column headers
+func (d *dataset) addCSV(records [][]string) int {
+	var header = records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		d.field(header[i])
+	}
+	for _, record := range records[1:] {
+		for i, v := range record {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if i < len(header) && header[i] != "" {
+				d.lines = append(d.lines, header[i]+": "+v)
+			} else {
+				d.lines = append(d.lines, v)
+			}
+		}
+	}
+	return len(records) - 1
+}
+
+func (d *dataset) flatten(path string, node interface{}) {
+	switch n := node.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		var keys = make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var p = k
+			if path != "" {
+				p = path + "." + k
+			}
+			d.flatten(p, n[k])
+		}
+	case []interface{}:
+		for _, v := range n {
+			d.flatten(path, v)
+		}
+	default:
+		if path == "" {
+			d.lines = append(d.lines, fmt.Sprint(n))
+		} else {
+			d.field(path)
+			d.lines = append(d.lines, path+": "+fmt.Sprint(n))
+		}
+	}
+}
+
+// field records a field name, if it has not been seen before
+func (d *dataset) field(name string) {
+	if name != "" && !d.seen[name] {
+		d.seen[name] = true
+		d.fields = append(d.fields, name)
+	}
+}
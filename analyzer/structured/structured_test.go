@@ -0,0 +1,206 @@
+package structured_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/structured"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      analyzer.Input
+		want    *analyzer.Result
+		wantErr string
+	}{
+		{"plain text",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("hello world, it is a nice day")},
+			nil, "unsupported content type"},
+		{"prose with commas",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("hello, world\nit is a nice day, isn't it, bob")},
+			nil, "unsupported content type"},
+		{"rhyme with commas",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("Roses are red, violets are blue\nsugar is sweet, and so are you\n")},
+			nil, "unsupported content type"},
+		{"sentences with commas",
+			analyzer.Input{Content: []byte("Yes, please.\nNo, thanks.\nMaybe, later.\n")},
+			nil, "unsupported content type"},
+		{"too few rows to sniff csv",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("city,country\nVancouver,Canada\n")},
+			nil, "unsupported content type"},
+		{"yaml-like text is not sniffed",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("note: buy milk")},
+			nil, "unsupported content type"},
+		{"json object",
+			analyzer.Input{MimeType: "text/plain", Content: []byte(`{
+				"name": "lens", "stars": 1000000,
+				"owner": {"name": "rtrade", "city": "Vancouver"},
+				"tags": ["ipfs", "search"]
+			}`)},
+			&analyzer.Result{
+				Content:  "name: lens\nowner.city: Vancouver\nowner.name: rtrade\nstars: 1000000\ntags: ipfs\ntags: search",
+				MimeType: "application/json",
+				Fields:   []string{"name", "owner.city", "owner.name", "stars", "tags"},
+				Attributes: map[string]string{
+					"format":  "json",
+					"columns": "name, owner.city, owner.name, stars, tags",
+				},
+			}, ""},
+		{"json records",
+			analyzer.Input{Name: "people.json", Content: []byte(`[{"name": "bob", "age": 30}, {"name": "alice"}]`)},
+			&analyzer.Result{
+				Content:  "age: 30\nname: bob\nname: alice",
+				MimeType: "application/json",
+				Fields:   []string{"age", "name"},
+				Attributes: map[string]string{
+					"format":  "json",
+					"rows":    "2",
+					"columns": "age, name",
+				},
+			}, ""},
+		{"ndjson",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("{\"id\": 1, \"msg\": \"hi\"}\n\n{\"id\": 2}\n")},
+			&analyzer.Result{
+				Content:  "id: 1\nmsg: hi\nid: 2",
+				MimeType: "application/x-ndjson",
+				Fields:   []string{"id", "msg"},
+				Attributes: map[string]string{
+					"format":  "ndjson",
+					"rows":    "2",
+					"columns": "id, msg",
+				},
+			}, ""},
+		{"invalid ndjson",
+			analyzer.Input{Name: "log.jsonl", Content: []byte("{\"id\": 1}\nnot json")},
+			nil, "failed to parse"},
+		{"csv",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("city, country\nVancouver,Canada\nBerlin,\n")},
+			&analyzer.Result{
+				Content:  "city: Vancouver\ncountry: Canada\ncity: Berlin",
+				MimeType: "text/csv",
+				Fields:   []string{"city", "country"},
+				Attributes: map[string]string{
+					"format":  "csv",
+					"rows":    "2",
+					"columns": "city, country",
+				},
+			}, ""},
+		{"sniffed csv with dotted display name",
+			analyzer.Input{Name: "cities v1.2", Content: []byte("city, country\nVancouver,Canada\nBerlin,\n")},
+			&analyzer.Result{
+				Content:  "city: Vancouver\ncountry: Canada\ncity: Berlin",
+				MimeType: "text/csv",
+				Fields:   []string{"city", "country"},
+				Attributes: map[string]string{
+					"format":  "csv",
+					"rows":    "2",
+					"columns": "city, country",
+				},
+			}, ""},
+		{"named csv with ragged rows",
+			analyzer.Input{Name: "notes.csv", Content: []byte("first name,notes\nbob,likes \"ipfs\",search\n")},
+			&analyzer.Result{
+				Content:  "first name: bob\nnotes: likes \"ipfs\"\nsearch",
+				MimeType: "text/csv",
+				Fields:   []string{"first name", "notes"},
+				Attributes: map[string]string{
+					"format":  "csv",
+					"rows":    "1",
+					"columns": "first name, notes",
+				},
+			}, ""},
+		{"empty csv",
+			analyzer.Input{MimeType: "text/csv", Content: []byte("")},
+			nil, "failed to parse"},
+		{"tsv",
+			analyzer.Input{Name: "cities.tsv", Content: []byte("city\tpopulation\nVancouver\t631486")},
+			&analyzer.Result{
+				Content:  "city: Vancouver\npopulation: 631486",
+				MimeType: "text/csv",
+				Fields:   []string{"city", "population"},
+				Attributes: map[string]string{
+					"format":  "csv",
+					"rows":    "1",
+					"columns": "city, population",
+				},
+			}, ""},
+		{"yaml",
+			analyzer.Input{Name: "config.yml", Content: []byte("server:\n  port: 8080\n  hosts:\n    - a.com\n    - b.com\ndebug: true\n")},
+			&analyzer.Result{
+				Content:  "debug: true\nserver.hosts: a.com\nserver.hosts: b.com\nserver.port: 8080",
+				MimeType: "application/x-yaml",
+				Fields:   []string{"debug", "server.hosts", "server.port"},
+				Attributes: map[string]string{
+					"format":  "yaml",
+					"columns": "debug, server.hosts, server.port",
+				},
+			}, ""},
+		{"json with coordinates",
+			analyzer.Input{Name: "cafe.json", Content: []byte(`{"name": "cafe", "location": {"lat": 49.28, "lng": -123.12}}`)},
+			&analyzer.Result{
+				Content:  "location.lat: 49.28\nlocation.lng: -123.12\nname: cafe",
+				MimeType: "application/json",
+				Fields:   []string{"location.lat", "location.lng", "name"},
+				Attributes: map[string]string{
+					"format":  "json",
+					"columns": "location.lat, location.lng, name",
+				},
+				Location: &models.GeoPoint{Lat: 49.28, Lon: -123.12},
+			}, ""},
+		{"geojson point",
+			analyzer.Input{Name: "cafe.geojson", MimeType: "application/json", Content: []byte(`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-123.12, 49.28]}}`)},
+			&analyzer.Result{
+				Content:  "geometry.coordinates: -123.12\ngeometry.coordinates: 49.28\ngeometry.type: Point\ntype: Feature",
+				MimeType: "application/json",
+				Fields:   []string{"geometry.coordinates", "geometry.type", "type"},
+				Attributes: map[string]string{
+					"format":  "json",
+					"columns": "geometry.coordinates, geometry.type, type",
+				},
+				Location: &models.GeoPoint{Lat: 49.28, Lon: -123.12},
+			}, ""},
+		{"invalid coordinates",
+			analyzer.Input{Name: "place.json", Content: []byte(`{"lat": 100, "lon": 0}`)},
+			&analyzer.Result{
+				Content:  "lat: 100\nlon: 0",
+				MimeType: "application/json",
+				Fields:   []string{"lat", "lon"},
+				Attributes: map[string]string{
+					"format":  "json",
+					"columns": "lat, lon",
+				},
+			}, ""},
+		{"yaml with coordinates",
+			analyzer.Input{Name: "berlin.yaml", Content: []byte("Latitude: 52.52\nLongitude: \"13.405\"\n")},
+			&analyzer.Result{
+				Content:  "Latitude: 52.52\nLongitude: 13.405",
+				MimeType: "application/x-yaml",
+				Fields:   []string{"Latitude", "Longitude"},
+				Attributes: map[string]string{
+					"format":  "yaml",
+					"columns": "Latitude, Longitude",
+				},
+				Location: &models.GeoPoint{Lat: 52.52, Lon: 13.405},
+			}, ""},
+		{"invalid yaml",
+			analyzer.Input{MimeType: "application/x-yaml", Content: []byte("a: [")},
+			nil, "failed to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := structured.NewAnalyzer().Analyze(context.Background(), tt.in)
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package subtitles provides analysis of SubRip (SRT) and WebVTT subtitles and
+// transcripts
+package subtitles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// Supported formats, as recorded in the "format" attribute
+const (
+	FormatSRT = "srt"
+	FormatVTT = "vtt"
+)
+
+// mimeTypes maps formats to the mime type recorded for the content
+var mimeTypes = map[string]string{
+	FormatSRT: "application/x-subrip",
+	FormatVTT: "text/vtt",
+}
+
+// Analyzer extracts the text of subtitle cues, without their timings
+type Analyzer struct{}
+
+// NewAnalyzer instantiates a subtitle analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze strips cue timings, identifiers and formatting from subtitles,
+// indexing the text of each cue on its own line. The start of each cue is
+// recorded as a section titled with its timestamp, ie "00:01:02.500", so that
+// search results can report when matched phrases occur. Subtitles are
+// recognized by their mime type or name, or by starting with a WebVTT header or
+// an SRT cue - other content, or subtitles without any cues, is not supported.
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	var format = detect(in)
+	if format == "" {
+		return nil, analyzer.ErrUnsupported
+	}
+	var cues = parse(in.Content)
+	if len(cues) == 0 {
+		return nil, analyzer.ErrUnsupported
+	}
+
+	var (
+		text     strings.Builder
+		sections = make([]models.Section, 0, len(cues))
+		end      time.Duration
+	)
+	for _, c := range cues {
+		if text.Len() > 0 {
+			text.WriteByte('\n')
+		}
+		sections = append(sections, models.Section{
+			Title:  timestamp(c.start),
+			Offset: text.Len(),
+		})
+		text.WriteString(c.text)
+		if c.end > end {
+			end = c.end
+		}
+	}
+
+	var attrs = map[string]string{
+		"format": format,
+		"cues":   strconv.Itoa(len(cues)),
+	}
+	if seconds := int64(end.Round(time.Second) / time.Second); seconds > 0 {
+		attrs["duration"] = strconv.FormatInt(seconds, 10)
+	}
+	return &analyzer.Result{
+		Content:    text.String(),
+		MimeType:   mimeTypes[format],
+		Sections:   sections,
+		Attributes: attrs,
+	}, nil
+}
+
+// cue denotes a subtitle and when it is shown
+type cue struct {
+	start time.Duration
+	end   time.Duration
+	text  string
+}
+
+var (
+	// timing matches cue timings such as "00:01:02,500 --> 00:01:04,000" in
+	// SRT, or "01:02.500 --> 01:04.000 align:start" in WebVTT
+	timing = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s+-->\s+((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})`)
+
+	// blankLines separate cues
+	blankLines = regexp.MustCompile(`\n[ \t]*\n\s*`)
+
+	// markup matches tags such as <i> or <v Speaker>, and SRT positioning such
+	// as {\an8}
+	markup = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+)
+
+// detect determines the format of the given subtitles, returning an empty
+// string if the content is not subtitles
+func detect(in analyzer.Input) string {
+	switch analyzer.MediaType(in.MimeType) {
+	case "text/vtt":
+		return FormatVTT
+	case "application/x-subrip", "text/srt":
+		return FormatSRT
+	}
+	// names may be display names rather than file names, ie "talk v1.2", so
+	// content with other extensions is sniffed as well
+	switch strings.ToLower(path.Ext(in.Name)) {
+	case ".vtt":
+		return FormatVTT
+	case ".srt":
+		return FormatSRT
+	}
+
+	var trimmed = bytes.TrimLeft(bytes.TrimPrefix(in.Content, []byte("\ufeff")), " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("WEBVTT")) {
+		return FormatVTT
+	}
+	var lines = strings.SplitN(string(trimmed), "\n", 3)
+	if len(lines) < 2 {
+		return ""
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(lines[0])); err != nil || !timing.MatchString(lines[1]) {
+		return ""
+	}
+	return FormatSRT
+}
+
+// parse reads the cues in the given subtitles. Blocks without timings, such as
+// the WebVTT header and NOTE, STYLE and REGION blocks, are skipped.
+func parse(content []byte) []cue {
+	var text = strings.TrimPrefix(string(content), "\ufeff")
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+
+	var cues []cue
+	for _, block := range blankLines.Split(strings.TrimSpace(text), -1) {
+		var lines = strings.Split(block, "\n")
+
+		// timings are preceded by at most an index or cue identifier
+		var at = -1
+		for i := 0; i < len(lines) && i < 2; i++ {
+			if timing.MatchString(lines[i]) {
+				at = i
+				break
+			}
+		}
+		if at < 0 {
+			continue
+		}
+		var m = timing.FindStringSubmatch(lines[at])
+		var c = cue{start: parseTimestamp(m[1]), end: parseTimestamp(m[2])}
+
+		var words []string
+		for _, l := range lines[at+1:] {
+			if l = clean(l); l != "" {
+				words = append(words, l)
+			}
+		}
+		if c.text = strings.Join(words, " "); c.text == "" {
+			continue
+		}
+		// captions that roll up often repeat the previous cue
+		if len(cues) > 0 && cues[len(cues)-1].text == c.text {
+			cues[len(cues)-1].end = c.end
+			continue
+		}
+		cues = append(cues, c)
+	}
+	return cues
+}
+
+// clean strips formatting from a line of cue text
+func clean(line string) string {
+	line = markup.ReplaceAllString(line, "")
+	return strings.Join(strings.Fields(html.UnescapeString(line)), " ")
+}
+
+// parseTimestamp reads a timestamp such as "01:02:03,500" or "02:03.5"
+func parseTimestamp(s string) time.Duration {
+	var frac time.Duration
+	if i := strings.IndexAny(s, ",."); i >= 0 {
+		var digits = s[i+1:]
+		ms, _ := strconv.Atoi((digits + "00")[:3])
+		frac, s = time.Duration(ms)*time.Millisecond, s[:i]
+	}
+	var d time.Duration
+	for _, part := range strings.Split(s, ":") {
+		n, _ := strconv.Atoi(part)
+		d = d*60 + time.Duration(n)
+	}
+	return d*time.Second + frac
+}
+
+// timestamp formats a position as "hh:mm:ss.mmm"
+func timestamp(d time.Duration) string {
+	var ms = int64(d / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,111 @@
+package subtitles_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/subtitles"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+const testSRT = "1\r\n00:00:01,000 --> 00:00:03,500\r\n{\\an8}<i>Hello</i> and\r\nwelcome\r\n\r\n" +
+	"2\r\n00:00:04,000 --> 00:00:06,000\r\nto the launch &amp; demo\r\n\r\n" +
+	"3\r\n00:01:02,500 --> 00:01:05,250\r\nThanks for watching\r\n"
+
+const testVTT = `WEBVTT - launch
+
+NOTE this is not a cue
+
+STYLE
+::cue { color: yellow }
+
+intro
+00:01.000 --> 00:03.000 align:start
+<v Ada>Hello there
+
+00:03.000 --> 00:05.000
+<v Ada>Hello there
+
+00:05.000 --> 01:00:07.000
+<c.loud>Welcome</c> to the <00:05.500>talk
+`
+
+func TestAnalyzer_Analyze(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      analyzer.Input
+		want    *analyzer.Result
+		wantErr bool
+	}{
+		{"plain text",
+			analyzer.Input{MimeType: "text/plain", Content: []byte("hello world")},
+			nil, true},
+		{"other extension",
+			analyzer.Input{Name: "notes.txt", Content: []byte("hello world")},
+			nil, true},
+		{"no cues",
+			analyzer.Input{Name: "empty.srt", Content: []byte("1\nnot a timing\nhello")},
+			nil, true},
+		{"srt",
+			analyzer.Input{Name: "launch.srt", Content: []byte(testSRT)},
+			&analyzer.Result{
+				Content:  "Hello and welcome\nto the launch & demo\nThanks for watching",
+				MimeType: "application/x-subrip",
+				Sections: []models.Section{
+					{Title: "00:00:01.000", Offset: 0},
+					{Title: "00:00:04.000", Offset: 18},
+					{Title: "00:01:02.500", Offset: 39},
+				},
+				Attributes: map[string]string{"format": "srt", "cues": "3", "duration": "65"},
+			}, false},
+		{"sniffed srt",
+			analyzer.Input{MimeType: "text/plain; charset=utf-8", Content: []byte("\ufeff" + testSRT)},
+			&analyzer.Result{
+				Content:  "Hello and welcome\nto the launch & demo\nThanks for watching",
+				MimeType: "application/x-subrip",
+				Sections: []models.Section{
+					{Title: "00:00:01.000", Offset: 0},
+					{Title: "00:00:04.000", Offset: 18},
+					{Title: "00:01:02.500", Offset: 39},
+				},
+				Attributes: map[string]string{"format": "srt", "cues": "3", "duration": "65"},
+			}, false},
+		{"sniffed srt with dotted display name",
+			analyzer.Input{Name: "launch v1.2", MimeType: "text/plain", Content: []byte(testSRT)},
+			&analyzer.Result{
+				Content:  "Hello and welcome\nto the launch & demo\nThanks for watching",
+				MimeType: "application/x-subrip",
+				Sections: []models.Section{
+					{Title: "00:00:01.000", Offset: 0},
+					{Title: "00:00:04.000", Offset: 18},
+					{Title: "00:01:02.500", Offset: 39},
+				},
+				Attributes: map[string]string{"format": "srt", "cues": "3", "duration": "65"},
+			}, false},
+		{"sniffed vtt",
+			analyzer.Input{MimeType: "text/plain", Content: []byte(testVTT)},
+			&analyzer.Result{
+				Content:  "Hello there\nWelcome to the talk",
+				MimeType: "text/vtt",
+				Sections: []models.Section{
+					{Title: "00:00:01.000", Offset: 0},
+					{Title: "00:00:05.000", Offset: 12},
+				},
+				Attributes: map[string]string{"format": "vtt", "cues": "2", "duration": "3607"},
+			}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := subtitles.NewAnalyzer().Analyze(context.Background(), tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Analyze() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
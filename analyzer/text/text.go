@@ -0,0 +1,31 @@
+// Package text provides analysis of plain-text documents
+package text
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+)
+
+// Analyzer indexes plain-text documents as-is
+type Analyzer struct{}
+
+// NewAnalyzer instantiates a plain-text analyzer
+func NewAnalyzer() *Analyzer { return &Analyzer{} }
+
+// Analyze returns the text of the document
+func (a *Analyzer) Analyze(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+	return &analyzer.Result{Content: string(in.Content)}, nil
+}
+
+// AnalyzeStream returns the text of the document as it is read, so that large
+// documents are not held in memory both as read and as text
+func (a *Analyzer) AnalyzeStream(ctx context.Context, in analyzer.Input, r io.Reader) (*analyzer.Result, error) {
+	var text strings.Builder
+	if _, err := io.Copy(&text, r); err != nil {
+		return nil, err
+	}
+	return &analyzer.Result{Content: text.String()}, nil
+}
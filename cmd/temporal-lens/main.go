@@ -1,11 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,10 +20,15 @@ import (
 	"github.com/bobheadxi/zapx"
 
 	lens "github.com/RTradeLtd/Lens/v2"
+	"github.com/RTradeLtd/Lens/v2/analyzer/cache"
 	"github.com/RTradeLtd/Lens/v2/analyzer/images"
 	"github.com/RTradeLtd/Lens/v2/engine"
 	"github.com/RTradeLtd/Lens/v2/engine/queue"
 	"github.com/RTradeLtd/Lens/v2/server"
+	"github.com/RTradeLtd/Lens/v2/source"
+	"github.com/RTradeLtd/Lens/v2/source/filesystem"
+	"github.com/RTradeLtd/Lens/v2/source/gateway"
+	"github.com/RTradeLtd/Lens/v2/source/planetary"
 )
 
 var (
@@ -32,14 +42,53 @@ var (
 	cfgPath = flag.String("cfg", os.Getenv("CONFIG_DAG"),
 		"path to Temporal configuration")
 	modelPath = flag.String("models", "/tmp",
-		"path to TensorFlow models")
+		"path to TensorFlow models - use the 'fetch' command to download them")
+	imagesClassifier = flag.String("images.classifier", "",
+		"classifier used for images, either 'tensorflow' or 'heuristic' - leave blank to use TensorFlow if this build supports it")
+	modelName = flag.String("images.model", images.Inception5h.Name,
+		"name of the TensorFlow model used to classify images")
+	modelConfig = flag.String("images.model.config", "",
+		"path to a JSON file describing additional TensorFlow models")
+	imagesTop = flag.Int("images.top", images.DefaultTopK,
+		"maximum number of labels recorded for each image")
+	imagesConfidence = flag.Float64("images.confidence", images.DefaultMinConfidence,
+		"minimum confidence, between 0 and 1, of labels recorded for images")
 	logPath = flag.String("logpath", "",
 		"path to write logs to - leave blank for stdout")
 	devMode = flag.Bool("dev", false,
 		"enable dev mode")
+	resolveInterval = flag.Duration("ipns.interval", 10*time.Minute,
+		"interval at which indexed IPNS names are re-resolved - set to 0 to disable")
+	ipfsTimeout = flag.Duration("ipfs.timeout", lens.DefaultRetrievalTimeout,
+		"time limit for retrieving an object from IPFS")
+	ipfsRetries = flag.Int("ipfs.retries", planetary.DefaultOptions.Retries,
+		"number of times requests to IPFS that failed due to transient errors are retried")
+	fsRoot = flag.String("fs.root", "",
+		"directory that file:// URIs can be indexed from - leave blank to disable")
+	httpHosts = flag.String("http.hosts", "",
+		"comma-separated hosts that http(s):// URIs can be indexed from - leave blank to disable")
+	cachePath = flag.String("cache.path", "",
+		"directory to cache analysis results in - leave blank to disable")
+	cacheSize = flag.Int64("cache.size", cache.DefaultMaxSize,
+		"maximum size of the analysis cache, in bytes")
 )
 
 var commands = map[string]cmd.Cmd{
+	"fetch": {
+		Blurb:       "download TensorFlow models",
+		Description: "Download the TensorFlow model used to classify images into the models directory, if it is not already present",
+		PreRun:      true,
+		Action: func(cfg config.TemporalConfig, args map[string]string) {
+			m, err := model()
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("fetching model %s into %s", m.Name, *modelPath)
+			if err := images.Fetch(m, *modelPath); err != nil {
+				log.Fatal("failed to fetch model: ", err)
+			}
+		},
+	},
 	"v2": {
 		Blurb: "start the Lens V2 server",
 		Action: func(cfg config.TemporalConfig, args map[string]string) {
@@ -54,23 +103,77 @@ var commands = map[string]cmd.Cmd{
 			// instantiate ipfs connection
 			var ipfsURL = fmt.Sprintf("%s:%s", cfg.IPFS.APIConnection.Host, cfg.IPFS.APIConnection.Port)
 			l.Infow("instantiating IPFS connection", "ipfs.url", ipfsURL)
-			manager, err := rtfs.NewManager(ipfsURL, "", 1*time.Minute)
+			manager, err := rtfs.NewManager(ipfsURL, "", *ipfsTimeout)
 			if err != nil {
 				l.Fatalw("failed to instantiate ipfs manager", "error", err)
 			}
 
-			// instantiate tensorflow wrapper
-			l.Infow("instantiating tensorflow wrappers", "tensorflow.models", *modelPath)
-			tf, err := images.NewAnalyzer(images.ConfigOpts{
+			// instantiate image classifier
+			l.Infow("instantiating image classifier",
+				"images.classifier", *imagesClassifier, "tensorflow.models", *modelPath)
+			m, err := model()
+			if err != nil {
+				l.Fatalw("failed to load model configuration", "error", err)
+			}
+			tf, err := images.NewClassifier(images.ConfigOpts{
+				Classifier:    *imagesClassifier,
 				ModelLocation: *modelPath,
+				Model:         m.Name,
+				TopK:          *imagesTop,
+				MinConfidence: float32(*imagesConfidence),
 			}, l.Named("analyzer").Named("images"))
 			if err != nil {
 				l.Fatalw("failed to instantiate image analyzer", "error", err)
 			}
+			if c, ok := tf.(io.Closer); ok {
+				defer c.Close()
+			}
+
+			// set up additional content sources
+			var sources = make(map[string]source.Source)
+			if *fsRoot != "" {
+				l.Infow("instantiating filesystem source", "fs.root", *fsRoot)
+				fs, err := filesystem.New(*fsRoot)
+				if err != nil {
+					l.Fatalw("failed to instantiate filesystem source", "error", err)
+				}
+				sources[filesystem.Scheme] = fs
+			}
+			if *httpHosts != "" {
+				var hosts = strings.Split(*httpHosts, ",")
+				l.Infow("instantiating gateway source", "http.hosts", hosts)
+				gw, err := gateway.New(hosts, &http.Client{Timeout: *ipfsTimeout})
+				if err != nil {
+					l.Fatalw("failed to instantiate gateway source", "error", err)
+				}
+				for _, scheme := range gateway.Schemes {
+					sources[scheme] = gw
+				}
+			}
+
+			// set up analysis cache
+			var c *cache.Cache
+			if *cachePath != "" {
+				l.Infow("instantiating analysis cache", "cache.path", *cachePath)
+				if c, err = cache.New(cache.Options{
+					Path:    *cachePath,
+					MaxSize: *cacheSize,
+				}, l.Named("cache")); err != nil {
+					l.Fatalw("failed to instantiate analysis cache", "error", err)
+				}
+			}
 
 			// create lens v2 service
 			l.Info("instantiating Lens V2")
 			srv, err := lens.NewV2(lens.V2Options{
+				ResolveInterval:  *resolveInterval,
+				RetrievalTimeout: *ipfsTimeout,
+				Sources:          sources,
+				Cache:            c,
+				Retrieval: &planetary.Options{
+					Retries: *ipfsRetries,
+					Backoff: planetary.DefaultOptions.Backoff,
+				},
 				Engine: engine.Opts{
 					StorePath: cfg.Lens.Options.Engine.StorePath,
 					Queue: queue.Options{
@@ -85,7 +188,7 @@ var commands = map[string]cmd.Cmd{
 
 			// set up interrupts
 			var stop = make(chan bool)
-			var signals = make(chan os.Signal)
+			var signals = make(chan os.Signal, 1)
 			signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 			go func() {
 				<-signals
@@ -101,6 +204,27 @@ var commands = map[string]cmd.Cmd{
 	},
 }
 
+// model registers the models described in the model configuration, if one
+// was provided, and retrieves the configured model
+func model() (images.Model, error) {
+	if *modelConfig != "" {
+		b, err := ioutil.ReadFile(*modelConfig)
+		if err != nil {
+			return images.Model{}, err
+		}
+		var models []images.Model
+		if err := json.Unmarshal(b, &models); err != nil {
+			return images.Model{}, fmt.Errorf("invalid model configuration: %v", err)
+		}
+		for _, m := range models {
+			if err := images.RegisterModel(m); err != nil {
+				return images.Model{}, err
+			}
+		}
+	}
+	return images.LookupModel(*modelName)
+}
+
 func main() {
 	if Version == "" {
 		Version = "unknown"
@@ -121,7 +121,9 @@ func (e *Engine) Index(doc Document) error {
 	if doc.Object == nil || doc.Object.Hash == "" {
 		return errors.New("no object details provided")
 	}
-	if e.IsIndexed(doc.Object.Hash) && !doc.Reindex {
+	var key = Key(doc.Object.Hash)
+	existing, indexed := e.lookup(doc.Object.Hash)
+	if indexed && !doc.Reindex {
 		return fmt.Errorf("document with hash '%s' already exists", doc.Object.Hash)
 	}
 	var l = e.l.With("hash", doc.Object.Hash)
@@ -141,10 +143,19 @@ func (e *Engine) Index(doc Document) error {
 		l.Warnw("queue stopped - waiting and trying again")
 		time.Sleep(3 * time.Second)
 	}
-	if err := e.q.Queue(&queue.Item{Key: doc.Object.Hash, Val: DocData{
+	if indexed && existing != key {
+		// replace documents stored under a legacy key, rather than duplicating them
+		l.Infow("migrating document to normalized key",
+			"key", key, "legacy_key", existing)
+		if err := e.q.Queue(&queue.Item{Key: existing, Val: nil}); err != nil {
+			return fmt.Errorf("could not index object: %s", err.Error())
+		}
+	}
+	if err := e.q.Queue(&queue.Item{Key: key, Val: DocData{
 		Content:  doc.Content,
 		Metadata: &doc.Object.MD,
 		Properties: &DocProps{
+			Hash:    doc.Object.Hash,
 			Indexed: time.Now().String(),
 		},
 	}}); err != nil {
@@ -158,16 +169,29 @@ func (e *Engine) Index(doc Document) error {
 
 // IsIndexed checks if the given content hash has already been indexed
 func (e *Engine) IsIndexed(hash string) bool {
+	_, ok := e.lookup(hash)
+	return ok
+}
+
+// lookup returns the key the document with the given content hash is stored
+// under, falling back to the keys it may have been stored under before CIDs
+// were normalized
+func (e *Engine) lookup(hash string) (string, bool) {
 	if hash == "" {
-		return false
+		return "", false
 	}
-	d, err := e.index.Document(hash)
-	if err == nil && d != nil && d.ID == hash {
-		return true
+	for _, key := range append([]string{Key(hash)}, legacyKeys(hash)...) {
+		d, err := e.index.Document(key)
+		if err == nil && d != nil && d.ID == key {
+			return key, true
+		}
 	}
-	return false
+	return "", false
 }
 
+// MaxResults is the maximum number of results returned by a search
+const MaxResults = 1000
+
 // Search performs a query
 func (e *Engine) Search(ctx context.Context, q Query) ([]Result, error) {
 	var l = e.l.With("query_id", q.Hash())
@@ -175,7 +199,12 @@ func (e *Engine) Search(ctx context.Context, q Query) ([]Result, error) {
 	var request = bleve.SearchRequest{
 		Query:  newBleveQuery(&q),
 		Fields: allMetaFields,
-		Size:   1000,
+		Size:   MaxResults,
+		From:   q.Offset,
+		Sort:   newSortOrder(&q),
+
+		// match locations are used to determine which sections matched
+		IncludeLocations: true,
 	}
 	l.Debugw("search constructed",
 		"query", q,
@@ -215,7 +244,8 @@ func (e *Engine) Search(ctx context.Context, q Query) ([]Result, error) {
 
 // Remove deletes an indexed object from the engine
 func (e *Engine) Remove(hash string) error {
-	if !e.IsIndexed(hash) {
+	key, ok := e.lookup(hash)
+	if !ok {
 		return fmt.Errorf("no document '%s' in index", hash)
 	}
 	if e.q.IsStopped() {
@@ -223,7 +253,7 @@ func (e *Engine) Remove(hash string) error {
 			"hash", hash)
 		time.Sleep(3 * time.Second)
 	}
-	return e.q.Queue(&queue.Item{Key: hash, Val: nil})
+	return e.q.Queue(&queue.Item{Key: key, Val: nil})
 }
 
 // Close shuts down the engine
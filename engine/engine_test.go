@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -160,6 +161,11 @@ func TestEngine_Search(t *testing.T) {
 				Hashes: []string{testObj.Hash},
 			}},
 			true},
+		{"ok: find test obj with only hash",
+			args{Query{
+				Hashes: []string{testObj.Hash},
+			}},
+			true},
 		{"fail: do NOT find test obj with wrong hash filter",
 			args{Query{
 				Text:   "Interplanetary File System",
@@ -243,7 +249,7 @@ func TestEngine_Search(t *testing.T) {
 					return
 				}
 				if got[0].Hash != testObj.Hash {
-					t.Errorf("Engine.Search() = %s, want %s", got[0].Hash, testObj)
+					t.Errorf("Engine.Search() = %s, want %s", got[0].Hash, testObj.Hash)
 				}
 				if !reflect.DeepEqual(got[0].MD, testObj.MD) {
 					t.Errorf("Engine.Search() = %v, want %v", got[0].MD, testObj.MD)
@@ -255,3 +261,560 @@ func TestEngine_Search(t *testing.T) {
 	e.Close()
 	os.RemoveAll("tmp")
 }
+
+func TestEngine_Search_headings(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 2,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	// both documents mention the phrase, but only one has it as a heading
+	e.Index(Document{&models.ObjectV2{Hash: "body"},
+		"a guide to storage, and why distributed storage is cool", false})
+	e.Index(Document{&models.ObjectV2{Hash: "heading", MD: models.MetaDataV2{
+		Headings: []string{"Distributed Storage"},
+	}}, "Distributed Storage\na guide to storage", false})
+	time.Sleep(time.Second)
+
+	r, err := e.Search(context.Background(), Query{Text: "distributed storage"})
+	if err != nil || len(r) != 2 {
+		t.Errorf("wanted 2 results, got %v (error = %v)", r, err)
+		return
+	}
+	if r[0].Hash != "heading" {
+		t.Errorf("wanted document with heading first, got %s", r[0].Hash)
+	}
+}
+
+func TestEngine_Search_comments(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 1,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	e.Index(Document{&models.ObjectV2{Hash: "code", MD: models.MetaDataV2{
+		Tags:     []string{"go"},
+		Comments: "retries requests that time out",
+	}}, "func retry() {}\nretry", false})
+	time.Sleep(time.Second)
+
+	for _, q := range []Query{
+		{Text: "retry"},
+		{Text: "requests that time out"},
+		{Text: "requests that time out", Tags: []string{"go"}},
+	} {
+		if r, err := e.Search(context.Background(), q); err != nil || len(r) != 1 {
+			t.Errorf("wanted 1 result for %+v, got %v (error = %v)", q, r, err)
+		}
+	}
+	if r, _ := e.Search(context.Background(), Query{Text: "time out", Tags: []string{"python"}}); len(r) != 0 {
+		t.Errorf("wanted no results for other language, got %v", r)
+	}
+}
+
+func TestEngine_Search_fields(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 2,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	e.Index(Document{&models.ObjectV2{Hash: "cities", MD: models.MetaDataV2{
+		Fields: []string{"city", "population"},
+	}}, "city: Vancouver\npopulation: 631486", false})
+	e.Index(Document{&models.ObjectV2{Hash: "people", MD: models.MetaDataV2{
+		Fields: []string{"name", "city"},
+	}}, "name: bob\ncity: Vancouver", false})
+	time.Sleep(time.Second)
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+	}{
+		{"shared field", []string{"city"}, []string{"cities", "people"}},
+		{"one field", []string{"population"}, []string{"cities"}},
+		{"no matching fields", []string{"country"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := e.Search(context.Background(), Query{Text: "Vancouver", Fields: tt.fields})
+			var got []string
+			for _, d := range r {
+				got = append(got, d.Hash)
+				if len(d.MD.Fields) != 2 {
+					t.Errorf("wanted stored fields, got %v", d.MD.Fields)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted results %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_Search_named(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 2,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	e.Index(Document{&models.ObjectV2{Hash: "named", MD: models.MetaDataV2{
+		IPNS: "/ipns/docs.temporal.cloud",
+	}}, "quick brown fox", false})
+	e.Index(Document{&models.ObjectV2{Hash: "direct"}, "quick brown fox", false})
+	time.Sleep(time.Second)
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"all named", Query{Named: true}, []string{"named"}},
+		{"named with text", Query{Text: "fox", Named: true}, []string{"named"}},
+		{"not restricted", Query{Text: "fox"}, []string{"direct", "named"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := e.Search(context.Background(), tt.q)
+			var got []string
+			for _, d := range r {
+				got = append(got, d.Hash)
+				if d.Hash == "named" && d.MD.IPNS != "/ipns/docs.temporal.cloud" {
+					t.Errorf("wanted stored name, got %q", d.MD.IPNS)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted results %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_Search_attributes(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 2,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	e.Index(Document{&models.ObjectV2{Hash: "beach", MD: models.MetaDataV2{
+		Attributes: map[string]string{"camera": "Canon EOS 5D", "year": "2018"},
+	}}, "beach", false})
+	e.Index(Document{&models.ObjectV2{Hash: "forest", MD: models.MetaDataV2{
+		Attributes: map[string]string{"camera": "Nikon D750", "year": "2018"},
+	}}, "forest", false})
+	time.Sleep(time.Second)
+
+	tests := []struct {
+		name  string
+		attrs map[string]string
+		want  []string
+	}{
+		{"shared attribute", map[string]string{"year": "2018"}, []string{"beach", "forest"}},
+		{"partial value", map[string]string{"camera": "canon"}, []string{"beach"}},
+		{"all attributes", map[string]string{"camera": "nikon", "year": "2018"}, []string{"forest"}},
+		{"no matching attributes", map[string]string{"year": "2019"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := e.Search(context.Background(), Query{Attributes: tt.attrs})
+			var got []string
+			for _, d := range r {
+				got = append(got, d.Hash)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted results %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_Search_labels(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 3,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	// images are tagged with their labels
+	e.Index(Document{&models.ObjectV2{Hash: "strong", MD: models.MetaDataV2{
+		Tags:   []string{"tabby cat"},
+		Labels: map[string]float64{"tabby cat": 0.9},
+	}}, "photo", false})
+	e.Index(Document{&models.ObjectV2{Hash: "weak", MD: models.MetaDataV2{
+		Tags:   []string{"tabby cat", "boat"},
+		Labels: map[string]float64{"tabby cat": 0.3, "boat": 0.6},
+	}}, "photo", false})
+	e.Index(Document{&models.ObjectV2{Hash: "tagged", MD: models.MetaDataV2{
+		Tags: []string{"tabby"},
+	}}, "photo", false})
+	time.Sleep(time.Second)
+
+	tests := []struct {
+		name          string
+		tags          []string
+		minConfidence float64
+		want          []string
+	}{
+		{"labels and tags", []string{"tabby cat"}, 0, []string{"strong", "tagged", "weak"}},
+		{"confident labels", []string{"tabby cat"}, 0.5, []string{"strong", "tagged"}},
+		{"other label", []string{"boat"}, 0.5, []string{"weak"}},
+		{"too confident", []string{"boat"}, 0.7, nil},
+		{"no matching labels", []string{"dog"}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := e.Search(context.Background(), Query{Tags: tt.tags, MinConfidence: tt.minConfidence})
+			var got []string
+			for _, d := range r {
+				got = append(got, d.Hash)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted results %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// confident labels should be ranked higher
+	r, _ := e.Search(context.Background(), Query{Tags: []string{"tabby cat"}})
+	var rank = make(map[string]int)
+	for i, d := range r {
+		rank[d.Hash] = i
+	}
+	if rank["strong"] > rank["weak"] {
+		t.Errorf("wanted strong label ranked above weak label, got %v", rank)
+	}
+}
+
+func TestEngine_Search_location(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 4,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	var (
+		vancouver = models.GeoPoint{Lat: 49.28, Lon: -123.12}
+		seattle   = models.GeoPoint{Lat: 47.61, Lon: -122.33}
+		berlin    = models.GeoPoint{Lat: 52.52, Lon: 13.405}
+	)
+	for hash, p := range map[string]models.GeoPoint{
+		"vancouver": vancouver, "seattle": seattle, "berlin": berlin,
+	} {
+		var p = p
+		e.Index(Document{&models.ObjectV2{Hash: hash, MD: models.MetaDataV2{
+			Location: &p,
+		}}, "photo of " + hash, false})
+	}
+	e.Index(Document{&models.ObjectV2{Hash: "nowhere"}, "photo of nowhere", false})
+	time.Sleep(time.Second)
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"within area",
+			Query{Text: "photo", Within: &GeoBox{
+				TopLeft:     models.GeoPoint{Lat: 50, Lon: -124},
+				BottomRight: models.GeoPoint{Lat: 47, Lon: -122},
+			}},
+			[]string{"seattle", "vancouver"}},
+		{"near point",
+			Query{Near: &GeoDistance{Point: vancouver, Distance: "50km"}},
+			[]string{"vancouver"}},
+		{"near point sorted by distance",
+			Query{Near: &GeoDistance{Point: berlin, Distance: "10000km"}, SortByDistance: true},
+			[]string{"berlin", "vancouver", "seattle"}},
+		{"near point sorted by distance from elsewhere",
+			Query{Near: &GeoDistance{Point: seattle, Distance: "10000km"}, SortByDistance: true},
+			[]string{"seattle", "vancouver", "berlin"}},
+		{"nothing nearby",
+			Query{Near: &GeoDistance{Point: models.GeoPoint{}, Distance: "10km"}},
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := e.Search(context.Background(), tt.q)
+			var got []string
+			for _, d := range r {
+				got = append(got, d.Hash)
+				if d.MD.Location == nil {
+					t.Errorf("wanted stored location for %s", d.Hash)
+				}
+			}
+			if !tt.q.SortByDistance {
+				sort.Strings(got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted results %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEngine_Search_sections(t *testing.T) {
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 1,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	var content = "Loomings\ncall me ishmael\n\nThe Carpet-Bag\na whale of a tale\n\nThe Spouter-Inn\nanother whale"
+	var sections = []models.Section{
+		{Title: "Loomings", Offset: 0},
+		{Title: "The Carpet-Bag", Offset: 26},
+		{Title: "The Spouter-Inn", Offset: 60},
+	}
+	e.Index(Document{&models.ObjectV2{Hash: "book", MD: models.MetaDataV2{
+		Sections: sections,
+	}}, content, false})
+	time.Sleep(time.Second)
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"first section", "ishmael", []string{"Loomings"}},
+		{"middle section", "tale", []string{"The Carpet-Bag"}},
+		{"multiple sections", "whale", []string{"The Carpet-Bag", "The Spouter-Inn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := e.Search(context.Background(), Query{Text: tt.text})
+			if err != nil || len(r) != 1 {
+				t.Errorf("wanted 1 result, got %v (error = %v)", r, err)
+				return
+			}
+			if !reflect.DeepEqual(r[0].MD.Sections, sections) {
+				t.Errorf("wanted sections %v, got %v", sections, r[0].MD.Sections)
+			}
+			if !reflect.DeepEqual(r[0].Sections, tt.want) {
+				t.Errorf("wanted matched sections %v, got %v", tt.want, r[0].Sections)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want string
+	}{
+		{"not a cid", "abcde", "abcde"},
+		{"cidv0", "QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW",
+			"QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW"},
+		{"cidv1", "bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme",
+			"QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Key(tt.hash); got != tt.want {
+				t.Errorf("Key() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_equivalentHashes(t *testing.T) {
+	const (
+		v0 = "QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW"
+		v1 = "bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme"
+	)
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 1,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	// index with v0, and make sure the v1 form is considered the same document
+	if err = e.Index(Document{&models.ObjectV2{Hash: v0}, "quick brown fox", false}); err != nil {
+		t.Errorf("wanted Index error = nil, got %v", err)
+		return
+	}
+	time.Sleep(time.Second)
+	if !e.IsIndexed(v1) {
+		t.Error("wanted IsIndexed(v1) = true, got false")
+	}
+	if err = e.Index(Document{&models.ObjectV2{Hash: v1}, "quick brown fox", false}); err == nil {
+		t.Error("wanted Index(v1) error, got nil")
+	}
+
+	// search by v1, and make sure the original hash is returned
+	r, err := e.Search(context.Background(), Query{Text: "fox", Hashes: []string{v1}})
+	if err != nil || len(r) != 1 {
+		t.Errorf("wanted 1 result, got %v (error = %v)", r, err)
+		return
+	}
+	if r[0].Hash != v0 {
+		t.Errorf("wanted result hash %s, got %s", v0, r[0].Hash)
+	}
+
+	// remove by v1
+	if err = e.Remove(v1); err != nil {
+		t.Errorf("wanted Remove error = nil, got %v", err)
+	}
+	time.Sleep(time.Second)
+	if e.IsIndexed(v0) {
+		t.Error("wanted IsIndexed(v0) = false, got true")
+	}
+}
+
+func TestEngine_legacyKeys(t *testing.T) {
+	const (
+		v0 = "QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW"
+		v1 = "bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme"
+	)
+	var l = zaptest.NewLogger(t).Sugar()
+	e, err := New(l, Opts{
+		StorePath: filepath.Join("tmp", t.Name()),
+		Queue: queue.Options{
+			Rate:      500 * time.Millisecond,
+			BatchSize: 1,
+		}})
+	if err != nil {
+		t.Error("failed to create engine: " + err.Error())
+		return
+	}
+	defer os.RemoveAll("tmp")
+	go e.Run()
+	defer e.Close()
+
+	// documents indexed before CIDs were normalized are keyed by their CIDv1
+	var legacy = func() {
+		if err := e.index.Index(v1, DocData{
+			Content:    "quick brown fox",
+			Metadata:   &models.MetaDataV2{MimeType: "text/plain", Category: "document"},
+			Properties: &DocProps{Indexed: time.Now().String()},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacy()
+	for _, h := range []string{v0, v1} {
+		if !e.IsIndexed(h) {
+			t.Errorf("wanted IsIndexed(%s) = true, got false", h)
+		}
+	}
+	if err = e.Index(Document{&models.ObjectV2{Hash: v0}, "quick brown fox", false}); err == nil {
+		t.Error("wanted Index(v0) error, got nil")
+	}
+	r, err := e.Search(context.Background(), Query{Text: "fox", Hashes: []string{v0}})
+	if err != nil || len(r) != 1 || r[0].Hash != v1 {
+		t.Errorf("wanted 1 result with hash %s, got %v (error = %v)", v1, r, err)
+	}
+
+	// removing by either form removes the legacy document
+	if err = e.Remove(v0); err != nil {
+		t.Errorf("wanted Remove error = nil, got %v", err)
+	}
+	time.Sleep(time.Second)
+	if e.IsIndexed(v1) {
+		t.Error("wanted IsIndexed(v1) = false after Remove, got true")
+	}
+
+	// reindexing moves the legacy document to its normalized key
+	legacy()
+	if err = e.Index(Document{&models.ObjectV2{Hash: v0}, "quick brown fox", true}); err != nil {
+		t.Errorf("wanted Index error = nil, got %v", err)
+	}
+	time.Sleep(2 * time.Second)
+	r, err = e.Search(context.Background(), Query{Text: "fox"})
+	if err != nil || len(r) != 1 || r[0].Hash != v0 {
+		t.Errorf("wanted 1 result with hash %s, got %v (error = %v)", v0, r, err)
+	}
+	if d, err := e.index.Document(v1); err != nil || d != nil {
+		t.Errorf("wanted legacy document to be removed, got %v (error = %v)", d, err)
+	}
+}
@@ -13,7 +13,18 @@ const (
 	fieldMimeType    = "metadata.mime_type"
 	fieldCategory    = "metadata.category"
 	fieldTags        = "metadata.tags"
+	fieldLabels      = "metadata.labels"
+	fieldHeadings    = "metadata.headings"
+	fieldComments    = "metadata.comments"
+	fieldReferences  = "metadata.references"
+	fieldSections    = "metadata.sections.title"
+	fieldOffsets     = "metadata.sections.offset"
+	fieldFields      = "metadata.fields"
+	fieldAttributes  = "metadata.attributes"
+	fieldLocation    = "metadata.location"
+	fieldIPNS        = "metadata.ipns"
 	fieldIndexed     = "properties.indexed"
+	fieldHash        = "properties.hash"
 )
 
 // allMetaFields includes all fields except 'content'
@@ -22,7 +33,14 @@ var allMetaFields = []string{
 	fieldMimeType,
 	fieldCategory,
 	fieldTags,
+	fieldReferences,
+	fieldSections,
+	fieldOffsets,
+	fieldFields,
+	fieldLocation,
+	fieldIPNS,
 	fieldIndexed,
+	fieldHash,
 }
 
 // DocData defines the structure of indexed objects
@@ -34,6 +52,7 @@ type DocData struct {
 
 // DocProps denotes additional information about a document
 type DocProps struct {
+	Hash    string `json:"hash"`    // content hash, as originally provided
 	Indexed string `json:"indexed"` // date indexed
 }
 
@@ -46,6 +65,7 @@ func newLensIndex() mapping.IndexMapping {
 	// DocData::Metadata
 	var mdIndex = bleve.NewDocumentMapping()
 	docData.AddSubDocumentMapping("metadata", mdIndex)
+	mdIndex.AddFieldMappingsAt("location", bleve.NewGeoPointFieldMapping())
 
 	// DocData::Properties
 	var pIndex = bleve.NewDocumentMapping()
@@ -55,6 +75,12 @@ func newLensIndex() mapping.IndexMapping {
 	// construct overall index
 	var m = bleve.NewIndexMapping()
 	m.AddDocumentMapping("objects", docData)
+
+	// DocData is not typed, so it is indexed using the default mapping - as
+	// geopoints are not detected by dynamic mapping, they must be mapped there
+	var defaultMD = bleve.NewDocumentMapping()
+	defaultMD.AddFieldMappingsAt("location", bleve.NewGeoPointFieldMapping())
+	m.DefaultMapping.AddSubDocumentMapping("metadata", defaultMD)
 	m.DefaultField = "content"
 	return m
 }
@@ -0,0 +1,35 @@
+package engine
+
+import (
+	gocid "github.com/ipfs/go-cid"
+)
+
+// Key normalizes the given content hash into the key its document is stored
+// under. CIDs are keyed by their base58-encoded multihash, so that the v0 and
+// v1 forms of the same content map to the same document. Hashes that are not
+// valid CIDs are used as-is.
+func Key(hash string) string {
+	id, err := gocid.Decode(hash)
+	if err != nil {
+		return hash
+	}
+	return id.Hash().B58String()
+}
+
+// legacyKeys returns the other keys a document with the given content hash may
+// be stored under. Documents indexed before CIDs were normalized are keyed by
+// the hash they were indexed with, which for CIDv1 is usually its default
+// string form.
+func legacyKeys(hash string) []string {
+	var key = Key(hash)
+	var keys []string
+	if hash != key {
+		keys = append(keys, hash)
+	}
+	if id, err := gocid.Decode(hash); err == nil {
+		if v1 := gocid.NewCidV1(id.Type(), id.Hash()).String(); v1 != key && v1 != hash {
+			keys = append(keys, v1)
+		}
+	}
+	return keys
+}
@@ -8,7 +8,10 @@ import (
 
 	"github.com/blevesearch/bleve"
 
+	"github.com/blevesearch/bleve/search"
 	"github.com/blevesearch/bleve/search/query"
+
+	"github.com/RTradeLtd/Lens/v2/models"
 )
 
 // Query denotes options for a search
@@ -21,9 +24,51 @@ type Query struct {
 	Categories []string
 	MimeTypes  []string
 
-	// Hashes restricts what documents to include in query - this is only a
-	// filtering option, so some other query fields must be provided as well
+	// MinConfidence is the lowest confidence of labels, ie image
+	// classifications, that match the given tags - tags also match labels,
+	// ranking more confident labels higher, and documents labelled with a tag
+	// only match if the label is confident enough
+	MinConfidence float64
+
+	// Fields are the names of keys or columns in structured data
+	Fields []string
+
+	// Attributes requires documents to have attributes matching each of the
+	// given values, ie {"year": "2018"} or {"camera": "canon"}
+	Attributes map[string]string
+
+	// Within restricts results to documents located within the given area
+	Within *GeoBox
+
+	// Near restricts results to documents located within a distance of a point,
+	// and SortByDistance orders them by their distance from it, nearest first
+	Near           *GeoDistance
+	SortByDistance bool
+
+	// Hashes restricts what documents to include in query
 	Hashes []string
+
+	// Named restricts results to documents indexed from an IPNS name
+	Named bool
+
+	// Offset skips the given number of results, to page through searches with
+	// more than MaxResults results
+	Offset int
+}
+
+// GeoBox denotes an area bounded by its top left and bottom right corners
+type GeoBox struct {
+	TopLeft     models.GeoPoint
+	BottomRight models.GeoPoint
+}
+
+// GeoDistance denotes the area within a distance of a point
+type GeoDistance struct {
+	Point models.GeoPoint
+
+	// Distance is the radius of the area, with a unit, ie "5km" or "10mi" -
+	// meters are assumed if no unit is provided
+	Distance string
 }
 
 // Hash generates a checksum hash for the query
@@ -33,16 +78,25 @@ func (q *Query) Hash() string {
 	return hex.EncodeToString(sum[:])
 }
 
+// headingsBoost is the boost applied to matches in document headings
+const headingsBoost = 5
+
 func newBleveQuery(q *Query) query.Query {
 	return query.NewConjunctionQuery(
 		func() []query.Query {
 			var qs = make([]query.Query, 0)
 
-			// require phrase
+			// require phrase, preferring documents with the phrase in a heading,
+			// and also matching comments in source code
 			if q.Text != "" {
 				var tq = query.NewMatchPhraseQuery(q.Text)
 				tq.SetField(fieldContent)
-				qs = append(qs, tq)
+				var hq = query.NewMatchPhraseQuery(q.Text)
+				hq.SetField(fieldHeadings)
+				hq.SetBoost(headingsBoost)
+				var cq = query.NewMatchPhraseQuery(q.Text)
+				cq.SetField(fieldComments)
+				qs = append(qs, query.NewDisjunctionQuery([]query.Query{tq, hq, cq}))
 			}
 
 			// require required words
@@ -52,9 +106,9 @@ func newBleveQuery(q *Query) query.Query {
 				qs = append(qs, bq)
 			}
 
-			// require one of provided tags
+			// require one of provided tags or labels
 			if len(q.Tags) > 0 {
-				qs = append(qs, newFieldTermsQuery(fieldTags, q.Tags))
+				qs = append(qs, newTagsQuery(q.Tags, q.MinConfidence))
 			}
 
 			// require one of provided categories
@@ -67,9 +121,48 @@ func newBleveQuery(q *Query) query.Query {
 				qs = append(qs, newFieldTermsQuery(fieldMimeType, q.MimeTypes))
 			}
 
+			// require one of provided fields
+			if len(q.Fields) > 0 {
+				qs = append(qs, newFieldTermsQuery(fieldFields, q.Fields))
+			}
+
+			// require all provided attributes
+			for key, value := range q.Attributes {
+				var aq = query.NewMatchPhraseQuery(value)
+				aq.SetField(fieldAttributes + "." + key)
+				qs = append(qs, aq)
+			}
+
+			// require location within area
+			if q.Within != nil {
+				var tl, br = q.Within.TopLeft, q.Within.BottomRight
+				var gq = query.NewGeoBoundingBoxQuery(tl.Lon, tl.Lat, br.Lon, br.Lat)
+				gq.SetField(fieldLocation)
+				qs = append(qs, gq)
+			}
+
+			// require location near point
+			if q.Near != nil {
+				var gq = query.NewGeoDistanceQuery(q.Near.Point.Lon, q.Near.Point.Lat, q.Near.Distance)
+				gq.SetField(fieldLocation)
+				qs = append(qs, gq)
+			}
+
+			// require an IPNS name - names are stored as /ipns/ paths, so all
+			// contain the term 'ipns'
+			if q.Named {
+				var nq = query.NewTermQuery("ipns")
+				nq.SetField(fieldIPNS)
+				qs = append(qs, nq)
+			}
+
 			// require hashses
 			if len(q.Hashes) > 0 {
-				qs = append(qs, query.NewDocIDQuery(q.Hashes))
+				var keys = make([]string, 0, len(q.Hashes))
+				for _, h := range q.Hashes {
+					keys = append(append(keys, Key(h)), legacyKeys(h)...)
+				}
+				qs = append(qs, query.NewDocIDQuery(keys))
 			}
 
 			return qs
@@ -77,6 +170,66 @@ func newBleveQuery(q *Query) query.Query {
 	)
 }
 
+// newSortOrder orders results by score, or by distance from the point the
+// query is near if requested
+func newSortOrder(q *Query) search.SortOrder {
+	var byScore = &search.SortScore{Desc: true}
+	if q.Near != nil && q.SortByDistance {
+		return search.SortOrder{&search.SortGeoDistance{
+			Field: fieldLocation,
+			Lon:   q.Near.Point.Lon,
+			Lat:   q.Near.Point.Lat,
+		}, byScore}
+	}
+	return search.SortOrder{byScore}
+}
+
+// labelTiers are the confidences above which labels are ranked higher - each
+// tier a label reaches adds to its score
+var labelTiers = []float64{0.25, 0.5, 0.75}
+
+// newTagsQuery matches documents with any of the given tags, or with labels
+// named by them of at least the given confidence. Labels are also tags, so
+// when a minimum confidence is given, tags only match documents without a
+// label of the same name.
+func newTagsQuery(tags []string, minConfidence float64) *query.BooleanQuery {
+	var (
+		bq        = newFieldTermsQuery(fieldTags, tags)
+		unlabeled *query.BooleanQuery
+	)
+	if minConfidence > 0 && bq.Should != nil {
+		unlabeled = bleve.NewBooleanQuery()
+		unlabeled.AddMust(bq)
+		bq = bleve.NewBooleanQuery()
+		bq.AddShould(unlabeled)
+	}
+	for _, t := range tags {
+		var name = strings.ToLower(strings.TrimSpace(t))
+		if len(name) < 2 {
+			continue
+		}
+		var field = fieldLabels + "." + name
+		if unlabeled != nil {
+			unlabeled.AddMustNot(newMinimumQuery(field, 0))
+		}
+		bq.AddShould(newMinimumQuery(field, minConfidence))
+		for _, tier := range labelTiers {
+			if tier > minConfidence {
+				bq.AddShould(newMinimumQuery(field, tier))
+			}
+		}
+	}
+	return bq
+}
+
+// newMinimumQuery matches numeric fields of at least the given value
+func newMinimumQuery(field string, min float64) *query.NumericRangeQuery {
+	var inclusive = true
+	var nq = query.NewNumericRangeInclusiveQuery(&min, nil, &inclusive, nil)
+	nq.SetField(field)
+	return nq
+}
+
 func stringSplitter(c rune) bool { return c == ' ' }
 
 func newFieldTermsQuery(field string, should []string) *query.BooleanQuery {
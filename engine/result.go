@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/blevesearch/bleve/search"
 
@@ -13,28 +14,111 @@ type Result struct {
 	Hash string
 	MD   models.MetaDataV2
 
+	// Sections are the titles of the document's sections that matched the
+	// query's text, if the document has sections - for subtitles, these are the
+	// timestamps of the matched cues
+	Sections []string
+
 	Score float64
 }
 
 func newResult(d *search.DocumentMatch) Result {
 	var md models.MetaDataV2
+	var hash = d.ID
 	if d.Fields != nil {
 		var fields = d.Fields
 		md.DisplayName, _ = fields[fieldDisplayName].(string)
 		md.Category, _ = fields[fieldCategory].(string)
 		md.MimeType, _ = fields[fieldMimeType].(string)
-		rawTags, _ := fields[fieldTags].([]interface{})
-		if len(rawTags) > 0 {
-			md.Tags = make([]string, len(rawTags))
-			for i, v := range rawTags {
-				md.Tags[i] = fmt.Sprint(v)
-			}
+		md.IPNS, _ = fields[fieldIPNS].(string)
+		md.Tags = toStrings(fields[fieldTags])
+		md.References = toStrings(fields[fieldReferences])
+		md.Fields = toStrings(fields[fieldFields])
+		md.Sections = toSections(fields[fieldSections], fields[fieldOffsets])
+		md.Location = toGeoPoint(fields[fieldLocation])
+		if h, ok := fields[fieldHash].(string); ok && h != "" {
+			hash = h
 		}
 	}
 
 	return Result{
-		Hash:  d.ID,
-		Score: d.Score,
-		MD:    md,
+		Hash:     hash,
+		Score:    d.Score,
+		MD:       md,
+		Sections: matchedSections(md.Sections, d.Locations[fieldContent]),
+	}
+}
+
+// toSections pairs stored section titles and offsets, ordered by offset
+func toSections(titles, offsets interface{}) []models.Section {
+	var t, o = toStrings(titles), toStrings(offsets)
+	if len(t) == 0 || len(t) != len(o) {
+		return nil
+	}
+	var sections = make([]models.Section, len(t))
+	for i := range t {
+		var offset float64
+		fmt.Sscan(o[i], &offset)
+		sections[i] = models.Section{Title: t[i], Offset: int(offset)}
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Offset < sections[j].Offset
+	})
+	return sections
+}
+
+// matchedSections returns the titles of the sections that contain the given
+// match locations, in document order
+func matchedSections(sections []models.Section, locations search.TermLocationMap) []string {
+	if len(sections) == 0 || len(locations) == 0 {
+		return nil
+	}
+	var matched = make([]bool, len(sections))
+	for _, locs := range locations {
+		for _, loc := range locs {
+			// find the last section that starts at or before the match
+			var i = sort.Search(len(sections), func(i int) bool {
+				return uint64(sections[i].Offset) > loc.Start
+			}) - 1
+			if i >= 0 {
+				matched[i] = true
+			}
+		}
+	}
+	var out []string
+	for i, m := range matched {
+		if m {
+			out = append(out, sections[i].Title)
+		}
+	}
+	return out
+}
+
+// toStrings converts a stored field into a string slice - bleve returns a
+// single value instead of a slice if only one value was stored
+func toStrings(field interface{}) []string {
+	switch v := field.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		var out = make([]string, len(v))
+		for i, s := range v {
+			out[i] = fmt.Sprint(s)
+		}
+		return out
+	case string:
+		return []string{v}
+	default:
+		return nil
+	}
+}
+
+// toGeoPoint converts a stored geopoint field, which bleve returns as a
+// longitude and latitude pair
+func toGeoPoint(field interface{}) *models.GeoPoint {
+	if v, ok := field.([]float64); ok && len(v) == 2 {
+		return &models.GeoPoint{Lat: v[1], Lon: v[0]}
 	}
+	return nil
 }
@@ -39,6 +39,7 @@ require (
 	github.com/libp2p/go-libp2p-peer v0.1.0 // indirect
 	github.com/maxbrunsfeld/counterfeiter/v6 v6.2.2
 	github.com/multiformats/go-multiaddr-dns v0.0.2 // indirect
+	github.com/multiformats/go-multihash v0.0.5
 	github.com/opentracing/opentracing-go v1.1.0 // indirect
 	github.com/otiai10/curr v0.0.0-20150429015615-9b4961190c95 // indirect
 	github.com/otiai10/gosseract v2.2.1+incompatible
@@ -52,9 +53,12 @@ require (
 	github.com/tensorflow/tensorflow v1.12.0
 	go.etcd.io/bbolt v1.3.2 // indirect
 	go.uber.org/zap v1.9.1
+	golang.org/x/image v0.18.0
 	golang.org/x/lint v0.0.0-20190409202823-959b441ac422
+	golang.org/x/net v0.25.0
 	google.golang.org/genproto v0.0.0-20190425155659-357c62f0e4bb // indirect
 	google.golang.org/grpc v1.20.1
+	gopkg.in/yaml.v2 v2.2.2
 )
 
 replace github.com/dgraph-io/badger v2.0.0-rc.2+incompatible => github.com/dgraph-io/badger v1.6.0
@@ -8,25 +8,35 @@ import (
 )
 
 type FakeTensorflowAnalyzer struct {
-	AnalyzeStub        func(string, []byte) (string, error)
+	AnalyzeStub        func(string, []byte) ([]images.Label, error)
 	analyzeMutex       sync.RWMutex
 	analyzeArgsForCall []struct {
 		arg1 string
 		arg2 []byte
 	}
 	analyzeReturns struct {
-		result1 string
+		result1 []images.Label
 		result2 error
 	}
 	analyzeReturnsOnCall map[int]struct {
-		result1 string
+		result1 []images.Label
 		result2 error
 	}
+	VersionStub        func() string
+	versionMutex       sync.RWMutex
+	versionArgsForCall []struct {
+	}
+	versionReturns struct {
+		result1 string
+	}
+	versionReturnsOnCall map[int]struct {
+		result1 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeTensorflowAnalyzer) Analyze(arg1 string, arg2 []byte) (string, error) {
+func (fake *FakeTensorflowAnalyzer) Analyze(arg1 string, arg2 []byte) ([]images.Label, error) {
 	var arg2Copy []byte
 	if arg2 != nil {
 		arg2Copy = make([]byte, len(arg2))
@@ -56,7 +66,7 @@ func (fake *FakeTensorflowAnalyzer) AnalyzeCallCount() int {
 	return len(fake.analyzeArgsForCall)
 }
 
-func (fake *FakeTensorflowAnalyzer) AnalyzeCalls(stub func(string, []byte) (string, error)) {
+func (fake *FakeTensorflowAnalyzer) AnalyzeCalls(stub func(string, []byte) ([]images.Label, error)) {
 	fake.analyzeMutex.Lock()
 	defer fake.analyzeMutex.Unlock()
 	fake.AnalyzeStub = stub
@@ -69,37 +79,91 @@ func (fake *FakeTensorflowAnalyzer) AnalyzeArgsForCall(i int) (string, []byte) {
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeTensorflowAnalyzer) AnalyzeReturns(result1 string, result2 error) {
+func (fake *FakeTensorflowAnalyzer) AnalyzeReturns(result1 []images.Label, result2 error) {
 	fake.analyzeMutex.Lock()
 	defer fake.analyzeMutex.Unlock()
 	fake.AnalyzeStub = nil
 	fake.analyzeReturns = struct {
-		result1 string
+		result1 []images.Label
 		result2 error
 	}{result1, result2}
 }
 
-func (fake *FakeTensorflowAnalyzer) AnalyzeReturnsOnCall(i int, result1 string, result2 error) {
+func (fake *FakeTensorflowAnalyzer) AnalyzeReturnsOnCall(i int, result1 []images.Label, result2 error) {
 	fake.analyzeMutex.Lock()
 	defer fake.analyzeMutex.Unlock()
 	fake.AnalyzeStub = nil
 	if fake.analyzeReturnsOnCall == nil {
 		fake.analyzeReturnsOnCall = make(map[int]struct {
-			result1 string
+			result1 []images.Label
 			result2 error
 		})
 	}
 	fake.analyzeReturnsOnCall[i] = struct {
-		result1 string
+		result1 []images.Label
 		result2 error
 	}{result1, result2}
 }
 
+func (fake *FakeTensorflowAnalyzer) Version() string {
+	fake.versionMutex.Lock()
+	ret, specificReturn := fake.versionReturnsOnCall[len(fake.versionArgsForCall)]
+	fake.versionArgsForCall = append(fake.versionArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Version", []interface{}{})
+	fake.versionMutex.Unlock()
+	if fake.VersionStub != nil {
+		return fake.VersionStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.versionReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeTensorflowAnalyzer) VersionCallCount() int {
+	fake.versionMutex.RLock()
+	defer fake.versionMutex.RUnlock()
+	return len(fake.versionArgsForCall)
+}
+
+func (fake *FakeTensorflowAnalyzer) VersionCalls(stub func() string) {
+	fake.versionMutex.Lock()
+	defer fake.versionMutex.Unlock()
+	fake.VersionStub = stub
+}
+
+func (fake *FakeTensorflowAnalyzer) VersionReturns(result1 string) {
+	fake.versionMutex.Lock()
+	defer fake.versionMutex.Unlock()
+	fake.VersionStub = nil
+	fake.versionReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeTensorflowAnalyzer) VersionReturnsOnCall(i int, result1 string) {
+	fake.versionMutex.Lock()
+	defer fake.versionMutex.Unlock()
+	fake.VersionStub = nil
+	if fake.versionReturnsOnCall == nil {
+		fake.versionReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.versionReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeTensorflowAnalyzer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.analyzeMutex.RLock()
 	defer fake.analyzeMutex.RUnlock()
+	fake.versionMutex.RLock()
+	defer fake.versionMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
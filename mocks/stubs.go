@@ -1,9 +1,14 @@
 package mocks
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"os"
+
+	shell "github.com/RTradeLtd/go-ipfs-api"
 )
 
 // StubIpfsCat returns a stub function for use in testing. It errors on blank
@@ -21,3 +26,56 @@ func StubIpfsCat(assetPath string) func(h string) (c []byte, e error) {
 		return nil, errors.New("oh no")
 	}
 }
+
+// StubIpfsRequest returns a stub function for use in testing in place of
+// CustomRequest. It errors on blank paths, otherwise it responds to "cat" and
+// "dag/get" with the contents of the file at given path, and to "object/stat"
+// with the size of the file at given path
+func StubIpfsRequest(assetPath string) func(
+	ctx context.Context, url, command string, opts map[string]string, args ...string,
+) (*shell.Response, error) {
+	return func(ctx context.Context, url, command string, opts map[string]string, args ...string) (*shell.Response, error) {
+		if len(args) < 1 || args[0] == "" {
+			return nil, errors.New("oh no")
+		}
+		switch command {
+		case "object/stat":
+			info, err := os.Stat(assetPath)
+			if err != nil {
+				return &shell.Response{Error: &shell.Error{Command: command, Message: err.Error()}}, nil
+			}
+			b, _ := json.Marshal(&shell.ObjectStats{
+				Hash:           args[0],
+				CumulativeSize: int(info.Size()),
+				DataSize:       int(info.Size()),
+			})
+			return &shell.Response{Output: ioutil.NopCloser(bytes.NewReader(b))}, nil
+		default:
+			f, err := os.Open(assetPath)
+			if err != nil {
+				return &shell.Response{Error: &shell.Error{Command: command, Message: err.Error()}}, nil
+			}
+			return &shell.Response{Output: f}, nil
+		}
+	}
+}
+
+// StubIpfsResolve returns a stub function for use in testing in place of
+// CustomRequest. It responds to "name/resolve" with the given path, or with
+// the given error if it is not nil, and otherwise behaves like
+// StubIpfsRequest.
+func StubIpfsResolve(assetPath, path string, err error) func(
+	ctx context.Context, url, command string, opts map[string]string, args ...string,
+) (*shell.Response, error) {
+	var request = StubIpfsRequest(assetPath)
+	return func(ctx context.Context, url, command string, opts map[string]string, args ...string) (*shell.Response, error) {
+		if command != "name/resolve" {
+			return request(ctx, url, command, opts, args...)
+		}
+		if err != nil {
+			return &shell.Response{Error: &shell.Error{Command: command, Message: err.Error()}}, nil
+		}
+		b, _ := json.Marshal(struct{ Path string }{path})
+		return &shell.Response{Output: ioutil.NopCloser(bytes.NewReader(b))}, nil
+	}
+}
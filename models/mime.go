@@ -13,4 +13,12 @@ const (
 	MimeTypeDocument = "document"
 	// MimeTypeImage is an image asset
 	MimeTypeImage = "image"
+	// MimeTypeIPLD is a structured (non-UnixFS) IPLD object
+	MimeTypeIPLD = "ipld"
+	// MimeTypeArchive is a bundle of files, ie a zip or tar archive
+	MimeTypeArchive = "archive"
+	// MimeTypeAudio is an audio file, ie music or a podcast
+	MimeTypeAudio = "audio"
+	// MimeTypeVideo is a video file
+	MimeTypeVideo = "video"
 )
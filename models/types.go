@@ -6,4 +6,49 @@ type MetaDataV2 struct {
 	MimeType    string   `json:"mime_type"`
 	Category    string   `json:"category"`
 	Tags        []string `json:"tags"`
+
+	// Labels are classifications of the object, ie what an image depicts,
+	// mapped to their confidence between 0 and 1 - searches for tags also
+	// match labels, preferring confident ones
+	Labels map[string]float64 `json:"labels,omitempty"`
+
+	// Headings are section titles within the object
+	Headings []string `json:"headings,omitempty"`
+
+	// Comments are the comments in source code
+	Comments string `json:"comments,omitempty"`
+
+	// Sections are the parts of the object's content, ie the chapters of a book
+	// or the cues of subtitles
+	Sections []Section `json:"sections,omitempty"`
+
+	// References are content hashes of other objects this object links to
+	References []string `json:"references,omitempty"`
+
+	// Fields are the names of the keys or columns in structured data
+	Fields []string `json:"fields,omitempty"`
+
+	// Attributes are additional properties of the object, ie its author
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// Location is where the object was created or what it describes, ie where
+	// a photo was taken
+	Location *GeoPoint `json:"location,omitempty"`
+
+	// IPNS is the IPNS name or DNSLink domain the object was resolved from
+	IPNS string `json:"ipns,omitempty"`
+}
+
+// GeoPoint denotes a position in decimal degrees
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Section denotes a titled part of an object's content
+type Section struct {
+	Title string `json:"title"`
+
+	// Offset is the position in the content, in bytes, where the section starts
+	Offset int `json:"offset"`
 }
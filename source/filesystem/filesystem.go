@@ -0,0 +1,98 @@
+// Package filesystem provides a content source for files on the local
+// filesystem, addressed by file:// URIs
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Scheme is the URI scheme handled by this source
+const Scheme = "file"
+
+// Source retrieves files from within a root directory. Paths in URIs are
+// relative to the root, and files outside of it cannot be accessed.
+type Source struct {
+	root string
+}
+
+// New instantiates a filesystem source that serves files under the given root
+func New(root string) (*Source, error) {
+	if root == "" {
+		return nil, errors.New("no root directory provided")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err = filepath.EvalSymlinks(abs); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("root '%s' is not a directory", root)
+	}
+	return &Source{root: abs}, nil
+}
+
+// Fetch opens the file at the given URI
+func (s *Source) Fetch(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := s.path(uri)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Stat returns the size of the file at the given URI
+func (s *Source) Stat(ctx context.Context, uri string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	path, err := s.path(uri)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// path converts the given URI into a path on the filesystem, ensuring that it
+// refers to a regular file within the root directory
+func (s *Source) path(uri string) (string, error) {
+	if !strings.HasPrefix(uri, Scheme+"://") {
+		return "", fmt.Errorf("invalid file uri '%s'", uri)
+	}
+	var rel = strings.TrimPrefix(uri, Scheme+"://")
+
+	// resolve symlinks so that they can't be used to escape the root
+	path, err := filepath.EvalSymlinks(filepath.Join(s.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", err
+	}
+	if path != s.root && !strings.HasPrefix(path, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("file '%s' is outside of the root directory", rel)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("'%s' is not a regular file", rel)
+	}
+	return path, nil
+}
@@ -0,0 +1,107 @@
+package filesystem_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/source/filesystem"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		root    string
+		wantErr bool
+	}{
+		{"no root", "", true},
+		{"nonexistent root", "../../test/nope", true},
+		{"root is a file", "../../test/config.json", true},
+		{"ok", "../../test", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := filesystem.New(tt.root); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	// set up a link that points outside of the root
+	os.Remove("../../test/assets/link.md")
+	if err := os.Symlink("../../README.md", "../../test/assets/link.md"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("../../test/assets/link.md")
+
+	s, err := filesystem.New("../../test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"wrong scheme", "https://config.json", "", true},
+		{"nonexistent file", "file://nope.json", "", true},
+		{"directory", "file://assets", "", true},
+		{"outside of root", "file://../README.md", "", true},
+		{"link outside of root", "file://assets/link.md", "", true},
+		{"ok", "file://config.json", "../../test/config.json", false},
+		{"ok: nested", "file://assets/text.pdf", "../../test/assets/text.pdf", false},
+		{"ok: absolute", "file:///config.json", "../../test/config.json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := s.Stat(context.Background(), tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Source.Stat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			r, err := s.Fetch(context.Background(), tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Source.Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			defer r.Close()
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadFile(filepath.FromSlash(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Source.Fetch() returned unexpected contents")
+			}
+			if size != int64(len(want)) {
+				t.Errorf("Source.Stat() = %d, want %d", size, len(want))
+			}
+		})
+	}
+}
+
+func TestSource_cancelled(t *testing.T) {
+	s, err := filesystem.New("../../test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Stat(ctx, "file://config.json"); err != context.Canceled {
+		t.Errorf("Source.Stat() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := s.Fetch(ctx, "file://config.json"); err != context.Canceled {
+		t.Errorf("Source.Fetch() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,141 @@
+// Package gateway provides a content source for objects served over HTTP(S),
+// such as by IPFS gateways, addressed by http:// and https:// URIs
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Schemes are the URI schemes handled by this source
+var Schemes = []string{"http", "https"}
+
+// Source retrieves objects over HTTP(S). Only hosts that have been explicitly
+// allowed can be accessed, so that requests can't be used to probe arbitrary
+// (ie internal) services.
+type Source struct {
+	client *http.Client
+	hosts  map[string]bool
+}
+
+// New instantiates a gateway source that can access the given hosts, which may
+// include ports, ie "ipfs.io" or "localhost:8080". If client is nil, a default
+// client is used.
+func New(hosts []string, client *http.Client) (*Source, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("no allowed hosts provided")
+	}
+	var s = &Source{hosts: make(map[string]bool, len(hosts))}
+	for _, h := range hosts {
+		s.hosts[strings.ToLower(h)] = true
+	}
+
+	// copy the client so that its redirect policy can be set
+	var c http.Client
+	if client != nil {
+		c = *client
+	}
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("too many redirects")
+		}
+		return s.check(req.URL)
+	}
+	s.client = &c
+	return s, nil
+}
+
+// Fetch retrieves the object at the given URI
+func (s *Source) Fetch(ctx context.Context, uri string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Stat retrieves the size of the object at the given URI, as reported by the
+// host. Not all hosts support HEAD requests or report sizes for them, so a
+// ranged GET request is used as a fallback. If the host does not report a
+// size, -1 is returned.
+func (s *Source) Stat(ctx context.Context, uri string) (int64, error) {
+	resp, err := s.do(ctx, http.MethodHead, uri, nil)
+	if err == nil {
+		resp.Body.Close()
+		if resp.ContentLength >= 0 {
+			return resp.ContentLength, nil
+		}
+	} else if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	// hosts that ignore the range respond with the full object instead, in
+	// which case its length is used without reading it
+	resp, err = s.do(ctx, http.MethodGet, uri, http.Header{"Range": {"bytes=0-0"}})
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPartialContent {
+		return rangeSize(resp.Header.Get("Content-Range")), nil
+	}
+	return resp.ContentLength, nil
+}
+
+// rangeSize parses the total size from the given Content-Range header, ie
+// "bytes 0-0/1234", returning -1 if it is unknown
+func rangeSize(header string) int64 {
+	var i = strings.LastIndex(header, "/")
+	if i < 0 {
+		return -1
+	}
+	size, err := strconv.ParseInt(header[i+1:], 10, 64)
+	if err != nil || size < 0 {
+		return -1
+	}
+	return size
+}
+
+// do executes a request with the given headers against an allowed host
+func (s *Source) do(ctx context.Context, method, uri string, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.check(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status '%s' for '%s'", resp.Status, uri)
+	}
+	return resp, nil
+}
+
+// check ensures that the given URL can be accessed
+func (s *Source) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme '%s'", u.Scheme)
+	}
+	if !s.hosts[strings.ToLower(u.Host)] {
+		return fmt.Errorf("host '%s' is not allowed", u.Host)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package gateway_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RTradeLtd/Lens/v2/source/gateway"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := gateway.New(nil, nil); err == nil {
+		t.Error("New() expected error for no allowed hosts")
+	}
+	if _, err := gateway.New([]string{"ipfs.io"}, nil); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+}
+
+func TestSource(t *testing.T) {
+	var forbidden = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer forbidden.Close()
+	var allowed = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ipfs/hello":
+			w.Write([]byte("hello world"))
+		case "/redirect":
+			http.Redirect(w, r, forbidden.URL, http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer allowed.Close()
+
+	s, err := gateway.New([]string{strings.TrimPrefix(allowed.URL, "http://")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"wrong scheme", "file://" + strings.TrimPrefix(allowed.URL, "http://") + "/ipfs/hello", "", true},
+		{"host not allowed", forbidden.URL, "", true},
+		{"redirect to host not allowed", allowed.URL + "/redirect", "", true},
+		{"not found", allowed.URL + "/ipfs/nope", "", true},
+		{"ok", allowed.URL + "/ipfs/hello", "hello world", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := s.Stat(context.Background(), tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Source.Stat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			r, err := s.Fetch(context.Background(), tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Source.Fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			defer r.Close()
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Source.Fetch() = %s, want %s", string(got), tt.want)
+			}
+			if size != int64(len(tt.want)) {
+				t.Errorf("Source.Stat() = %d, want %d", size, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSource_Stat(t *testing.T) {
+	var content = strings.Repeat("hello world", 100)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/head":
+			w.Write([]byte(content))
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+		case "/no-head-length":
+			if r.Method == http.MethodHead {
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+		case "/no-range":
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content))
+		case "/unknown-range":
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.Header().Set("Content-Range", "bytes 0-0/*")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(content[:1]))
+		case "/no-length":
+			if r.Method == http.MethodHead {
+				return
+			}
+			w.Write([]byte(content[:1]))
+			w.(http.Flusher).Flush()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s, err := gateway.New([]string{strings.TrimPrefix(server.URL, "http://")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		want    int64
+		wantErr bool
+	}{
+		{"not found", "/nope", 0, true},
+		{"head", "/head", int64(len(content)), false},
+		{"head not allowed", "/no-head", int64(len(content)), false},
+		{"head without length", "/no-head-length", int64(len(content)), false},
+		{"range not supported", "/no-range", int64(len(content)), false},
+		{"unknown range size", "/unknown-range", -1, false},
+		{"unknown size", "/no-length", -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Stat(context.Background(), server.URL+tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Source.Stat() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Source.Stat() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,27 +1,134 @@
 package planetary
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	ipfsapi "github.com/RTradeLtd/go-ipfs-api"
 	"github.com/RTradeLtd/rtfs/v2"
 )
 
+// Scheme is the URI scheme handled by the extractor when used as a content
+// source. Content hashes may also be provided without a scheme.
+const Scheme = "ipfs"
+
+// Options denotes configuration for the extractor
+type Options struct {
+	// Retries is the number of times a request that failed due to a transient
+	// error is retried
+	Retries int
+
+	// Backoff is the delay before the first retry, which is doubled for each
+	// subsequent retry
+	Backoff time.Duration
+}
+
+// DefaultOptions is the default extractor configuration
+var DefaultOptions = Options{
+	Retries: 3,
+	Backoff: 500 * time.Millisecond,
+}
+
 // Extractor is how we grab data from ipld objects
 type Extractor struct {
-	im rtfs.Manager
+	im   rtfs.Manager
+	opts Options
 }
 
 // NewPlanetaryExtractor is used to generate our IPLD object extractor
-func NewPlanetaryExtractor(ipfsManager rtfs.Manager) *Extractor {
+func NewPlanetaryExtractor(ipfsManager rtfs.Manager, opts Options) *Extractor {
 	return &Extractor{
-		im: ipfsManager,
+		im:   ipfsManager,
+		opts: opts,
 	}
 }
 
 // ExtractObject is used to extract an IPLD object from a content hash
-func (e *Extractor) ExtractObject(contentHash string, out interface{}) error {
-	return e.im.DagGet(contentHash, out)
+func (e *Extractor) ExtractObject(ctx context.Context, contentHash string, out interface{}) error {
+	r, err := e.request(ctx, "dag/get", contentHash)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(out)
 }
 
 // ExtractContents is used to extract the contents from the ipld object
-func (e *Extractor) ExtractContents(contentHash string) ([]byte, error) {
-	return e.im.Cat(contentHash)
+func (e *Extractor) ExtractContents(ctx context.Context, contentHash string) ([]byte, error) {
+	r, err := e.ExtractReader(ctx, contentHash)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// ExtractReader is used to stream the contents of the ipld object. The caller
+// is responsible for closing the returned reader.
+func (e *Extractor) ExtractReader(ctx context.Context, contentHash string) (io.ReadCloser, error) {
+	return e.request(ctx, "cat", contentHash)
+}
+
+// Fetch implements source.Source, and is equivalent to ExtractReader
+func (e *Extractor) Fetch(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return e.ExtractReader(ctx, uri)
+}
+
+// Stat is used to retrieve the cumulative size of the ipld object, which
+// includes the size of all the objects it links to
+func (e *Extractor) Stat(ctx context.Context, contentHash string) (int64, error) {
+	r, err := e.request(ctx, "object/stat", contentHash)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	var stats ipfsapi.ObjectStats
+	if err = json.NewDecoder(r).Decode(&stats); err != nil {
+		return 0, err
+	}
+	return int64(stats.CumulativeSize), nil
+}
+
+// Resolve retrieves the path the given IPNS name currently points to
+func (e *Extractor) Resolve(ctx context.Context, name string) (string, error) {
+	r, err := e.request(ctx, "name/resolve", name)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	var out struct{ Path string }
+	if err = json.NewDecoder(r).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.Path, nil
+}
+
+// request executes the given command against the IPFS node, retrying on
+// transient errors. The caller is responsible for closing the returned reader.
+func (e *Extractor) request(ctx context.Context, command, contentHash string) (io.ReadCloser, error) {
+	contentHash = strings.TrimPrefix(contentHash, Scheme+"://")
+	var out io.ReadCloser
+	if err := retry(ctx, e.opts, func() error {
+		resp, err := e.im.CustomRequest(ctx, e.im.NodeAddress(), command, nil, contentHash)
+		if err != nil {
+			return err
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if resp.Output == nil {
+			out = ioutil.NopCloser(&bytes.Buffer{})
+		} else {
+			out = resp.Output
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
@@ -1,6 +1,8 @@
 package planetary_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -9,6 +11,7 @@ import (
 	"github.com/RTradeLtd/config/v2"
 	"github.com/RTradeLtd/rtfs/v2"
 
+	"github.com/RTradeLtd/Lens/v2/mocks"
 	"github.com/RTradeLtd/Lens/v2/source/planetary"
 )
 
@@ -32,9 +35,9 @@ func TestPlanetaryExtractor(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	px := planetary.NewPlanetaryExtractor(manager)
+	px := planetary.NewPlanetaryExtractor(manager, planetary.DefaultOptions)
 	var out interface{}
-	if err = px.ExtractObject(testHash, &out); err != nil {
+	if err = px.ExtractObject(context.Background(), testHash, &out); err != nil {
 		t.Fatal(err)
 	}
 	cidObj, err := planetary.DecodeStringToCID(testHash)
@@ -49,8 +52,51 @@ func TestPlanetaryExtractor(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	_, err = px.ExtractContents(testHash)
+	_, err = px.ExtractContents(context.Background(), testHash)
 	if err != nil {
 		t.Fatal(err)
 	}
+	size, err := px.Stat(context.Background(), testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size < 1 {
+		t.Fatalf("unexpected size %d returned", size)
+	}
+	r, err := px.ExtractReader(context.Background(), testHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+}
+
+func TestExtractor_Resolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		err     error
+		want    string
+		wantErr bool
+	}{
+		{"ok", "/ipfs/" + testHash, nil, "/ipfs/" + testHash, false},
+		{"resolve failure", "", errors.New("could not resolve name"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			ipfs.CustomRequestStub = mocks.StubIpfsResolve("", tt.path, tt.err)
+			var px = planetary.NewPlanetaryExtractor(ipfs, planetary.DefaultOptions)
+			got, err := px.Resolve(context.Background(), "/ipns/docs.temporal.cloud")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+			if _, _, command, _, args := ipfs.CustomRequestArgsForCall(0); command != "name/resolve" ||
+				len(args) != 1 || args[0] != "/ipns/docs.temporal.cloud" {
+				t.Errorf("Resolve() requested %s %v", command, args)
+			}
+		})
+	}
 }
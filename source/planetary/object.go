@@ -0,0 +1,87 @@
+package planetary
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// codecDagJSON is the multicodec for dag-json, which is not yet provided by
+// go-cid
+const codecDagJSON = 0x0129
+
+// IsUnixFS checks if the given CID refers to a UnixFS (file) object, which
+// can be retrieved as raw bytes - everything else is a structured IPLD node
+func IsUnixFS(id gocid.Cid) bool {
+	switch id.Type() {
+	case gocid.DagProtobuf, gocid.Raw:
+		return true
+	default:
+		return false
+	}
+}
+
+// MimeType returns the mime type of the IPLD codec used by the given CID
+func MimeType(id gocid.Cid) string {
+	switch id.Type() {
+	case gocid.DagCBOR:
+		return "application/vnd.ipld.dag-cbor"
+	case codecDagJSON:
+		return "application/vnd.ipld.dag-json"
+	}
+	if name, ok := gocid.CodecToStr[id.Type()]; ok {
+		return "application/vnd.ipld." + name
+	}
+	return "application/vnd.ipld.unknown"
+}
+
+// Flatten converts a decoded IPLD node into searchable text, with one
+// "path: value" line per leaf field. Links to other objects (encoded as
+// {"/": "<cid>"} by the IPFS DAG API) are not included in the text, and are
+// returned as references instead.
+func Flatten(node interface{}) (text string, links []string) {
+	var lines = make([]string, 0)
+	links = make([]string, 0)
+	flatten("", node, &lines, &links)
+	return strings.Join(lines, "\n"), links
+}
+
+func flatten(path string, node interface{}, lines, links *[]string) {
+	switch n := node.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		if link, ok := n["/"]; ok && len(n) == 1 {
+			// bytes are encoded as {"/": {"bytes": "..."}}, and are not useful
+			// for indexing, so only keep proper links
+			if s, ok := link.(string); ok {
+				*links = append(*links, s)
+			}
+			return
+		}
+		var keys = make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var p = k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, n[k], lines, links)
+		}
+	case []interface{}:
+		for _, v := range n {
+			flatten(path, v, lines, links)
+		}
+	default:
+		if path == "" {
+			*lines = append(*lines, fmt.Sprint(n))
+		} else {
+			*lines = append(*lines, path+": "+fmt.Sprint(n))
+		}
+	}
+}
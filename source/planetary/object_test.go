@@ -0,0 +1,71 @@
+package planetary_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/source/planetary"
+)
+
+func TestIsUnixFS(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     string
+		wantFS   bool
+		wantMime string
+	}{
+		{"cidv0", testHash, true, "application/vnd.ipld.protobuf"},
+		{"cidv1 dag-pb", "bafybeica6bziiq3iexvaotrt5xjmysn4ew2ks74otrdixigpcaszxrclme", true, "application/vnd.ipld.protobuf"},
+		{"cidv1 dag-cbor", "bafyreiebfojbltjpbz7kihzsvbawqa66fph25ulmioxficx4mwrbri62by", false, "application/vnd.ipld.dag-cbor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := planetary.DecodeStringToCID(tt.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := planetary.IsUnixFS(id); got != tt.wantFS {
+				t.Errorf("IsUnixFS() = %v, want %v", got, tt.wantFS)
+			}
+			if got := planetary.MimeType(id); got != tt.wantMime {
+				t.Errorf("MimeType() = %v, want %v", got, tt.wantMime)
+			}
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		name      string
+		node      string
+		wantText  string
+		wantLinks []string
+	}{
+		{"nil", `null`, "", []string{}},
+		{"scalar", `"hello"`, "hello", []string{}},
+		{"flat object", `{"name": "bob", "age": 24}`,
+			"age: 24\nname: bob", []string{}},
+		{"nested object", `{"author": {"name": "bob", "tags": ["a", "b"]}}`,
+			"author.name: bob\nauthor.tags: a\nauthor.tags: b", []string{}},
+		{"with links", `{"title": "post", "prev": {"/": "` + testHash + `"}, "refs": [{"/": "` + testHashPdf + `"}]}`,
+			"title: post", []string{testHash, testHashPdf}},
+		{"with bytes", `{"data": {"/": {"bytes": "aGVsbG8="}}}`,
+			"", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node interface{}
+			if err := json.Unmarshal([]byte(tt.node), &node); err != nil {
+				t.Fatal(err)
+			}
+			gotText, gotLinks := planetary.Flatten(node)
+			if gotText != tt.wantText {
+				t.Errorf("Flatten() text = %q, want %q", gotText, tt.wantText)
+			}
+			if !reflect.DeepEqual(gotLinks, tt.wantLinks) {
+				t.Errorf("Flatten() links = %v, want %v", gotLinks, tt.wantLinks)
+			}
+		})
+	}
+}
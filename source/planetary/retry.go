@@ -0,0 +1,53 @@
+package planetary
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	ipfsapi "github.com/RTradeLtd/go-ipfs-api"
+)
+
+// retry executes fn until it succeeds, fails with a non-transient error, runs
+// out of retries, or the context is done
+func retry(ctx context.Context, opts Options, fn func() error) error {
+	var backoff = opts.Backoff
+	for attempt := 0; ; attempt++ {
+		var err = fn()
+		if err == nil {
+			return nil
+		}
+
+		// context errors take precedence, since they are often wrapped in
+		// network errors
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= opts.Retries || !IsTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// IsTransient checks if the given error is a transient failure to reach the
+// IPFS node, in which case the request can be retried
+func IsTransient(err error) bool {
+	switch err.(type) {
+	case nil:
+		return false
+	case *ipfsapi.Error:
+		// the node responded, so retrying will likely give the same result
+		return false
+	case net.Error:
+		return true
+	}
+	return err == io.ErrUnexpectedEOF
+}
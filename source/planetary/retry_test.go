@@ -0,0 +1,97 @@
+package planetary_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	shell "github.com/RTradeLtd/go-ipfs-api"
+
+	"github.com/RTradeLtd/Lens/v2/mocks"
+	"github.com/RTradeLtd/Lens/v2/source/planetary"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"generic error", errors.New("oh no"), false},
+		{"ipfs error", &shell.Error{Message: "merkledag: not found"}, false},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planetary.IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractor_retries(t *testing.T) {
+	var netErr = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	var opts = planetary.Options{Retries: 2, Backoff: time.Millisecond}
+	type returns struct {
+		errs []error
+	}
+	tests := []struct {
+		name      string
+		returns   returns
+		wantCalls int
+		wantErr   bool
+	}{
+		{"ok",
+			returns{nil}, 1, false},
+		{"ok: transient errors",
+			returns{[]error{netErr, netErr}}, 3, false},
+		{"too many transient errors",
+			returns{[]error{netErr, netErr, netErr}}, 3, true},
+		{"non-transient error",
+			returns{[]error{errors.New("oh no")}}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			for i, err := range tt.returns.errs {
+				ipfs.CustomRequestReturnsOnCall(i, nil, err)
+			}
+			ipfs.CustomRequestReturns(&shell.Response{
+				Output: ioutil.NopCloser(bytes.NewBufferString("hello world")),
+			}, nil)
+
+			var px = planetary.NewPlanetaryExtractor(ipfs, opts)
+			got, err := px.ExtractContents(context.Background(), testHash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExtractContents() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != "hello world" {
+				t.Errorf("ExtractContents() = %s, want %s", string(got), "hello world")
+			}
+			if calls := ipfs.CustomRequestCallCount(); calls != tt.wantCalls {
+				t.Errorf("got %d requests, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExtractor_cancelled(t *testing.T) {
+	var ipfs = &mocks.FakeRTFSManager{}
+	ipfs.CustomRequestReturns(nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var px = planetary.NewPlanetaryExtractor(ipfs, planetary.Options{Retries: 5, Backoff: time.Second})
+	if _, err := px.Stat(ctx, testHash); err != context.Canceled {
+		t.Errorf("Stat() error = %v, want %v", err, context.Canceled)
+	}
+	if calls := ipfs.CustomRequestCallCount(); calls != 1 {
+		t.Errorf("got %d requests, want %d", calls, 1)
+	}
+}
@@ -0,0 +1,65 @@
+// Package source defines locations that content can be retrieved from for
+// indexing, and a registry to select one based on the scheme of a URI
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Source is a location that content can be retrieved from
+type Source interface {
+	// Fetch streams the contents of the object at the given URI. The caller is
+	// responsible for closing the returned reader.
+	Fetch(ctx context.Context, uri string) (io.ReadCloser, error)
+
+	// Stat returns the size of the object at the given URI, or -1 if the size
+	// cannot be determined before retrieving the object
+	Stat(ctx context.Context, uri string) (int64, error)
+}
+
+// Parse splits the given URI into its scheme and the remainder of the URI. If
+// the URI has no scheme, an empty scheme is returned.
+func Parse(uri string) (scheme, path string) {
+	var i = strings.Index(uri, "://")
+	if i < 1 {
+		return "", uri
+	}
+	return strings.ToLower(uri[:i]), uri[i+3:]
+}
+
+// Registry maps URI schemes to the sources that handle them
+type Registry struct {
+	sources map[string]Source
+	mux     sync.RWMutex
+}
+
+// NewRegistry instantiates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{sources: make(map[string]Source)}
+}
+
+// Register sets the source for the given scheme, replacing any existing source
+func (r *Registry) Register(scheme string, s Source) {
+	r.mux.Lock()
+	r.sources[strings.ToLower(scheme)] = s
+	r.mux.Unlock()
+}
+
+// Get returns the source registered for the scheme of the given URI
+func (r *Registry) Get(uri string) (Source, error) {
+	var scheme, _ = Parse(uri)
+	if scheme == "" {
+		return nil, fmt.Errorf("no scheme provided in '%s'", uri)
+	}
+	r.mux.RLock()
+	s, ok := r.sources[scheme]
+	r.mux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported scheme '%s'", scheme)
+	}
+	return s, nil
+}
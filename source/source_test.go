@@ -0,0 +1,70 @@
+package source_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/RTradeLtd/Lens/v2/source"
+)
+
+type testSource struct{ name string }
+
+func (t *testSource) Fetch(ctx context.Context, uri string) (io.ReadCloser, error) { return nil, nil }
+func (t *testSource) Stat(ctx context.Context, uri string) (int64, error)          { return 0, nil }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		uri        string
+		wantScheme string
+		wantPath   string
+	}{
+		{"no scheme", "QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW", "", "QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW"},
+		{"ipns path", "/ipns/docs.ipfs.io", "", "/ipns/docs.ipfs.io"},
+		{"empty scheme", "://asdf", "", "://asdf"},
+		{"file", "file://docs/README.md", "file", "docs/README.md"},
+		{"uppercase scheme", "HTTPS://ipfs.io/ipfs/Qm", "https", "ipfs.io/ipfs/Qm"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotScheme, gotPath := source.Parse(tt.uri)
+			if gotScheme != tt.wantScheme {
+				t.Errorf("Parse() scheme = %v, want %v", gotScheme, tt.wantScheme)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("Parse() path = %v, want %v", gotPath, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestRegistry_Get(t *testing.T) {
+	var r = source.NewRegistry()
+	r.Register("file", &testSource{"file"})
+	r.Register("HTTPS", &testSource{"https"})
+
+	tests := []struct {
+		name    string
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"no scheme", "README.md", "", true},
+		{"unregistered scheme", "s3://bucket/README.md", "", true},
+		{"ok: file", "file://README.md", "file", false},
+		{"ok: case insensitive", "https://ipfs.io", "https", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Get(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Registry.Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.(*testSource).name != tt.want {
+				t.Errorf("Registry.Get() = %v, want %v", got.(*testSource).name, tt.want)
+			}
+		})
+	}
+}
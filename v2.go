@@ -3,30 +3,48 @@ package lens
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/RTradeLtd/grpc/lensv2"
 	"github.com/RTradeLtd/rtfs/v2"
 
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/archive"
+	"github.com/RTradeLtd/Lens/v2/analyzer/cache"
 	"github.com/RTradeLtd/Lens/v2/analyzer/images"
 	"github.com/RTradeLtd/Lens/v2/analyzer/ocr"
 	"github.com/RTradeLtd/Lens/v2/engine"
+	"github.com/RTradeLtd/Lens/v2/models"
+	"github.com/RTradeLtd/Lens/v2/source"
 	"github.com/RTradeLtd/Lens/v2/source/planetary"
 )
 
 // V2 is the new Lens API, and implements the LensV2 gRPC interface directly.
 type V2 struct {
-	se   engine.Searcher
-	ipfs rtfs.Manager
+	se      engine.Searcher
+	ipfs    rtfs.Manager
+	sources *source.Registry
 
 	// Analysis classes
-	oc *ocr.Analyzer
-	px *planetary.Extractor
-	tf images.TensorflowAnalyzer
+	px        *planetary.Extractor
+	analyzers *analyzer.Registry
+
+	// Limits on indexed objects
+	limits  SizeLimits
+	timeout time.Duration
+	stream  int64
+
+	// IPNS names that have been indexed
+	names *nameTracker
+	stop  chan bool
 
 	l *zap.SugaredLogger
 }
@@ -35,9 +53,82 @@ type V2 struct {
 type V2Options struct {
 	TesseractConfigPath string
 
+	// ResolveInterval is the interval at which indexed IPNS names are
+	// re-resolved - set to 0 to disable
+	ResolveInterval time.Duration
+
+	// SizeLimits sets the maximum size of objects that can be indexed
+	SizeLimits SizeLimits
+
+	// RetrievalTimeout limits the time spent retrieving an object - if not
+	// set, DefaultRetrievalTimeout is used
+	RetrievalTimeout time.Duration
+
+	// StreamThreshold is the size above which text objects are streamed into
+	// analyzers that support it, rather than buffered. Format-specific
+	// analyzers, such as Markdown, can't stream content, so streamed text is
+	// indexed as plain text. If not set, DefaultStreamThreshold is used.
+	StreamThreshold int64
+
+	// Retrieval configures retries for failed requests to IPFS - if not set,
+	// planetary.DefaultOptions is used
+	Retrieval *planetary.Options
+
+	// Sources registers additional sources that content can be indexed from by
+	// URI scheme, ie filesystem.Scheme. IPFS is always available, and is used
+	// for content hashes provided without a scheme.
+	Sources map[string]source.Source
+
+	// Cache stores OCR and image classification results so that the same
+	// content is not analyzed repeatedly - leave nil to disable
+	Cache *cache.Cache
+
+	// Archive sets limits on the zip and tar archives that are indexed - if not
+	// set, archive.DefaultOptions is used
+	Archive *archive.Options
+
+	// Analyzers registers additional analyzers, which are tried before the
+	// built-in analyzers if they have the same or a higher priority. They are
+	// not cached unless wrapped with analyzer.Cached.
+	Analyzers []analyzer.Registration
+
 	Engine engine.Opts
 }
 
+// DefaultRetrievalTimeout is the default time limit for retrieving an object
+const DefaultRetrievalTimeout = time.Minute
+
+// DefaultStreamThreshold is the default size, in bytes, above which text
+// objects are streamed into analyzers
+const DefaultStreamThreshold int64 = 4 << 20
+
+// DefaultMaxSize is the default maximum size, in bytes, of objects that can be
+// indexed
+const DefaultMaxSize int64 = 32 << 20
+
+// SizeLimits denotes the maximum sizes, in bytes, of objects that can be
+// indexed. Objects are checked against the limit for their category before
+// they are retrieved.
+type SizeLimits struct {
+	// Default applies to categories without a specific limit - if not set,
+	// DefaultMaxSize is used
+	Default int64
+
+	// Categories sets limits for specific categories, ie models.MimeTypeImage
+	Categories map[string]int64
+}
+
+// For returns the size limit for the given category
+func (s SizeLimits) For(category string) int64 {
+	if limit, ok := s.Categories[category]; ok && limit > 0 {
+		return limit
+	}
+	if s.Default > 0 {
+		return s.Default
+	}
+	return DefaultMaxSize
+}
+
 // NewV2 instantiates a new V2 API
 func NewV2(
 	opts V2Options,
@@ -56,15 +147,7 @@ func NewV2(
 	}
 	go se.Run()
 
-	return &V2{
-		se:   se,
-		ipfs: ipfs,
-
-		tf: ia,
-		px: planetary.NewPlanetaryExtractor(ipfs),
-		oc: ocr.NewAnalyzer(opts.TesseractConfigPath, logger.Named("ocr")),
-		l:  logger.Named("service.v2"),
-	}, nil
+	return NewV2WithEngine(opts, ipfs, ia, se, logger), nil
 }
 
 // NewV2WithEngine instantiates a Lens V2 service with the given engine
@@ -79,19 +162,51 @@ func NewV2WithEngine(
 		logger = zap.NewNop().Sugar()
 	}
 
-	return &V2{
-		se:   se,
-		ipfs: ipfs,
+	if opts.RetrievalTimeout <= 0 {
+		opts.RetrievalTimeout = DefaultRetrievalTimeout
+	}
+	if opts.StreamThreshold <= 0 {
+		opts.StreamThreshold = DefaultStreamThreshold
+	}
+	if opts.Retrieval == nil {
+		opts.Retrieval = &planetary.DefaultOptions
+	}
+
+	var v = &V2{
+		se:      se,
+		ipfs:    ipfs,
+		sources: source.NewRegistry(),
+
+		px: planetary.NewPlanetaryExtractor(ipfs, *opts.Retrieval),
+		analyzers: newAnalyzers(opts, ia,
+			ocr.NewAnalyzer(opts.TesseractConfigPath, logger.Named("ocr")),
+			logger.Named("analyzer")),
 
-		tf: ia,
-		px: planetary.NewPlanetaryExtractor(ipfs),
-		oc: ocr.NewAnalyzer(opts.TesseractConfigPath, logger.Named("ocr")),
-		l:  logger.Named("service.v2"),
+		limits:  opts.SizeLimits,
+		timeout: opts.RetrievalTimeout,
+		stream:  opts.StreamThreshold,
+
+		names: newNameTracker(),
+		stop:  make(chan bool),
+
+		l: logger.Named("service.v2"),
+	}
+	for scheme, src := range opts.Sources {
+		v.sources.Register(scheme, src)
 	}
+	v.sources.Register(planetary.Scheme, v.px)
+	v.loadNames(context.Background())
+	if opts.ResolveInterval > 0 {
+		go v.watchNames(opts.ResolveInterval)
+	}
+	return v
 }
 
 // Close releases Lens resources
-func (v *V2) Close() { v.se.Close() }
+func (v *V2) Close() {
+	close(v.stop)
+	v.se.Close()
+}
 
 // Index analyzes and stores the given object
 func (v *V2) Index(ctx context.Context, req *lensv2.IndexReq) (*lensv2.IndexResp, error) {
@@ -104,16 +219,69 @@ func (v *V2) Index(ctx context.Context, req *lensv2.IndexReq) (*lensv2.IndexResp
 			"invalid data type '%s' provided", req.GetType())
 	}
 
-	var hash = req.GetHash()
-	var reindex = req.GetOptions().GetReindex()
-	content, md, err := v.magnify(hash, magnifyOpts{
-		DisplayName: req.GetDisplayName(),
-		Tags:        req.GetTags(),
-		Reindex:     reindex,
-	})
+	var (
+		hash    = req.GetHash()
+		reindex = req.GetOptions().GetReindex()
+		opts    = magnifyOpts{
+			DisplayName: req.GetDisplayName(),
+			Tags:        req.GetTags(),
+			Reindex:     reindex,
+		}
+		src     source.Source
+		content string
+		md      *models.MetaDataV2
+		err     error
+	)
+	if scheme, _ := source.Parse(hash); scheme != "" && scheme != planetary.Scheme {
+		// content from other sources is keyed by its URI
+		if src, err = v.sources.Get(hash); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid uri '%s': %s", hash, err.Error())
+		}
+	} else {
+		src = v.px
+		hash = strings.TrimPrefix(hash, planetary.Scheme+"://")
+		if isName(hash) {
+			opts.Name = hash
+			id, err := v.resolve(ctx, opts.Name)
+			if err != nil {
+				l.Errorw("failed to resolve name", "error", err)
+				return nil, nameError(opts.Name, err)
+			}
+			hash = id.String()
+
+			// content already indexed directly or by another name is shared
+			// rather than reindexed, so only the name needs to be tracked
+			if doc, indexed := v.document(ctx, hash); indexed && doc.MD.IPNS != opts.Name {
+				v.move(ctx, opts.Name, hash, opts)
+				l.Info("name refers to indexed content - name tracked")
+				return &lensv2.IndexResp{
+					Doc: &lensv2.Document{
+						Hash:        hash,
+						DisplayName: doc.MD.DisplayName,
+						MimeType:    doc.MD.MimeType,
+						Category:    doc.MD.Category,
+						Tags:        doc.MD.Tags,
+					},
+				}, nil
+			}
+		} else if _, err = planetary.DecodeStringToCID(hash); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"invalid content hash '%s': %s", hash, err.Error())
+		}
+	}
+
+	content, md, err = v.magnify(ctx, src, hash, opts)
 	if err != nil {
 		l.Errorw("failed to magnify document", "error", err)
-		if strings.Contains(err.Error(), "failed to find content") {
+		switch {
+		case err == context.DeadlineExceeded:
+			return nil, status.Errorf(codes.DeadlineExceeded,
+				"timed out indexing content for '%s'", hash)
+		case err == context.Canceled:
+			return nil, status.Errorf(codes.Canceled,
+				"request cancelled while indexing content for '%s'", hash)
+		case strings.Contains(err.Error(), "failed to find content"):
 			return nil, status.Error(codes.NotFound, err.Error())
 		}
 		return nil, status.Errorf(codes.FailedPrecondition,
@@ -126,6 +294,10 @@ func (v *V2) Index(ctx context.Context, req *lensv2.IndexReq) (*lensv2.IndexResp
 			"failed to store requested document: %s", err.Error())
 	}
 
+	if opts.Name != "" {
+		v.move(ctx, opts.Name, hash, opts)
+	}
+
 	l.Info("document indexed")
 
 	return &lensv2.IndexResp{
@@ -156,6 +328,12 @@ func (v *V2) Search(ctx context.Context, req *lensv2.SearchReq) (*lensv2.SearchR
 		return nil, status.Errorf(codes.InvalidArgument,
 			"no search parameters provided")
 	}
+	var hashes = make([]string, len(opts.GetHashes()))
+	for i, h := range opts.GetHashes() {
+		if hashes[i], err = v.key(ctx, h); err != nil {
+			return nil, err
+		}
+	}
 
 	if opts == nil {
 		results, err = v.se.Search(ctx, engine.Query{Text: req.GetQuery()})
@@ -166,7 +344,7 @@ func (v *V2) Search(ctx context.Context, req *lensv2.SearchReq) (*lensv2.SearchR
 			Tags:       opts.GetTags(),
 			Categories: opts.GetCategories(),
 			MimeTypes:  opts.GetMimeTypes(),
-			Hashes:     opts.GetHashes(),
+			Hashes:     hashes,
 		})
 	}
 	if err != nil {
@@ -178,6 +356,15 @@ func (v *V2) Search(ctx context.Context, req *lensv2.SearchReq) (*lensv2.SearchR
 
 	v.l.Debugw("query completed",
 		"query", req, "results", len(results))
+
+	// the API has no field for matched sections, so they are reported in the
+	// response header instead
+	if md := matchedSections(results); md.Len() > 0 {
+		if err := grpc.SetHeader(ctx, md); err != nil {
+			v.l.Debugw("failed to report matched sections", "error", err)
+		}
+	}
+
 	return &lensv2.SearchResp{
 		Results: func() []*lensv2.SearchResp_Result {
 			var formatted = make([]*lensv2.SearchResp_Result, len(results))
@@ -199,17 +386,72 @@ func (v *V2) Search(ctx context.Context, req *lensv2.SearchReq) (*lensv2.SearchR
 	}, nil
 }
 
+// MetadataMatchedSections is the response header key under which Search reports
+// the sections of each result that matched the query. Each value is a URL
+// encoded query string with a "hash" and one or more "section" parameters.
+const MetadataMatchedSections = "lens-matched-sections"
+
+// matchedSections formats the sections that matched in each result as
+// response metadata
+func matchedSections(results []engine.Result) metadata.MD {
+	var md = metadata.MD{}
+	for _, r := range results {
+		if len(r.Sections) == 0 {
+			continue
+		}
+		md.Append(MetadataMatchedSections, url.Values{
+			"hash":    []string{r.Hash},
+			"section": r.Sections,
+		}.Encode())
+	}
+	return md
+}
+
 // Remove unindexes and deletes the requested object
 func (v *V2) Remove(ctx context.Context, req *lensv2.RemoveReq) (*lensv2.RemoveResp, error) {
-	if req.GetHash() == "" {
+	var hash = req.GetHash()
+	if hash == "" {
 		return nil, status.Errorf(codes.InvalidArgument,
 			"no hash to remove was provided")
 	}
+	key, err := v.key(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if isName(hash) {
+		v.names.untrack(hash)
+	}
 
-	if err := v.remove(req.GetHash()); err != nil {
+	if err := v.remove(key); err != nil {
 		return nil, status.Errorf(codes.NotFound,
 			"failed to remove requested hash: %s", err.Error())
 	}
 
 	return &lensv2.RemoveResp{}, nil
 }
+
+// key validates the given content hash, IPNS name, or URI, and returns the key
+// of the document it refers to
+func (v *V2) key(ctx context.Context, hash string) (string, error) {
+	if scheme, _ := source.Parse(hash); scheme != "" && scheme != planetary.Scheme {
+		if _, err := v.sources.Get(hash); err != nil {
+			return "", status.Errorf(codes.InvalidArgument,
+				"invalid uri '%s': %s", hash, err.Error())
+		}
+		return hash, nil
+	}
+
+	hash = strings.TrimPrefix(hash, planetary.Scheme+"://")
+	if isName(hash) {
+		key, err := v.lookup(ctx, hash)
+		if err != nil {
+			return "", nameError(hash, err)
+		}
+		return key, nil
+	}
+	if _, err := planetary.DecodeStringToCID(hash); err != nil {
+		return "", status.Errorf(codes.InvalidArgument,
+			"invalid content hash '%s': %s", hash, err.Error())
+	}
+	return hash, nil
+}
@@ -0,0 +1,180 @@
+package lens
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/archive"
+	"github.com/RTradeLtd/Lens/v2/analyzer/code"
+	"github.com/RTradeLtd/Lens/v2/analyzer/epub"
+	"github.com/RTradeLtd/Lens/v2/analyzer/html"
+	"github.com/RTradeLtd/Lens/v2/analyzer/images"
+	"github.com/RTradeLtd/Lens/v2/analyzer/markdown"
+	"github.com/RTradeLtd/Lens/v2/analyzer/media"
+	"github.com/RTradeLtd/Lens/v2/analyzer/ocr"
+	"github.com/RTradeLtd/Lens/v2/analyzer/office"
+	"github.com/RTradeLtd/Lens/v2/analyzer/structured"
+	"github.com/RTradeLtd/Lens/v2/analyzer/subtitles"
+	"github.com/RTradeLtd/Lens/v2/analyzer/text"
+	"github.com/RTradeLtd/Lens/v2/models"
+)
+
+// newAnalyzers sets up the registry of built-in analyzers, along with any
+// additional analyzers provided in opts
+func newAnalyzers(
+	opts V2Options,
+	tf images.TensorflowAnalyzer,
+	oc *ocr.Analyzer,
+	logger *zap.SugaredLogger,
+) *analyzer.Registry {
+	// expensive analyses are cached if a cache is available
+	var cached = func(reg analyzer.Registration) analyzer.Registration {
+		if opts.Cache == nil {
+			return reg
+		}
+		return analyzer.Cached(opts.Cache, reg)
+	}
+
+	// additional analyzers are registered first, so that they are tried before
+	// built-in analyzers with the same priority
+	var r = analyzer.NewRegistry(opts.Analyzers...)
+	for _, reg := range []analyzer.Registration{
+		{
+			Name:     "text",
+			Version:  "1",
+			Patterns: []string{"text/*"},
+			Category: models.MimeTypeDocument,
+			Analyzer: text.NewAnalyzer(),
+		},
+		{
+			Name:     "html",
+			Version:  "1",
+			Patterns: []string{"text/html"},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: html.NewAnalyzer(),
+		},
+		{
+			Name:     "subtitles",
+			Version:  "1",
+			Patterns: []string{"text/*", "application/x-subrip"},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: subtitles.NewAnalyzer(),
+		},
+		// markdown is tried before code, since documents often include snippets
+		// of code
+		{
+			Name:     "markdown",
+			Version:  "1",
+			Patterns: []string{"text/*"},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: markdown.NewAnalyzer(),
+		},
+		{
+			Name:     "code",
+			Version:  "1",
+			Patterns: []string{"text/*", "application/javascript", "application/x-sh"},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: code.NewAnalyzer(),
+		},
+		{
+			Name:    "structured",
+			Version: "1",
+			Patterns: []string{
+				"text/plain",
+				"text/csv",
+				"text/tab-separated-values",
+				"text/yaml",
+				"text/x-yaml",
+				"application/json",
+				"application/x-ndjson",
+				"application/jsonlines",
+				"application/x-yaml",
+			},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: structured.NewAnalyzer(),
+		},
+		{
+			Name:     "office",
+			Version:  "1",
+			Patterns: []string{"application/zip"},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: office.NewAnalyzer(),
+		},
+		{
+			Name:     "epub",
+			Version:  "1",
+			Patterns: []string{"application/zip", epub.MimeType},
+			Priority: 1,
+			Category: models.MimeTypeDocument,
+			Analyzer: epub.NewAnalyzer(),
+		},
+		{
+			Name:     "audio",
+			Version:  "1",
+			Patterns: []string{"audio/*", "application/ogg"},
+			Category: models.MimeTypeAudio,
+			Analyzer: media.NewAnalyzer(),
+		},
+		{
+			Name:     "video",
+			Version:  "1",
+			Patterns: []string{"video/*"},
+			Category: models.MimeTypeVideo,
+			Analyzer: media.NewAnalyzer(),
+		},
+		cached(analyzer.Registration{
+			Name:     "pdf",
+			Version:  oc.Version(),
+			Patterns: []string{"application/pdf"},
+			Category: models.MimeTypePDF,
+			Analyzer: oc.PDF(),
+		}),
+		cached(analyzer.Registration{
+			Name: "image",
+			// the leading revision invalidates results without embedded metadata
+			// or without both labels and tags
+			Version:  "4+" + tf.Version() + "+" + oc.Version(),
+			Patterns: []string{"image/*"},
+			Category: models.MimeTypeImage,
+			Analyzer: images.NewImageAnalyzer(tf, oc.Image(), logger.Named("image")),
+		}),
+	} {
+		r.Register(reg)
+	}
+
+	// archive members are analyzed with the rest of the registry, and archives
+	// are only tried once other analyzers of zip containers have declined them
+	var archiveOpts = archive.DefaultOptions
+	if opts.Archive != nil {
+		archiveOpts = *opts.Archive
+	}
+	r.Register(analyzer.Registration{
+		Name:    "archive",
+		Version: "1",
+		Patterns: []string{
+			"application/zip",
+			"application/x-gzip",
+			"application/x-tar",
+			"application/octet-stream",
+		},
+		Category: models.MimeTypeArchive,
+		Analyzer: archive.NewAnalyzer(r, archiveOpts, logger.Named("archive")),
+	})
+
+	// some media formats, such as FLAC and QuickTime, are not recognized by
+	// content type detection, so they are tried after archives
+	r.Register(analyzer.Registration{
+		Name:     "media",
+		Version:  "1",
+		Patterns: []string{"application/octet-stream"},
+		Category: models.MimeTypeAudio,
+		Analyzer: media.NewAnalyzer(),
+	})
+	return r
+}
@@ -0,0 +1,263 @@
+package lens
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	gocid "github.com/ipfs/go-cid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/RTradeLtd/Lens/v2/engine"
+	"github.com/RTradeLtd/Lens/v2/source/planetary"
+)
+
+// ipnsPrefix denotes an IPNS path, which can refer to either an IPNS key or a
+// DNSLink domain
+const ipnsPrefix = "/ipns/"
+
+// isName checks if the given hash is an IPNS path
+func isName(hash string) bool { return strings.HasPrefix(hash, ipnsPrefix) }
+
+// resolve retrieves the CID the given IPNS path currently points to, within
+// the retrieval timeout. Context errors are returned as-is.
+func (v *V2) resolve(ctx context.Context, name string) (gocid.Cid, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+	path, err := v.px.Resolve(ctx, name)
+	if err != nil {
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return gocid.Cid{}, err
+		}
+		return gocid.Cid{}, fmt.Errorf("failed to resolve name '%s': %s", name, err.Error())
+	}
+	var hash = strings.TrimPrefix(path, "/ipfs/")
+	if strings.Contains(hash, "/") {
+		return gocid.Cid{}, fmt.Errorf("name '%s' resolves to path '%s', which is not a plain CID",
+			name, path)
+	}
+	id, err := planetary.DecodeStringToCID(hash)
+	if err != nil {
+		return gocid.Cid{}, fmt.Errorf("name '%s' resolves to invalid hash '%s': %s",
+			name, hash, err.Error())
+	}
+	return id, nil
+}
+
+// nameError converts a failure to resolve the given IPNS name into a gRPC
+// status error
+func nameError(name string, err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return status.Errorf(codes.DeadlineExceeded,
+			"timed out resolving name '%s'", name)
+	case context.Canceled:
+		return status.Errorf(codes.Canceled,
+			"request cancelled while resolving name '%s'", name)
+	}
+	return status.Error(codes.NotFound, err.Error())
+}
+
+// lookup returns the indexed hash of the given IPNS path, falling back to
+// resolving the name if it is not tracked
+func (v *V2) lookup(ctx context.Context, name string) (string, error) {
+	if tracked, ok := v.names.get(name); ok {
+		return tracked.hash, nil
+	}
+	id, err := v.resolve(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// loadNames tracks the IPNS names of documents in the index, so that names
+// indexed before the service was started are still re-resolved. Their display
+// names and tags are reused when they are reindexed.
+func (v *V2) loadNames(ctx context.Context) {
+	var loaded int
+	for offset := 0; ; offset += engine.MaxResults {
+		results, err := v.se.Search(ctx, engine.Query{Named: true, Offset: offset})
+		if err != nil {
+			// searches without results fail, so this is expected once all
+			// names are loaded
+			v.l.Debugw("no more indexed names found", "error", err)
+			break
+		}
+		for _, r := range results {
+			if !isName(r.MD.IPNS) {
+				continue
+			}
+			v.names.track(r.MD.IPNS, r.Hash, magnifyOpts{
+				DisplayName: r.MD.DisplayName,
+				Name:        r.MD.IPNS,
+				Tags:        r.MD.Tags,
+			})
+			loaded++
+		}
+		if len(results) < engine.MaxResults {
+			break
+		}
+	}
+	v.l.Infow("indexed names loaded", "names", loaded)
+}
+
+// watchNames periodically re-resolves indexed IPNS names until the service is
+// closed
+func (v *V2) watchNames(interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-v.stop
+		cancel()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			v.refreshNames(ctx)
+		}
+	}
+}
+
+// refreshNames re-resolves all indexed IPNS names. Names that have moved to a
+// new CID are reindexed, and the documents of their stale CIDs are removed if
+// nothing else refers to them.
+func (v *V2) refreshNames(ctx context.Context) {
+	for name, tracked := range v.names.all() {
+		if ctx.Err() != nil {
+			return
+		}
+		var l = v.l.With("name", name, "hash", tracked.hash)
+		id, err := v.resolve(ctx, name)
+		if err != nil {
+			l.Warnw("failed to re-resolve name", "error", err)
+			continue
+		}
+		var hash = id.String()
+		if engine.Key(hash) == engine.Key(tracked.hash) {
+			continue
+		}
+
+		// index new content, unless it is already indexed directly or by
+		// another name, in which case it is shared
+		if owner, indexed := v.owner(ctx, hash); !indexed || owner == name {
+			var opts = tracked.opts
+			opts.Reindex = true
+			content, md, err := v.magnify(ctx, v.px, hash, opts)
+			if err != nil {
+				l.Warnw("failed to magnify new document for name",
+					"error", err, "hash.new", hash)
+				continue
+			}
+			if err = v.store(hash, content, md, true); err != nil {
+				l.Warnw("failed to store new document for name",
+					"error", err, "hash.new", hash)
+				continue
+			}
+		}
+		v.move(ctx, name, hash, tracked.opts)
+
+		l.Infow("name moved to new content - document reindexed",
+			"hash.new", hash)
+	}
+}
+
+// move tracks the given name as referring to the given hash. If the name
+// previously referred to other content, the stale document is removed unless
+// something else still refers to it.
+func (v *V2) move(ctx context.Context, name, hash string, opts magnifyOpts) {
+	prev, tracked := v.names.get(name)
+	v.names.track(name, hash, opts)
+	if !tracked || engine.Key(prev.hash) == engine.Key(hash) {
+		return
+	}
+	var l = v.l.With("name", name, "hash", prev.hash)
+	if v.referenced(ctx, prev.hash, name) {
+		l.Infow("stale document is still referenced - keeping it")
+	} else if err := v.remove(prev.hash); err != nil {
+		l.Warnw("failed to remove stale document for name", "error", err)
+	}
+}
+
+// document returns the indexed document with the given hash, if there is one
+func (v *V2) document(ctx context.Context, hash string) (engine.Result, bool) {
+	results, err := v.se.Search(ctx, engine.Query{Hashes: []string{hash}})
+	if err != nil || len(results) == 0 {
+		return engine.Result{}, false
+	}
+	return results[0], true
+}
+
+// owner returns the IPNS name the document with the given hash was indexed
+// from, which is empty if it was indexed directly, and whether it is indexed
+func (v *V2) owner(ctx context.Context, hash string) (name string, indexed bool) {
+	doc, indexed := v.document(ctx, hash)
+	return doc.MD.IPNS, indexed
+}
+
+// referenced checks if the document with the given hash is referred to by
+// anything other than the given name - either another tracked name, or an
+// index request for the hash itself
+func (v *V2) referenced(ctx context.Context, hash, name string) bool {
+	for other, tracked := range v.names.all() {
+		if other != name && engine.Key(tracked.hash) == engine.Key(hash) {
+			return true
+		}
+	}
+	owner, indexed := v.owner(ctx, hash)
+	return indexed && owner == ""
+}
+
+// trackedName denotes the currently indexed content of an IPNS name
+type trackedName struct {
+	hash string
+	opts magnifyOpts
+}
+
+// nameTracker keeps track of indexed IPNS names for the lifetime of the
+// service
+type nameTracker struct {
+	names map[string]trackedName
+	mux   sync.RWMutex
+}
+
+func newNameTracker() *nameTracker {
+	return &nameTracker{names: make(map[string]trackedName)}
+}
+
+func (n *nameTracker) track(name, hash string, opts magnifyOpts) {
+	n.mux.Lock()
+	n.names[name] = trackedName{hash, opts}
+	n.mux.Unlock()
+}
+
+func (n *nameTracker) untrack(name string) {
+	n.mux.Lock()
+	delete(n.names, name)
+	n.mux.Unlock()
+}
+
+func (n *nameTracker) get(name string) (trackedName, bool) {
+	n.mux.RLock()
+	tracked, ok := n.names[name]
+	n.mux.RUnlock()
+	return tracked, ok
+}
+
+// all returns a snapshot of all tracked names
+func (n *nameTracker) all() map[string]trackedName {
+	n.mux.RLock()
+	var names = make(map[string]trackedName, len(n.names))
+	for k, v := range n.names {
+		names[k] = v
+	}
+	n.mux.RUnlock()
+	return names
+}
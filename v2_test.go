@@ -1,20 +1,39 @@
 package lens
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"google.golang.org/grpc/status"
 
+	"github.com/RTradeLtd/Lens/v2/analyzer"
+	"github.com/RTradeLtd/Lens/v2/analyzer/cache"
+	"github.com/RTradeLtd/Lens/v2/analyzer/images"
 	"github.com/RTradeLtd/Lens/v2/engine"
 	"github.com/RTradeLtd/Lens/v2/mocks"
 	"github.com/RTradeLtd/Lens/v2/models"
+	"github.com/RTradeLtd/Lens/v2/source"
+	"github.com/RTradeLtd/Lens/v2/source/filesystem"
+	shell "github.com/RTradeLtd/go-ipfs-api"
 	"github.com/RTradeLtd/grpc/lensv2"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 )
 
+const (
+	testHash     = "QmSi9TLyzTXmrLMXDvhztDoX3jghoG3vcRrnPkLvGgfpdW"
+	testHashCBOR = "bafyreiebfojbltjpbz7kihzsvbawqa66fph25ulmioxficx4mwrbri62by"
+)
+
 func TestNewV2(t *testing.T) {
 	var ipfs = &mocks.FakeRTFSManager{}
 	var ia = &mocks.FakeTensorflowAnalyzer{}
@@ -63,18 +82,26 @@ func TestV2_Index(t *testing.T) {
 			returns{"", false, false, false},
 			"",
 			codes.InvalidArgument},
-		{"no content for hash found",
+		{"invalid hash",
 			args{&lensv2.IndexReq{
 				Type: lensv2.IndexReq_IPLD,
 				Hash: "asdf",
 			}},
+			returns{"README.md", false, false, false},
+			"",
+			codes.InvalidArgument},
+		{"no content for hash found",
+			args{&lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			}},
 			returns{"", false, false, false},
 			"",
 			codes.NotFound},
 		{"already indexed",
 			args{&lensv2.IndexReq{
 				Type: lensv2.IndexReq_IPLD,
-				Hash: "asdf",
+				Hash: testHash,
 			}},
 			returns{"README.md", false, false, true},
 			"",
@@ -82,7 +109,7 @@ func TestV2_Index(t *testing.T) {
 		{"tensor failure",
 			args{&lensv2.IndexReq{
 				Type: lensv2.IndexReq_IPLD,
-				Hash: "asdf",
+				Hash: testHash,
 			}},
 			returns{"test/assets/image.jpg", true, false, false},
 			"",
@@ -90,7 +117,7 @@ func TestV2_Index(t *testing.T) {
 		{"ok: image",
 			args{&lensv2.IndexReq{
 				Type: lensv2.IndexReq_IPLD,
-				Hash: "asdf",
+				Hash: testHash,
 			}},
 			returns{"test/assets/image.jpg", false, false, false},
 			models.MimeTypeImage,
@@ -98,7 +125,7 @@ func TestV2_Index(t *testing.T) {
 		{"ok: pdf",
 			args{&lensv2.IndexReq{
 				Type: lensv2.IndexReq_IPLD,
-				Hash: "asdf",
+				Hash: testHash,
 			}},
 			returns{"test/assets/text.pdf", false, false, false},
 			models.MimeTypePDF,
@@ -106,11 +133,78 @@ func TestV2_Index(t *testing.T) {
 		{"ok: document",
 			args{&lensv2.IndexReq{
 				Type: lensv2.IndexReq_IPLD,
-				Hash: "asdf",
+				Hash: testHash,
 			}},
 			returns{"README.md", false, false, false},
 			models.MimeTypeDocument,
 			codes.OK},
+		{"ok: office document",
+			args{&lensv2.IndexReq{
+				Type:        lensv2.IndexReq_IPLD,
+				Hash:        testHash,
+				DisplayName: "my document",
+			}},
+			returns{"test/assets/document.docx", false, false, false},
+			models.MimeTypeDocument,
+			codes.OK},
+		{"ok: e-book",
+			args{&lensv2.IndexReq{
+				Type:        lensv2.IndexReq_IPLD,
+				Hash:        testHash,
+				DisplayName: "my book",
+			}},
+			returns{"test/assets/book.epub", false, false, false},
+			models.MimeTypeDocument,
+			codes.OK},
+		{"ok: source code",
+			args{&lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			}},
+			returns{"v2_analyzers.go", false, false, false},
+			models.MimeTypeDocument,
+			codes.OK},
+		{"ok: dataset",
+			args{&lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			}},
+			returns{"test/assets/data.csv", false, false, false},
+			models.MimeTypeDocument,
+			codes.OK},
+		{"ok: archive",
+			args{&lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			}},
+			returns{"test/assets/bundle.tar.gz", false, false, false},
+			models.MimeTypeArchive,
+			codes.OK},
+		{"ok: audio",
+			args{&lensv2.IndexReq{
+				Type:        lensv2.IndexReq_IPLD,
+				Hash:        testHash,
+				DisplayName: "my song",
+			}},
+			returns{"test/assets/song.flac", false, false, false},
+			models.MimeTypeAudio,
+			codes.OK},
+		{"no content for object found",
+			args{&lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHashCBOR,
+			}},
+			returns{"", false, false, false},
+			"",
+			codes.NotFound},
+		{"ok: dag-cbor object",
+			args{&lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHashCBOR,
+			}},
+			returns{"test/assets/object.json", false, false, false},
+			models.MimeTypeIPLD,
+			codes.OK},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -124,11 +218,11 @@ func TestV2_Index(t *testing.T) {
 				zap.NewNop().Sugar())
 
 			// set up mocks
-			ipfs.CatStub = mocks.StubIpfsCat(tt.returns.catAssetPath)
+			ipfs.CustomRequestStub = mocks.StubIpfsRequest(tt.returns.catAssetPath)
 			if tt.returns.tensorErr {
-				tensor.AnalyzeReturns("", errors.New("oh no"))
+				tensor.AnalyzeReturns(nil, errors.New("oh no"))
 			} else {
-				tensor.AnalyzeReturns("test", nil)
+				tensor.AnalyzeReturns([]images.Label{{Name: "test", Confidence: 0.9}}, nil)
 			}
 			if tt.returns.indexErr {
 				se.IndexReturns(errors.New("oh no"))
@@ -170,6 +264,911 @@ func TestV2_Index(t *testing.T) {
 	}
 }
 
+func TestSizeLimits_For(t *testing.T) {
+	var limits = SizeLimits{
+		Default:    100,
+		Categories: map[string]int64{string(models.MimeTypeImage): 10},
+	}
+	if got := limits.For(string(models.MimeTypeImage)); got != 10 {
+		t.Errorf("SizeLimits.For(image) = %d, want %d", got, 10)
+	}
+	if got := limits.For(string(models.MimeTypePDF)); got != 100 {
+		t.Errorf("SizeLimits.For(pdf) = %d, want %d", got, 100)
+	}
+	if got := (SizeLimits{}).For(string(models.MimeTypePDF)); got != DefaultMaxSize {
+		t.Errorf("SizeLimits.For(pdf) = %d, want %d", got, DefaultMaxSize)
+	}
+}
+
+func TestV2_Index_sizeLimits(t *testing.T) {
+	type args struct {
+		assetPath string
+		statPath  string
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantErrCode codes.Code
+	}{
+		{"image too large",
+			args{"test/assets/image.jpg", "test/assets/image.jpg"},
+			codes.FailedPrecondition},
+		{"document too large",
+			args{"README.md", "README.md"},
+			codes.FailedPrecondition},
+		{"document larger than reported",
+			args{"README.md", "test/config.json"},
+			codes.FailedPrecondition},
+		{"ok: document within limits",
+			args{"test/config.json", "test/config.json"},
+			codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var tensor = &mocks.FakeTensorflowAnalyzer{}
+			var v = NewV2WithEngine(V2Options{
+				SizeLimits: SizeLimits{
+					Default: 4 << 10,
+					Categories: map[string]int64{
+						string(models.MimeTypeImage): 1 << 10,
+					},
+				},
+			}, ipfs, tensor, &mocks.FakeSearcher{}, zap.NewNop().Sugar())
+
+			// set up mocks
+			var cat = mocks.StubIpfsRequest(tt.args.assetPath)
+			var stat = mocks.StubIpfsRequest(tt.args.statPath)
+			ipfs.CustomRequestStub = func(ctx context.Context, url, command string,
+				opts map[string]string, args ...string) (*shell.Response, error) {
+				if command == "object/stat" {
+					return stat(ctx, url, command, opts, args...)
+				}
+				return cat(ctx, url, command, opts, args...)
+			}
+
+			// execute tests
+			_, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			})
+			if s := status.Convert(err); s.Code() != tt.wantErrCode {
+				t.Errorf("V2.Index() err code = %s, want %s (error = %v)",
+					s.Code().String(), tt.wantErrCode.String(), err)
+			}
+			if tensor.AnalyzeCallCount() > 0 {
+				t.Error("wanted oversized image to not be analyzed")
+			}
+		})
+	}
+}
+
+func TestV2_Index_sharedSizeLimits(t *testing.T) {
+	// tarballs and FLAC are both detected as application/octet-stream, which
+	// archives and media share
+	var tarball bytes.Buffer
+	var tw = tar.NewWriter(&tarball)
+	var text = []byte("hello world")
+	if err := tw.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: int64(len(text))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(text)
+	tw.Close()
+	f, err := ioutil.TempFile("", "lens_tarball")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(tarball.Bytes())
+	f.Close()
+
+	tests := []struct {
+		name        string
+		assetPath   string
+		wantErrCode codes.Code
+	}{
+		{"ok: media within media limit", "test/assets/song.flac", codes.OK},
+		{"archive too large", f.Name(), codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var v = NewV2WithEngine(V2Options{
+				SizeLimits: SizeLimits{
+					Categories: map[string]int64{
+						string(models.MimeTypeArchive): 100,
+						string(models.MimeTypeAudio):   4 << 10,
+					},
+				},
+			}, ipfs, &mocks.FakeTensorflowAnalyzer{}, &mocks.FakeSearcher{}, zap.NewNop().Sugar())
+			ipfs.CustomRequestStub = mocks.StubIpfsRequest(tt.assetPath)
+
+			_, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			})
+			if s := status.Convert(err); s.Code() != tt.wantErrCode {
+				t.Errorf("V2.Index() err code = %s, want %s (error = %v)",
+					s.Code().String(), tt.wantErrCode.String(), err)
+			}
+		})
+	}
+}
+
+func TestV2_Index_stream(t *testing.T) {
+	readme, err := ioutil.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type args struct {
+		assetPath string
+		statPath  string
+		threshold int64
+	}
+	tests := []struct {
+		name        string
+		args        args
+		wantErrCode codes.Code
+		wantStream  bool
+	}{
+		{"ok: buffered",
+			args{"README.md", "README.md", 0}, codes.OK, false},
+		{"ok: streamed",
+			args{"README.md", "README.md", 64}, codes.OK, true},
+		{"streamed document larger than reported",
+			args{"README.md", "test/config.json", 64}, codes.FailedPrecondition, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var se = &mocks.FakeSearcher{}
+			var limit = int64(len(readme))
+			if tt.wantErrCode != codes.OK {
+				limit = 4 << 10
+			}
+			var v = NewV2WithEngine(V2Options{
+				SizeLimits:      SizeLimits{Default: limit},
+				StreamThreshold: tt.args.threshold,
+			}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+
+			var cat = mocks.StubIpfsRequest(tt.args.assetPath)
+			var stat = mocks.StubIpfsRequest(tt.args.statPath)
+			ipfs.CustomRequestStub = func(ctx context.Context, url, command string,
+				opts map[string]string, args ...string) (*shell.Response, error) {
+				if command == "object/stat" {
+					return stat(ctx, url, command, opts, args...)
+				}
+				return cat(ctx, url, command, opts, args...)
+			}
+
+			_, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			})
+			if s := status.Convert(err); s.Code() != tt.wantErrCode {
+				t.Fatalf("V2.Index() err code = %s, want %s (error = %v)",
+					s.Code().String(), tt.wantErrCode.String(), err)
+			}
+			if err != nil {
+				return
+			}
+
+			// streamed text is indexed as-is, rather than as Markdown
+			var stored = se.IndexArgsForCall(0)
+			if streamed := stored.Object.MD.MimeType != "text/markdown"; streamed != tt.wantStream {
+				t.Errorf("stored mime type %s, want streamed %v", stored.Object.MD.MimeType, tt.wantStream)
+			}
+			if tt.wantStream && stored.Content != string(readme) {
+				t.Errorf("stored content of length %d, want %d", len(stored.Content), len(readme))
+			}
+		})
+	}
+}
+
+func TestV2_Index_retrievalTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		cancel       bool
+		slowAnalysis bool
+		wantErrCode  codes.Code
+	}{
+		{"retrieval timed out", false, false, codes.DeadlineExceeded},
+		{"request cancelled", true, false, codes.Canceled},
+		{"analysis slower than retrieval timeout", false, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var v = NewV2WithEngine(V2Options{
+				RetrievalTimeout: 10 * time.Millisecond,
+				Analyzers: []analyzer.Registration{{
+					Name:     "slow",
+					Patterns: []string{"text/plain"},
+					Priority: 2,
+					Analyzer: analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+						time.Sleep(50 * time.Millisecond)
+						if err := ctx.Err(); err != nil {
+							return nil, err
+						}
+						return &analyzer.Result{Content: "slow content"}, nil
+					}),
+				}},
+			}, ipfs, &mocks.FakeTensorflowAnalyzer{}, &mocks.FakeSearcher{}, zap.NewNop().Sugar())
+
+			if tt.slowAnalysis {
+				ipfs.CustomRequestStub = mocks.StubIpfsRequest("README.md")
+			} else {
+				// block requests until context is done
+				ipfs.CustomRequestStub = func(ctx context.Context, url, command string,
+					opts map[string]string, args ...string) (*shell.Response, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+			}
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			} else {
+				defer cancel()
+			}
+			_, err := v.Index(ctx, &lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: testHash,
+			})
+			if s := status.Convert(err); s.Code() != tt.wantErrCode {
+				t.Errorf("V2.Index() err code = %s, want %s (error = %v)",
+					s.Code().String(), tt.wantErrCode.String(), err)
+			}
+		})
+	}
+}
+
+func TestV2_Index_sources(t *testing.T) {
+	fs, err := filesystem.New("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		uri         string
+		wantKey     string
+		wantType    models.MimeType
+		wantErrCode codes.Code
+	}{
+		{"unsupported scheme", "s3://bucket/config.json", "", "", codes.InvalidArgument},
+		{"file not found", "file://nope.json", "", "", codes.NotFound},
+		{"file outside of root", "file://../README.md", "", "", codes.NotFound},
+		{"ok: file", "file://config.json", "file://config.json", models.MimeTypeDocument, codes.OK},
+		{"ok: ipfs", "ipfs://" + testHash, testHash, models.MimeTypeDocument, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var se = &mocks.FakeSearcher{}
+			var v = NewV2WithEngine(V2Options{
+				Sources: map[string]source.Source{filesystem.Scheme: fs},
+			}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+			ipfs.CustomRequestStub = mocks.StubIpfsRequest("test/config.json")
+
+			got, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: tt.uri,
+			})
+			if s := status.Convert(err); s.Code() != tt.wantErrCode {
+				t.Errorf("V2.Index() err code = %s, want %s (error = %v)",
+					s.Code().String(), tt.wantErrCode.String(), err)
+				return
+			}
+			if tt.wantErrCode != codes.OK {
+				return
+			}
+			if got.GetDoc().GetHash() != tt.wantKey {
+				t.Errorf("got hash %s, want %s", got.GetDoc().GetHash(), tt.wantKey)
+			}
+			if got.GetDoc().GetCategory() != string(tt.wantType) {
+				t.Errorf("got category %s, want %s", got.GetDoc().GetCategory(), tt.wantType)
+			}
+			if stored := se.IndexArgsForCall(0); stored.Object.Hash != tt.wantKey {
+				t.Errorf("stored document with hash %s, want %s", stored.Object.Hash, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestV2_Index_cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lens_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := cache.New(cache.Options{Path: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ipfs = &mocks.FakeRTFSManager{}
+	var tensor = &mocks.FakeTensorflowAnalyzer{}
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest("test/assets/image.jpg")
+	tensor.AnalyzeReturns([]images.Label{{Name: "test", Confidence: 0.9}}, nil)
+
+	tests := []struct {
+		name      string
+		version   string
+		wantCalls int
+	}{
+		{"not cached", "v1", 1},
+		{"cached", "v1", 1},
+		{"new analyzer version", "v2", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tensor.VersionReturns(tt.version)
+			var se = &mocks.FakeSearcher{}
+			var v = NewV2WithEngine(V2Options{Cache: c},
+				ipfs, tensor, se, zap.NewNop().Sugar())
+			got, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type:    lensv2.IndexReq_IPLD,
+				Hash:    testHash,
+				Options: &lensv2.IndexReq_Options{Reindex: true},
+			})
+			if err != nil {
+				t.Fatalf("V2.Index() error = %v", err)
+			}
+			if tags := got.GetDoc().GetTags(); len(tags) != 1 || tags[0] != "test" {
+				t.Errorf("got tags %v, want %v", tags, []string{"test"})
+			}
+			var want = map[string]float64{"test": float64(float32(0.9))}
+			if labels := se.IndexArgsForCall(0).Object.MD.Labels; !reflect.DeepEqual(labels, want) {
+				t.Errorf("got labels %v, want %v", labels, want)
+			}
+			if calls := tensor.AnalyzeCallCount(); calls != tt.wantCalls {
+				t.Errorf("got %d analyzer calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestV2_Index_analyzers(t *testing.T) {
+	var ipfs = &mocks.FakeRTFSManager{}
+	var se = &mocks.FakeSearcher{}
+	var v = NewV2WithEngine(V2Options{
+		Analyzers: []analyzer.Registration{{
+			Name:     "custom",
+			Patterns: []string{"text/plain"},
+			Priority: 1,
+			Category: "custom",
+			Analyzer: analyzer.Func(func(ctx context.Context, in analyzer.Input) (*analyzer.Result, error) {
+				return &analyzer.Result{
+					Content:     "custom content",
+					DisplayName: "custom name",
+					Tags:        []string{"custom"},
+					Fields:      []string{"title"},
+					Attributes:  map[string]string{"author": "bob"},
+				}, nil
+			}),
+		}},
+	}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest("README.md")
+
+	got, err := v.Index(context.Background(), &lensv2.IndexReq{
+		Type: lensv2.IndexReq_IPLD,
+		Hash: testHash,
+		Tags: []string{"requested"},
+	})
+	if err != nil {
+		t.Fatalf("V2.Index() error = %v", err)
+	}
+	if got.GetDoc().GetCategory() != "custom" {
+		t.Errorf("got category %s, want %s", got.GetDoc().GetCategory(), "custom")
+	}
+	if got.GetDoc().GetDisplayName() != "custom name" {
+		t.Errorf("got display name %s, want %s", got.GetDoc().GetDisplayName(), "custom name")
+	}
+	if tags := got.GetDoc().GetTags(); !reflect.DeepEqual(tags, []string{"requested", "custom"}) {
+		t.Errorf("got tags %v, want %v", tags, []string{"requested", "custom"})
+	}
+	var stored = se.IndexArgsForCall(0)
+	if stored.Content != "custom content" {
+		t.Errorf("stored content %s, want %s", stored.Content, "custom content")
+	}
+	if stored.Object.MD.Attributes["author"] != "bob" {
+		t.Errorf("stored attributes %v, want author", stored.Object.MD.Attributes)
+	}
+	if !reflect.DeepEqual(stored.Object.MD.Fields, []string{"title"}) {
+		t.Errorf("stored fields %v, want %v", stored.Object.MD.Fields, []string{"title"})
+	}
+}
+
+func TestV2_Index_sections(t *testing.T) {
+	var ipfs = &mocks.FakeRTFSManager{}
+	var se = &mocks.FakeSearcher{}
+	var v = NewV2WithEngine(V2Options{}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest("test/assets/book.epub")
+
+	got, err := v.Index(context.Background(), &lensv2.IndexReq{
+		Type: lensv2.IndexReq_IPLD,
+		Hash: testHash,
+	})
+	if err != nil {
+		t.Fatalf("V2.Index() error = %v", err)
+	}
+	if got.GetDoc().GetDisplayName() != "The Distributed Web" {
+		t.Errorf("got display name %s, want %s", got.GetDoc().GetDisplayName(), "The Distributed Web")
+	}
+	var md = se.IndexArgsForCall(0).Object.MD
+	if md.MimeType != "application/epub+zip" {
+		t.Errorf("stored mime type %s, want %s", md.MimeType, "application/epub+zip")
+	}
+	if md.Attributes["author"] != "RTrade Technologies" || md.Attributes["language"] != "en" {
+		t.Errorf("stored attributes %v, want author and language", md.Attributes)
+	}
+	var titles []string
+	for _, s := range md.Sections {
+		titles = append(titles, s.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Introduction", "Storage"}) {
+		t.Errorf("stored sections %v, want Introduction and Storage", md.Sections)
+	}
+}
+
+func TestV2_Index_markdown(t *testing.T) {
+	var ipfs = &mocks.FakeRTFSManager{}
+	var se = &mocks.FakeSearcher{}
+	var v = NewV2WithEngine(V2Options{}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest("README.md")
+
+	if _, err := v.Index(context.Background(), &lensv2.IndexReq{
+		Type:        lensv2.IndexReq_IPLD,
+		Hash:        testHash,
+		DisplayName: "README.md",
+	}); err != nil {
+		t.Fatalf("V2.Index() error = %v", err)
+	}
+	var stored = se.IndexArgsForCall(0)
+	if stored.Object.MD.MimeType != "text/markdown" {
+		t.Errorf("stored mime type %s, want %s", stored.Object.MD.MimeType, "text/markdown")
+	}
+	if len(stored.Object.MD.Headings) == 0 || stored.Object.MD.Headings[0] != "🔍 Lens" {
+		t.Errorf("stored headings %v, want first heading %s", stored.Object.MD.Headings, "🔍 Lens")
+	}
+	if strings.Contains(stored.Content, "](") {
+		t.Errorf("stored content contains markdown links: %s", stored.Content)
+	}
+}
+
+func TestV2_Index_markdownOrCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		displayName  string
+		content      string
+		wantMarkdown bool
+		wantTags     []string
+	}{
+		{"readme with go snippet", "",
+			"# My Project\n\nTo get started:\n\n```go\npackage main\n\nfunc main() {}\n```\n",
+			true, []string{}},
+		{"readme with c snippet", "",
+			"# My Project\n\nInclude the header:\n\n```c\n#include <stdio.h>\n```\n",
+			true, []string{}},
+		{"readme with dotted display name", "notes v1.2",
+			"# My Project\n\nSee the [docs](https://example.com).\n",
+			true, []string{}},
+		{"python script with comment", "",
+			"# Copyright 2018 RTrade\nimport os\n\ndef main():\n    pass\n",
+			false, []string{"python"}},
+		{"shell script with comment", "",
+			"# build script\nset -e\ngo build ./...\n",
+			false, []string{"shell"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "lens_content")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			if _, err := f.WriteString(tt.content); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			var ipfs = &mocks.FakeRTFSManager{}
+			var se = &mocks.FakeSearcher{}
+			var v = NewV2WithEngine(V2Options{}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+			ipfs.CustomRequestStub = mocks.StubIpfsRequest(f.Name())
+
+			if _, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type:        lensv2.IndexReq_IPLD,
+				Hash:        testHash,
+				DisplayName: tt.displayName,
+			}); err != nil {
+				t.Fatalf("V2.Index() error = %v", err)
+			}
+			var stored = se.IndexArgsForCall(0)
+			if isMarkdown := stored.Object.MD.MimeType == "text/markdown"; isMarkdown != tt.wantMarkdown {
+				t.Errorf("stored mime type %s, want markdown %v", stored.Object.MD.MimeType, tt.wantMarkdown)
+			}
+			if tt.wantMarkdown && !reflect.DeepEqual(stored.Object.MD.Headings, []string{"My Project"}) {
+				t.Errorf("stored headings %v, want %v", stored.Object.MD.Headings, []string{"My Project"})
+			}
+			if !tt.wantMarkdown && len(stored.Object.MD.Headings) > 0 {
+				t.Errorf("stored headings %v, want none", stored.Object.MD.Headings)
+			}
+			if !reflect.DeepEqual(stored.Object.MD.Tags, tt.wantTags) {
+				t.Errorf("stored tags %v, want %v", stored.Object.MD.Tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestV2_Index_tiff(t *testing.T) {
+	// a big-endian TIFF with only the make of the camera
+	var tiff = []byte("MM\x00*\x00\x00\x00\x08" +
+		"\x00\x01" + "\x01\x0F\x00\x02\x00\x00\x00\x05\x00\x00\x00\x1A" + "\x00\x00\x00\x00" +
+		"Lens\x00")
+	f, err := ioutil.TempFile("", "lens_tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(tiff); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var ipfs = &mocks.FakeRTFSManager{}
+	var se = &mocks.FakeSearcher{}
+	var tensor = &mocks.FakeTensorflowAnalyzer{}
+	tensor.AnalyzeReturns(nil, errors.New("unsupported image format"))
+	var v = NewV2WithEngine(V2Options{}, ipfs, tensor, se, zap.NewNop().Sugar())
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest(f.Name())
+
+	if _, err := v.Index(context.Background(), &lensv2.IndexReq{
+		Type: lensv2.IndexReq_IPLD,
+		Hash: testHash,
+	}); err != nil {
+		t.Fatalf("V2.Index() error = %v", err)
+	}
+	var stored = se.IndexArgsForCall(0)
+	if stored.Object.MD.MimeType != "image/tiff" {
+		t.Errorf("stored mime type %s, want %s", stored.Object.MD.MimeType, "image/tiff")
+	}
+	if stored.Object.MD.Category != models.MimeTypeImage {
+		t.Errorf("stored category %s, want %s", stored.Object.MD.Category, models.MimeTypeImage)
+	}
+	if got := stored.Object.MD.Attributes["make"]; got != "Lens" {
+		t.Errorf("stored make %q, want %q", got, "Lens")
+	}
+}
+
+func TestV2_Index_subtitles(t *testing.T) {
+	var ipfs = &mocks.FakeRTFSManager{}
+	var se = &mocks.FakeSearcher{}
+	var v = NewV2WithEngine(V2Options{}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest("test/assets/talk.srt")
+
+	if _, err := v.Index(context.Background(), &lensv2.IndexReq{
+		Type: lensv2.IndexReq_IPLD,
+		Hash: testHash,
+	}); err != nil {
+		t.Fatalf("V2.Index() error = %v", err)
+	}
+	var stored = se.IndexArgsForCall(0)
+	if strings.Contains(stored.Content, "-->") {
+		t.Errorf("stored content contains cue timings: %s", stored.Content)
+	}
+	var want = []models.Section{
+		{Title: "00:00:01.000", Offset: 0},
+		{Title: "00:00:05.500", Offset: 31},
+		{Title: "00:01:10.000", Offset: 77},
+	}
+	if !reflect.DeepEqual(stored.Object.MD.Sections, want) {
+		t.Errorf("stored sections %v, want %v", stored.Object.MD.Sections, want)
+	}
+}
+
+func TestV2_Index_location(t *testing.T) {
+	var ipfs = &mocks.FakeRTFSManager{}
+	var se = &mocks.FakeSearcher{}
+	var v = NewV2WithEngine(V2Options{}, ipfs, &mocks.FakeTensorflowAnalyzer{}, se, zap.NewNop().Sugar())
+	ipfs.CustomRequestStub = mocks.StubIpfsRequest("test/assets/place.json")
+
+	if _, err := v.Index(context.Background(), &lensv2.IndexReq{
+		Type: lensv2.IndexReq_IPLD,
+		Hash: testHash,
+	}); err != nil {
+		t.Fatalf("V2.Index() error = %v", err)
+	}
+	var want = &models.GeoPoint{Lat: 49.2798, Lon: -123.1156}
+	if got := se.IndexArgsForCall(0).Object.MD.Location; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored location %v, want %v", got, want)
+	}
+}
+
+func TestV2_Index_name(t *testing.T) {
+	const name = "/ipns/docs.temporal.cloud"
+	type returns struct {
+		resolvePath string
+		resolveErr  bool
+		resolveHang bool
+	}
+	tests := []struct {
+		name        string
+		returns     returns
+		wantErrCode codes.Code
+	}{
+		{"resolve failure",
+			returns{"", true, false},
+			codes.NotFound},
+		{"resolve timeout",
+			returns{"", false, true},
+			codes.DeadlineExceeded},
+		{"resolves to path",
+			returns{"/ipfs/" + testHash + "/index.html", false, false},
+			codes.NotFound},
+		{"ok",
+			returns{"/ipfs/" + testHash, false, false},
+			0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var se = &mocks.FakeSearcher{}
+			var v = NewV2WithEngine(V2Options{RetrievalTimeout: 100 * time.Millisecond},
+				ipfs,
+				&mocks.FakeTensorflowAnalyzer{},
+				se,
+				zap.NewNop().Sugar())
+
+			// set up mocks
+			var resolveErr error
+			if tt.returns.resolveErr {
+				resolveErr = errors.New("oh no")
+			}
+			var stub = mocks.StubIpfsResolve("README.md", tt.returns.resolvePath, resolveErr)
+			ipfs.CustomRequestStub = func(ctx context.Context, url, command string,
+				opts map[string]string, args ...string) (*shell.Response, error) {
+				if tt.returns.resolveHang && command == "name/resolve" {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return stub(ctx, url, command, opts, args...)
+			}
+
+			// execute tests
+			got, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type: lensv2.IndexReq_IPLD,
+				Hash: name,
+			})
+			if (err != nil) != (tt.wantErrCode != 0) {
+				t.Errorf("V2.Index() error = %v, wantErr %v", err, (tt.wantErrCode != 0))
+				return
+			}
+			if tt.wantErrCode != 0 {
+				if s := status.Convert(err); s.Code() != tt.wantErrCode {
+					t.Errorf("V2.Index() err code = %s, want %s",
+						s.Code().String(), tt.wantErrCode.String())
+				}
+				return
+			}
+
+			// resolved hash should be stored and returned, along with the name
+			if got.GetDoc().GetHash() != testHash {
+				t.Errorf("got hash %s, want %s", got.GetDoc().GetHash(), testHash)
+			}
+			if doc := se.IndexArgsForCall(0); doc.Object.Hash != testHash ||
+				doc.Object.MD.IPNS != name {
+				t.Errorf("got stored object %+v, want hash %s and name %s",
+					doc.Object, testHash, name)
+			}
+			if tracked, ok := v.names.get(name); !ok || tracked.hash != testHash {
+				t.Errorf("got tracked name %+v (%v), want hash %s", tracked, ok, testHash)
+			}
+		})
+	}
+}
+
+func TestV2_Index_nameIndexed(t *testing.T) {
+	const name = "/ipns/docs.temporal.cloud"
+	const moved = "QmTbvUMmniE7wUP1ucbtC9s4ree7s8mSiQBt1c4odzKnY4"
+	tests := []struct {
+		name       string
+		tracked    string            // content the name was previously indexed with
+		owners     map[string]string // indexed documents and the names they were indexed from
+		wantIndex  bool
+		wantRemove bool
+	}{
+		{"content indexed directly", "",
+			map[string]string{moved: ""}, false, false},
+		{"content indexed by another name", "",
+			map[string]string{moved: "/ipns/other.temporal.cloud"}, false, false},
+		{"moved", testHash,
+			map[string]string{testHash: name}, true, true},
+		{"moved: old content indexed directly", testHash,
+			map[string]string{testHash: ""}, true, false},
+		{"moved: new content indexed directly", testHash,
+			map[string]string{testHash: name, moved: ""}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var se = &mocks.FakeSearcher{}
+			var v = NewV2WithEngine(V2Options{},
+				ipfs,
+				&mocks.FakeTensorflowAnalyzer{},
+				se,
+				zap.NewNop().Sugar())
+
+			// set up mocks
+			ipfs.CustomRequestStub = mocks.StubIpfsResolve("README.md", "/ipfs/"+moved, nil)
+			se.IsIndexedStub = func(hash string) bool {
+				_, ok := tt.owners[hash]
+				return ok
+			}
+			se.SearchStub = func(ctx context.Context, q engine.Query) ([]engine.Result, error) {
+				if owner, ok := tt.owners[q.Hashes[0]]; ok {
+					return []engine.Result{{Hash: q.Hashes[0], MD: models.MetaDataV2{
+						IPNS:     owner,
+						MimeType: "text/plain",
+					}}}, nil
+				}
+				return nil, errors.New("no results found")
+			}
+			if tt.tracked != "" {
+				v.names.track(name, tt.tracked, magnifyOpts{})
+			}
+
+			// execute tests
+			got, err := v.Index(context.Background(), &lensv2.IndexReq{
+				Type:    lensv2.IndexReq_IPLD,
+				Hash:    name,
+				Options: &lensv2.IndexReq_Options{Reindex: true},
+			})
+			if err != nil {
+				t.Fatalf("V2.Index() error = %v", err)
+			}
+			if got.GetDoc().GetHash() != moved {
+				t.Errorf("got hash %s, want %s", got.GetDoc().GetHash(), moved)
+			}
+			if !tt.wantIndex && got.GetDoc().GetMimeType() != "text/plain" {
+				t.Errorf("got document %+v, want indexed document", got.GetDoc())
+			}
+			if got := se.IndexCallCount() == 1; got != tt.wantIndex {
+				t.Errorf("wanted index = %v, got %d index calls", tt.wantIndex, se.IndexCallCount())
+			} else if got {
+				if doc := se.IndexArgsForCall(0); doc.Object.Hash != moved || doc.Object.MD.IPNS != name {
+					t.Errorf("wanted index of %s from %s, got %+v", moved, name, doc.Object)
+				}
+			}
+			if got := se.RemoveCallCount() == 1; got != tt.wantRemove {
+				t.Errorf("wanted remove = %v, got %d remove calls", tt.wantRemove, se.RemoveCallCount())
+			} else if got {
+				if removed := se.RemoveArgsForCall(0); removed != testHash {
+					t.Errorf("wanted removal of %s, got %s", testHash, removed)
+				}
+			}
+			if tracked, _ := v.names.get(name); tracked.hash != moved {
+				t.Errorf("wanted tracked hash %s, got %s", moved, tracked.hash)
+			}
+		})
+	}
+}
+
+func TestV2_loadNames(t *testing.T) {
+	const name = "/ipns/docs.temporal.cloud"
+	var se = &mocks.FakeSearcher{}
+
+	// a full page of names, followed by a page with the last name
+	var page = make([]engine.Result, engine.MaxResults)
+	for i := range page {
+		page[i] = engine.Result{Hash: testHash, MD: models.MetaDataV2{IPNS: fmt.Sprintf("/ipns/name%d", i)}}
+	}
+	se.SearchReturnsOnCall(0, page, nil)
+	se.SearchReturnsOnCall(1, []engine.Result{
+		{Hash: testHash, MD: models.MetaDataV2{IPNS: name, DisplayName: "docs", Tags: []string{"help"}}},
+		{Hash: testHashCBOR}, // indexed directly
+	}, nil)
+	var v = NewV2WithEngine(V2Options{},
+		&mocks.FakeRTFSManager{},
+		&mocks.FakeTensorflowAnalyzer{},
+		se,
+		zap.NewNop().Sugar())
+
+	if se.SearchCallCount() != 2 {
+		t.Fatalf("wanted 2 searches, got %d", se.SearchCallCount())
+	}
+	if _, q := se.SearchArgsForCall(1); !q.Named || q.Offset != engine.MaxResults {
+		t.Errorf("wanted search for second page of names, got %+v", q)
+	}
+	if got := len(v.names.all()); got != engine.MaxResults+1 {
+		t.Errorf("wanted %d tracked names, got %d", engine.MaxResults+1, got)
+	}
+	tracked, ok := v.names.get(name)
+	if !ok || tracked.hash != testHash || tracked.opts.DisplayName != "docs" ||
+		!reflect.DeepEqual(tracked.opts.Tags, []string{"help"}) {
+		t.Errorf("got tracked name %+v (%v), want hash %s with display name and tags",
+			tracked, ok, testHash)
+	}
+}
+
+func TestV2_refreshNames(t *testing.T) {
+	const name = "/ipns/docs.temporal.cloud"
+	const other = "/ipns/other.temporal.cloud"
+	const moved = "QmTbvUMmniE7wUP1ucbtC9s4ree7s8mSiQBt1c4odzKnY4"
+	tests := []struct {
+		name       string
+		resolved   string
+		owners     map[string]string // indexed documents and the names they were indexed from
+		otherName  bool              // another name refers to the original content
+		wantIndex  bool
+		wantRemove bool
+	}{
+		{"not moved", testHash,
+			map[string]string{testHash: name}, false, false, false},
+		{"moved", moved,
+			map[string]string{testHash: name}, false, true, true},
+		{"moved: old content indexed directly", moved,
+			map[string]string{testHash: ""}, false, true, false},
+		{"moved: old content shared with another name", moved,
+			map[string]string{testHash: name}, true, true, false},
+		{"moved: new content indexed directly", moved,
+			map[string]string{testHash: name, moved: ""}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ipfs = &mocks.FakeRTFSManager{}
+			var se = &mocks.FakeSearcher{}
+			var v = NewV2WithEngine(V2Options{},
+				ipfs,
+				&mocks.FakeTensorflowAnalyzer{},
+				se,
+				zap.NewNop().Sugar())
+
+			// set up mocks - the other name always refers to the original content
+			var moves = mocks.StubIpfsResolve("README.md", "/ipfs/"+tt.resolved, nil)
+			var stays = mocks.StubIpfsResolve("README.md", "/ipfs/"+testHash, nil)
+			ipfs.CustomRequestStub = func(ctx context.Context, url, command string,
+				opts map[string]string, args ...string) (*shell.Response, error) {
+				if command == "name/resolve" && args[0] == other {
+					return stays(ctx, url, command, opts, args...)
+				}
+				return moves(ctx, url, command, opts, args...)
+			}
+			se.IsIndexedReturns(true)
+			se.SearchStub = func(ctx context.Context, q engine.Query) ([]engine.Result, error) {
+				if owner, ok := tt.owners[q.Hashes[0]]; ok {
+					return []engine.Result{{Hash: q.Hashes[0], MD: models.MetaDataV2{IPNS: owner}}}, nil
+				}
+				return nil, errors.New("no results found")
+			}
+			v.names.track(name, testHash, magnifyOpts{})
+			if tt.otherName {
+				v.names.track(other, testHash, magnifyOpts{})
+			}
+
+			v.refreshNames(context.Background())
+			if got := se.IndexCallCount() == 1; got != tt.wantIndex {
+				t.Errorf("wanted index = %v, got %d index calls", tt.wantIndex, se.IndexCallCount())
+			} else if got {
+				if doc := se.IndexArgsForCall(0); doc.Object.Hash != moved || !doc.Reindex {
+					t.Errorf("wanted reindex of %s, got %+v", moved, doc)
+				}
+			}
+			if got := se.RemoveCallCount() == 1; got != tt.wantRemove {
+				t.Errorf("wanted remove = %v, got %d remove calls", tt.wantRemove, se.RemoveCallCount())
+			} else if got {
+				if removed := se.RemoveArgsForCall(0); removed != testHash {
+					t.Errorf("wanted removal of %s, got %s", testHash, removed)
+				}
+			}
+			if tracked, _ := v.names.get(name); tracked.hash != tt.resolved {
+				t.Errorf("wanted tracked hash %s, got %s", tt.resolved, tracked.hash)
+			}
+		})
+	}
+}
+
 func TestV2_Search(t *testing.T) {
 	type args struct {
 		req *lensv2.SearchReq
@@ -214,15 +1213,53 @@ func TestV2_Search(t *testing.T) {
 			args{&lensv2.SearchReq{
 				Query: "cats",
 			}},
-			returns{[]engine.Result{{Hash: "asdf"}}, nil},
+			returns{[]engine.Result{{Hash: testHash}}, nil},
 			0},
-		{"ok: with options",
+		{"ok: with matched sections",
+			args{&lensv2.SearchReq{
+				Query: "cats",
+			}},
+			returns{[]engine.Result{{Hash: testHash, Sections: []string{"Chapter 1"}}}, nil},
+			0},
+		{"invalid hash in options",
 			args{&lensv2.SearchReq{
 				Query: "cats",
 				Options: &lensv2.SearchReq_Options{
 					Hashes: []string{"asdf"},
 				}}},
-			returns{[]engine.Result{{Hash: "asdf"}}, nil},
+			returns{[]engine.Result{{Hash: testHash}}, nil},
+			codes.InvalidArgument},
+		{"unresolvable name in options",
+			args{&lensv2.SearchReq{
+				Query: "cats",
+				Options: &lensv2.SearchReq_Options{
+					Hashes: []string{"/ipns/docs.temporal.cloud"},
+				}}},
+			returns{[]engine.Result{{Hash: testHash}}, nil},
+			codes.NotFound},
+		{"unsupported scheme in options",
+			args{&lensv2.SearchReq{
+				Query: "cats",
+				Options: &lensv2.SearchReq_Options{
+					Hashes: []string{"s3://bucket/README.md"},
+				}}},
+			returns{[]engine.Result{{Hash: testHash}}, nil},
+			codes.InvalidArgument},
+		{"ok: with options",
+			args{&lensv2.SearchReq{
+				Query: "cats",
+				Options: &lensv2.SearchReq_Options{
+					Hashes: []string{testHash},
+				}}},
+			returns{[]engine.Result{{Hash: testHash}}, nil},
+			0},
+		{"ok: with ipfs uri in options",
+			args{&lensv2.SearchReq{
+				Query: "cats",
+				Options: &lensv2.SearchReq_Options{
+					Hashes: []string{"ipfs://" + testHash},
+				}}},
+			returns{[]engine.Result{{Hash: testHash}}, nil},
 			0},
 	}
 	for _, tt := range tests {
@@ -237,6 +1274,7 @@ func TestV2_Search(t *testing.T) {
 				zap.NewNop().Sugar())
 
 			// set up mocks
+			ipfs.CustomRequestStub = mocks.StubIpfsResolve("", "", errors.New("oh no"))
 			se.SearchReturns(tt.returns.searchReturns, tt.returns.searchError)
 
 			// execute tests
@@ -265,6 +1303,28 @@ func TestV2_Search(t *testing.T) {
 	}
 }
 
+func TestMatchedSections(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []engine.Result
+		want    []string
+	}{
+		{"no results", nil, nil},
+		{"no sections", []engine.Result{{Hash: testHash}}, nil},
+		{"sections", []engine.Result{
+			{Hash: testHash, Sections: []string{"Chapter 1", "Loomings & more"}},
+			{Hash: "abcde"},
+		}, []string{"hash=" + testHash + "&section=Chapter+1&section=Loomings+%26+more"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchedSections(tt.results).Get(MetadataMatchedSections); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchedSections() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestV2_Remove(t *testing.T) {
 	type args struct {
 		req *lensv2.RemoveReq
@@ -286,15 +1346,27 @@ func TestV2_Remove(t *testing.T) {
 			args{&lensv2.RemoveReq{}},
 			returns{true},
 			codes.InvalidArgument},
-		{"not indexed",
+		{"invalid hash",
 			args{&lensv2.RemoveReq{
 				Hash: "asdf",
 			}},
+			returns{true},
+			codes.InvalidArgument},
+		{"unsupported scheme",
+			args{&lensv2.RemoveReq{
+				Hash: "s3://bucket/README.md",
+			}},
+			returns{true},
+			codes.InvalidArgument},
+		{"not indexed",
+			args{&lensv2.RemoveReq{
+				Hash: testHash,
+			}},
 			returns{false},
 			codes.NotFound},
 		{"ok: indexed",
 			args{&lensv2.RemoveReq{
-				Hash: "asdf",
+				Hash: testHash,
 			}},
 			returns{true},
 			0},
@@ -1,25 +1,34 @@
 package lens
 
 import (
-	"errors"
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"io/ioutil"
 	"strings"
 	"time"
 
+	"github.com/RTradeLtd/Lens/v2/analyzer"
 	"github.com/RTradeLtd/Lens/v2/engine"
 	"github.com/RTradeLtd/Lens/v2/logs"
 	"github.com/RTradeLtd/Lens/v2/models"
+	"github.com/RTradeLtd/Lens/v2/source"
+	"github.com/RTradeLtd/Lens/v2/source/planetary"
 )
 
 // magnifyOpts declares configuration for magnification
 type magnifyOpts struct {
 	DisplayName string
+	Name        string
 	Reindex     bool
 	Tags        []string
 }
 
-func (v *V2) magnify(hash string, opts magnifyOpts) (content string, metadata *models.MetaDataV2, err error) {
+// magnify retrieves the object at the given content hash or URI from the given
+// source, and analyzes it for indexing
+func (v *V2) magnify(ctx context.Context, src source.Source, hash string, opts magnifyOpts) (content string, metadata *models.MetaDataV2, err error) {
 	if v.se.IsIndexed(hash) && !opts.Reindex {
 		return "", nil, fmt.Errorf("object '%s' has already been indexed", hash)
 	}
@@ -34,72 +43,210 @@ func (v *V2) magnify(hash string, opts magnifyOpts) (content string, metadata *m
 	var start = time.Now()
 	defer func() { l.Infow("magnification ended", "duration", time.Since(start)) }()
 
-	// retrieve object and detect content type
-	contents, err := v.px.ExtractContents(hash)
+	// limit time spent retrieving content - analysis is only limited by the
+	// request's own deadline
+	rctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	// structured IPLD objects can't be retrieved as files, so decode them instead
+	if src == v.px {
+		if id, err := planetary.DecodeStringToCID(hash); err == nil && !planetary.IsUnixFS(id) {
+			return v.magnifyObject(rctx, hash, planetary.MimeType(id), opts)
+		}
+	}
+
+	// check object size before retrieving it - sources that can't determine the
+	// size up front are limited while reading instead
+	size, err := src.Stat(rctx, hash)
+	if err != nil {
+		l.Warnw("failed to stat object", "error", err)
+		return "", nil, retrievalError(rctx, hash)
+	}
+
+	// retrieve object and detect content type from its first bytes
+	r, err := src.Fetch(rctx, hash)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to find content for hash '%s'", hash)
+		l.Warnw("failed to retrieve object", "error", err)
+		return "", nil, retrievalError(rctx, hash)
 	}
-	contentType := http.DetectContentType(contents)
+	defer r.Close()
+	var br = bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		l.Warnw("failed to read object", "error", err)
+		return "", nil, retrievalError(rctx, hash)
+	}
+	contentType := analyzer.DetectContentType(head)
 	if contentType == "" {
 		return "", nil, fmt.Errorf("unknown content type for document '%s'", hash)
 	}
-	l.Infow("object retrieved and content type detected",
-		"content_type", contentType)
-
-	// contentType will be in the format of `<content-type>; charset=...`
-	// we use strings.FieldsFunc to separate the string, and to be able to examine
-	// the content type
-	var parsed = strings.FieldsFunc(contentType, func(r rune) bool { return (r == ';') })
-	if parsed == nil || len(parsed) == 0 {
-		return "", nil, fmt.Errorf("invalid content type '%s'", contentType)
-	}
-
-	// scrape for content based on content-type
-	var category models.MimeType
-	switch parsed[0] {
-	case "application/pdf":
-		category = models.MimeTypePDF
-		text, err := v.oc.Analyze(hash, contents, "pdf")
-		if err != nil {
-			return "", nil, err
+	l.Infow("object found and content type detected",
+		"content_type", contentType,
+		"size", size)
+
+	// check size against the limit for this type of content. Content types
+	// shared by several categories, ie application/octet-stream for archives
+	// and media, are checked against the largest of their limits, as it is not
+	// known which analyzer accepts the content until it is analyzed.
+	var regs = v.analyzers.Match(contentType)
+	if len(regs) == 0 {
+		return "", nil, analyzer.ErrUnsupported
+	}
+	var category = regs[0].Category
+	var limit = v.limits.For(category)
+	for _, reg := range regs[1:] {
+		if max := v.limits.For(reg.Category); max > limit {
+			category, limit = reg.Category, max
 		}
-		content = text
-	default:
-		var parsed2 = strings.FieldsFunc(contentType, func(r rune) bool { return (r == '/') })
-		if parsed2 == nil || len(parsed2) == 0 {
-			return "", nil, fmt.Errorf("invalid content type '%s'", contentType)
+	}
+	if size > limit {
+		return "", nil, newSizeError(hash, category, limit)
+	}
+
+	// text that is too large to buffer is streamed into analyzers instead
+	var buffer = limit
+	var streamable = strings.HasPrefix(analyzer.MediaType(contentType), "text/") && v.stream < limit
+	if streamable {
+		buffer = v.stream
+	}
+	contents, more, err := readHead(rctx, br, hash, buffer)
+	if err != nil {
+		return "", nil, err
+	}
+	var in = analyzer.Input{
+		JobID:    hash,
+		Name:     opts.DisplayName,
+		MimeType: contentType,
+		Content:  contents,
+	}
+	var result *analyzer.Result
+	switch {
+	case !more:
+		// scrape for content using the best analyzer for this type of content
+		cancel()
+		result, err = v.analyzers.Analyze(ctx, in)
+	case streamable:
+		// the rest of the object is read while it is analyzed, so the analysis
+		// is limited by the retrieval timeout
+		l.Infow("streaming object into analyzers", "threshold", v.stream)
+		var r = &sizeLimitedReader{
+			r:     io.MultiReader(bytes.NewReader(contents), br),
+			limit: limit,
+			err:   newSizeError(hash, category, limit),
 		}
-		switch parsed2[0] {
-		case "text":
-			category = models.MimeTypeDocument
-			content = string(contents)
-		case "image":
-			category = models.MimeTypeImage
-			keyword, err := v.tf.Analyze(hash, contents)
-			if err != nil {
-				l.Warnw("failed to categorize image", "error", err)
-				return "", nil, errors.New("failed to categorize image")
-			}
-
-			// grab any text in image
-			text, err := v.oc.Analyze(hash, contents, "image")
-			if err != nil {
-				l.Warnw("failed to OCR image", "error", err)
-				content = keyword
-			} else {
-				content = text
-			}
-			opts.Tags = append(opts.Tags, keyword)
-		default:
-			return "", nil, errors.New("unsupported content type for indexing")
+		result, err = v.analyzers.AnalyzeStream(rctx, in, r)
+		if err != nil && rctx.Err() != nil {
+			err = rctx.Err()
 		}
+	default:
+		err = newSizeError(hash, category, limit)
 	}
+	if err != nil {
+		return "", nil, err
+	}
+	if max := v.limits.For(result.Category); !more && int64(len(contents)) > max {
+		// the analyzer that accepted the content has a smaller limit
+		return "", nil, newSizeError(hash, result.Category, max)
+	}
+	l.Infow("object analyzed",
+		"category", result.Category,
+		"content.length", len(result.Content))
 
-	return content, &models.MetaDataV2{
+	var md = &models.MetaDataV2{
 		DisplayName: opts.DisplayName,
 		MimeType:    contentType,
-		Category:    string(category),
+		Category:    result.Category,
+		Tags:        append(opts.Tags, result.Tags...),
+		Labels:      result.Labels,
+		Headings:    result.Headings,
+		Comments:    result.Comments,
+		Sections:    result.Sections,
+		References:  result.References,
+		Fields:      result.Fields,
+		Attributes:  result.Attributes,
+		Location:    result.Location,
+		IPNS:        opts.Name,
+	}
+	if md.DisplayName == "" {
+		md.DisplayName = result.DisplayName
+	}
+	if result.MimeType != "" {
+		md.MimeType = result.MimeType
+	}
+	return result.Content, md, nil
+}
+
+// sniffLen is the maximum number of bytes used to detect content types
+const sniffLen = 512
+
+// newSizeError creates an error for objects that exceed the size limit of
+// their category
+func newSizeError(hash, category string, limit int64) error {
+	return fmt.Errorf("object '%s' is too large to index: %s objects are limited to %d bytes",
+		hash, category, limit)
+}
+
+// retrievalError creates an error for objects that could not be retrieved.
+// Context errors are returned as-is so that they can be reported accordingly.
+func retrievalError(ctx context.Context, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("failed to find content for hash '%s'", hash)
+}
+
+// readHead reads up to n bytes of the given object into memory, reporting
+// whether there is more to read. Limits are enforced while reading in case the
+// object is larger than its reported size.
+func readHead(ctx context.Context, r io.Reader, hash string, n int64) ([]byte, bool, error) {
+	head, err := ioutil.ReadAll(io.LimitReader(r, n+1))
+	if err != nil {
+		return nil, false, retrievalError(ctx, hash)
+	}
+	return head, int64(len(head)) > n, nil
+}
+
+// sizeLimitedReader fails with err once more than limit bytes are read
+type sizeLimitedReader struct {
+	r     io.Reader
+	limit int64
+	err   error
+}
+
+func (s *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if s.limit -= int64(n); s.limit < 0 {
+		return n, s.err
+	}
+	return n, err
+}
+
+// magnifyObject decodes a structured IPLD object, and flattens its fields into
+// searchable text. Links to other objects are recorded as references.
+func (v *V2) magnifyObject(ctx context.Context, hash, mimeType string, opts magnifyOpts) (string, *models.MetaDataV2, error) {
+	var l = logs.NewProcessLogger(v.l, "magnify_object", "hash", hash)
+
+	var node interface{}
+	if err := v.px.ExtractObject(ctx, hash, &node); err != nil {
+		l.Warnw("failed to retrieve object", "error", err)
+		return "", nil, retrievalError(ctx, hash)
+	}
+	content, links := planetary.Flatten(node)
+	if content == "" {
+		return "", nil, fmt.Errorf("object '%s' has no fields to index", hash)
+	}
+	l.Infow("object retrieved and flattened",
+		"mime_type", mimeType,
+		"content.length", len(content),
+		"references", len(links))
+
+	return content, &models.MetaDataV2{
+		DisplayName: opts.DisplayName,
+		MimeType:    mimeType,
+		Category:    models.MimeTypeIPLD,
 		Tags:        opts.Tags,
+		References:  links,
+		IPNS:        opts.Name,
 	}, nil
 }
 